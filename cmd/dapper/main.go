@@ -11,8 +11,9 @@ var (
 		Long:  `A fast easy to use LDAP server for use with home labs`,
 	}
 
-	cfgFile string
-	baseDN  string
+	cfgFile      string
+	settingsFile string
+	baseDN       string
 )
 
 func init() {
@@ -29,6 +30,7 @@ func init() {
 	// Add flags to the root command
 	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "f", "~/.dapper.yaml", "config file (default is $HOME/.dapper.yaml)")
 	rootCmd.MarkPersistentFlagRequired("config")
+	rootCmd.PersistentFlags().StringVarP(&settingsFile, "settings", "s", "", "server settings file (e.g. admin DNs and access control)")
 
 	// Add the sub commands to the root
 	rootCmd.AddCommand(serverCmd)
@@ -34,20 +34,17 @@ type UserSearchResult struct {
 // search performs a search against the ldap service.
 func search(args []string) {
 	// create a new ldap server and load its config but don't start it.
-	dapper := ldap.NewServer(baseDN, cfgFile, serverPort)
-	dapper.ReloadConfiguration(cfgFile)
-
-	// perform the search using the SearchInternal function.
-	// if no argument is specified then search for all dn's
-	// otherwise use the query provided as an argument.
-	var result []*ldap2.Entry
-	var err error
-	if len(args) >= 1 {
-		result, err = dapper.SearchInternal(args[0])
-	} else {
-		result, err = dapper.SearchInternal("(dn=*)")
+	settings, err := ldap.LoadSettings(settingsFile)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
 	}
 
+	dapper := ldap.NewServer(baseDN, cfgFile, serverPort, settings)
+	dapper.ReloadAll()
+
+	result, err := searchEntries(dapper, args)
+
 	// if the search errors out then print an error message.
 	if err != nil {
 		dapper.Logger.Err(err).Msg("search failed")
@@ -86,6 +83,15 @@ func search(args []string) {
 	}
 }
 
+// searchEntries performs the search using the SearchInternal function. If no argument is
+// specified then it searches for every entry, otherwise it uses the query provided as an argument.
+func searchEntries(dapper *ldap.Server, args []string) ([]*ldap2.Entry, error) {
+	if len(args) >= 1 {
+		return dapper.SearchInternal(args[0])
+	}
+	return dapper.SearchInternal("(objectClass=*)")
+}
+
 func createSearchResult(res *ldap2.Entry) interface{} {
 	vals := res.GetAttributeValues("objectClass")
 	for _, v := range vals {
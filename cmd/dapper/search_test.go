@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	"github.com/rs/zerolog"
+	"github.com/shauncampbell/dapper/pkg/ldap"
+)
+
+func TestSearchEntries(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	// the example configuration has to conform to the schema the example settings declare,
+	// otherwise it isn't loaded.
+	settings, err := ldap.LoadSettings("../../settings.yaml")
+	Ω(err).ShouldNot(gomega.HaveOccurred())
+	settings.Schema.Validation = "reject"
+	dapper := ldap.NewServer("dc=home,dc=lab", "../../dapper.yaml", 0, settings)
+	dapper.Logger = zerolog.Nop()
+	dapper.ReloadAll()
+
+	// without a query every entry is returned.
+	result, err := searchEntries(dapper, nil)
+	Ω(err).ShouldNot(gomega.HaveOccurred())
+	Ω(result).Should(gomega.HaveLen(2))
+
+	result, err = searchEntries(dapper, []string{"(uid=root)"})
+	Ω(err).ShouldNot(gomega.HaveOccurred())
+	Ω(result).Should(gomega.HaveLen(1))
+	Ω(result[0].DN).Should(gomega.Equal("cn=root,dc=home,dc=lab"))
+
+	result, err = searchEntries(dapper, []string{"(objectClass=jellyfinUser)"})
+	Ω(err).ShouldNot(gomega.HaveOccurred())
+	Ω(result).Should(gomega.HaveLen(1))
+	Ω(result[0].DN).Should(gomega.Equal("cn=user,ou=users,dc=home,dc=lab"))
+
+	_, err = searchEntries(dapper, []string{"(uid=root"})
+	Ω(err).Should(gomega.HaveOccurred())
+}
@@ -12,7 +12,13 @@ var (
 		Short: "Start the LDAP server",
 		Long:  "Start the LDAP server on the specified port",
 		Run: func(cmd *cobra.Command, args []string) {
-			dapper := ldap.NewServer(baseDN, cfgFile, serverPort)
+			settings, err := ldap.LoadSettings(settingsFile)
+			if err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+
+			dapper := ldap.NewServer(baseDN, cfgFile, serverPort, settings)
 			if err := dapper.Listen(); err != nil {
 				fmt.Println(err.Error())
 			}
@@ -0,0 +1,213 @@
+package dn
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AttributeTypeAndValue is a single assertion within a relative distinguished name (e.g. cn=user).
+type AttributeTypeAndValue struct {
+	Type  string // Type is the attribute type (e.g. cn)
+	Value string // Value is the unescaped attribute value (e.g. user)
+}
+
+// RDN is a relative distinguished name. Most RDNs contain a single assertion but
+// multi-valued RDNs (e.g. cn=user+uid=1000) contain several.
+type RDN []AttributeTypeAndValue
+
+// DN is a parsed distinguished name. The first RDN is the leaf and the last RDN is the root.
+type DN []RDN
+
+// Parse parses a distinguished name in the string representation described by RFC 4514.
+// The empty string parses to the empty DN which represents the root of the tree.
+func Parse(str string) (DN, error) {
+	d := make(DN, 0)
+	if strings.TrimSpace(str) == "" {
+		return d, nil
+	}
+
+	rdn := make(RDN, 0)
+	offset := 0
+	for {
+		ava, next, err := parseAttributeTypeAndValue(str, offset)
+		if err != nil {
+			return nil, err
+		}
+		rdn = append(rdn, ava)
+
+		if next >= len(str) {
+			d = append(d, rdn)
+			return d, nil
+		}
+
+		switch str[next] {
+		case '+':
+			// multi-valued rdn, keep adding to the current one.
+		case ',', ';':
+			d = append(d, rdn)
+			rdn = make(RDN, 0)
+		default:
+			return nil, fmt.Errorf("unexpected character '%c' at position %d in dn '%s'", str[next], next, str)
+		}
+		offset = next + 1
+	}
+}
+
+// parseAttributeTypeAndValue parses a single type=value pair starting at offset and returns
+// the position of the separator (or end of string) which follows it.
+func parseAttributeTypeAndValue(str string, offset int) (AttributeTypeAndValue, int, error) {
+	eq := strings.IndexByte(str[offset:], '=')
+	if eq == -1 {
+		return AttributeTypeAndValue{}, -1, fmt.Errorf("missing '=' after position %d in dn '%s'", offset, str)
+	}
+	attrType := strings.TrimSpace(str[offset : offset+eq])
+	if attrType == "" {
+		return AttributeTypeAndValue{}, -1, fmt.Errorf("missing attribute type at position %d in dn '%s'", offset, str)
+	}
+
+	var value []byte
+	pos := offset + eq + 1
+
+	// skip leading spaces, they are not significant.
+	for pos < len(str) && str[pos] == ' ' {
+		pos++
+	}
+
+	// trailingSpaces tracks unescaped spaces at the end of the value so they can be dropped.
+	trailingSpaces := 0
+	for pos < len(str) {
+		c := str[pos]
+		if c == ',' || c == ';' || c == '+' {
+			break
+		}
+
+		if c == '\\' {
+			if pos+1 >= len(str) {
+				return AttributeTypeAndValue{}, -1, fmt.Errorf("unterminated escape at position %d in dn '%s'", pos, str)
+			}
+			if isHex(str[pos+1]) {
+				if pos+2 >= len(str) || !isHex(str[pos+2]) {
+					return AttributeTypeAndValue{}, -1, fmt.Errorf("invalid hex escape at position %d in dn '%s'", pos, str)
+				}
+				b, _ := hex.DecodeString(str[pos+1 : pos+3])
+				value = append(value, b...)
+				pos += 3
+			} else {
+				value = append(value, str[pos+1])
+				pos += 2
+			}
+			trailingSpaces = 0
+			continue
+		}
+
+		if c == ' ' {
+			trailingSpaces++
+		} else {
+			trailingSpaces = 0
+		}
+		value = append(value, c)
+		pos++
+	}
+
+	return AttributeTypeAndValue{Type: attrType, Value: string(value[:len(value)-trailingSpaces])}, pos, nil
+}
+
+// isHex returns true if the character is a valid hexadecimal digit.
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// EscapeValue escapes an attribute value so that it can be safely placed in the string form of a DN
+// (RFC 4514 section 2.4).
+func EscapeValue(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == ',' || c == '+' || c == '"' || c == '\\' || c == '<' || c == '>' || c == ';' || c == '=':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == ' ' && (i == 0 || i == len(value)-1):
+			b.WriteString("\\ ")
+		case c == '#' && i == 0:
+			b.WriteString("\\#")
+		case c < 0x20 || c == 0x7f:
+			b.WriteString(fmt.Sprintf("\\%02x", c))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// String produces the RFC 4514 string representation of the RDN.
+func (r RDN) String() string {
+	parts := make([]string, len(r))
+	for i, ava := range r {
+		parts[i] = ava.Type + "=" + EscapeValue(ava.Value)
+	}
+	return strings.Join(parts, "+")
+}
+
+// String produces the RFC 4514 string representation of the DN.
+func (d DN) String() string {
+	parts := make([]string, len(d))
+	for i, rdn := range d {
+		parts[i] = rdn.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Normalize produces a canonical string form of the DN which can be used for comparisons
+// and as a map key. Attribute types and values are lower-cased, insignificant spaces are
+// removed and the assertions within multi-valued RDNs are sorted.
+func (d DN) Normalize() string {
+	parts := make([]string, len(d))
+	for i, rdn := range d {
+		avas := make([]string, len(rdn))
+		for j, ava := range rdn {
+			avas[j] = strings.ToLower(ava.Type) + "=" + EscapeValue(strings.ToLower(strings.Join(strings.Fields(ava.Value), " ")))
+		}
+		sort.Strings(avas)
+		parts[i] = strings.Join(avas, "+")
+	}
+	return strings.Join(parts, ",")
+}
+
+// Equal returns true if both DNs refer to the same entry.
+func (d DN) Equal(other DN) bool {
+	return d.Normalize() == other.Normalize()
+}
+
+// IsRoot returns true if the DN is the empty DN.
+func (d DN) IsRoot() bool {
+	return len(d) == 0
+}
+
+// Parent returns the DN of the entry immediately above this one. The parent of the root is the root.
+func (d DN) Parent() DN {
+	if len(d) == 0 {
+		return d
+	}
+	return d[1:]
+}
+
+// IsDescendantOf returns true if the DN sits anywhere beneath the ancestor DN. A DN is not a
+// descendant of itself.
+func (d DN) IsDescendantOf(ancestor DN) bool {
+	if len(d) <= len(ancestor) {
+		return false
+	}
+	return d[len(d)-len(ancestor):].Equal(ancestor)
+}
+
+// Normalize parses a DN string and returns its canonical form.
+func Normalize(str string) (string, error) {
+	d, err := Parse(str)
+	if err != nil {
+		return "", err
+	}
+	return d.Normalize(), nil
+}
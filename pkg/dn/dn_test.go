@@ -0,0 +1,78 @@
+package dn
+
+import (
+	"github.com/onsi/gomega"
+	"testing"
+)
+
+func TestParseHappyPath(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	d, err := Parse("cn=user,ou=users,dc=home,dc=lab")
+	Ω(err).Should(gomega.BeNil())
+	Ω(len(d)).Should(gomega.Equal(4))
+	Ω(d[0]).Should(gomega.Equal(RDN{{Type: "cn", Value: "user"}}))
+	Ω(d.String()).Should(gomega.Equal("cn=user,ou=users,dc=home,dc=lab"))
+}
+
+func TestParseEscapes(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	d, err := Parse(`cn=Smith\, John,ou=a\2bb,dc=lab`)
+	Ω(err).Should(gomega.BeNil())
+	Ω(d[0][0].Value).Should(gomega.Equal("Smith, John"))
+	Ω(d[1][0].Value).Should(gomega.Equal("a+b"))
+	Ω(d.String()).Should(gomega.Equal(`cn=Smith\, John,ou=a\+b,dc=lab`))
+	Ω(EscapeValue(" a,b=c ")).Should(gomega.Equal(`\ a\,b\=c\ `))
+
+	_, err = Parse(`cn=bad\2`)
+	Ω(err).ShouldNot(gomega.BeNil())
+
+	_, err = Parse(`cn`)
+	Ω(err).ShouldNot(gomega.BeNil())
+}
+
+func TestParseMultiValuedRDN(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	a, err := Parse("uid=1000+cn=user,dc=lab")
+	Ω(err).Should(gomega.BeNil())
+	Ω(len(a[0])).Should(gomega.Equal(2))
+
+	b, err := Parse("CN=User + UID=1000, DC=Lab")
+	Ω(err).Should(gomega.BeNil())
+	Ω(a.Equal(b)).Should(gomega.Equal(true))
+}
+
+func TestParseRoot(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	d, err := Parse("")
+	Ω(err).Should(gomega.BeNil())
+	Ω(d.IsRoot()).Should(gomega.Equal(true))
+	Ω(d.Normalize()).Should(gomega.Equal(""))
+}
+
+func TestHierarchy(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	user, _ := Parse("cn=user,ou=users,dc=home,dc=lab")
+	users, _ := Parse("OU=Users,DC=Home,DC=Lab")
+	base, _ := Parse("dc=home,dc=lab")
+
+	Ω(user.Parent().Equal(users)).Should(gomega.Equal(true))
+	Ω(user.IsDescendantOf(base)).Should(gomega.Equal(true))
+	Ω(user.IsDescendantOf(users)).Should(gomega.Equal(true))
+	Ω(users.IsDescendantOf(user)).Should(gomega.Equal(false))
+	Ω(base.IsDescendantOf(base)).Should(gomega.Equal(false))
+}
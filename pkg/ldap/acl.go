@@ -0,0 +1,334 @@
+package ldap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nmcclain/ldap"
+	"github.com/rs/zerolog"
+	"github.com/shauncampbell/dapper/pkg/dn"
+	"github.com/shauncampbell/dapper/pkg/query"
+)
+
+// passwordAttribute is always treated as a sensitive attribute.
+const passwordAttribute = "userPassword"
+
+// entryAttribute is the pseudo-attribute which ACL rules use to refer to the entry itself.
+const entryAttribute = "entry"
+
+// access is a level of access to an entry or attribute. Each level implies all of the levels below it.
+type access int
+
+const (
+	accessNone    access = iota // no access at all
+	accessAuth                  // may be used to authenticate (bind)
+	accessCompare               // may be compared
+	accessSearch                // may be used in search filters
+	accessRead                  // may be read
+	accessWrite                 // may be modified
+)
+
+// accessLevels maps the names used in the settings file onto access levels.
+var accessLevels = map[string]access{
+	"none":    accessNone,
+	"auth":    accessAuth,
+	"compare": accessCompare,
+	"search":  accessSearch,
+	"read":    accessRead,
+	"write":   accessWrite,
+}
+
+// ACLRule is an access control rule in the style of OpenLDAP's "access to <what> by <who> <access>".
+// Rules are checked in order and the first rule whose target matches decides the access.
+type ACLRule struct {
+	To ACLTarget  `yaml:"to"` // To describes the entries and attributes the rule applies to
+	By []ACLGrant `yaml:"by"` // By lists who is granted which access, the first match wins
+}
+
+// ACLTarget describes the entries and attributes that an ACL rule applies to.
+type ACLTarget struct {
+	DN     string   `yaml:"dn"`     // DN is the root of the subtree the rule applies to (default: everything)
+	Scope  string   `yaml:"scope"`  // Scope is one of base, one, subtree or children (default: subtree)
+	Filter string   `yaml:"filter"` // Filter optionally restricts the rule to entries matching an LDAP filter
+	Attrs  []string `yaml:"attrs"`  // Attrs optionally restricts the rule to attributes, "entry" means the entry itself
+}
+
+// ACLGrant grants a level of access to a set of clients.
+type ACLGrant struct {
+	Who    string `yaml:"who"`    // Who is "*", "anonymous", "users", "self", a DN or dn.subtree=<DN>
+	Access string `yaml:"access"` // Access is one of none, auth, compare, search, read or write
+}
+
+// aclRule is the compiled form of an ACLRule.
+type aclRule struct {
+	dn     dn.DN
+	scope  string
+	filter query.Evaluator
+	attrs  map[string]bool
+	by     []aclGrant
+}
+
+// aclGrant is the compiled form of an ACLGrant.
+type aclGrant struct {
+	who    string
+	dn     dn.DN
+	access access
+}
+
+// compileACL validates the ACL rules from the settings and compiles them.
+func compileACL(rules []ACLRule) ([]aclRule, error) {
+	out := make([]aclRule, 0, len(rules))
+	for i, rule := range rules {
+		compiled := aclRule{scope: strings.ToLower(rule.To.Scope), by: make([]aclGrant, 0, len(rule.By))}
+
+		target, err := dn.Parse(rule.To.DN)
+		if err != nil {
+			return nil, fmt.Errorf("acl rule %d: %w", i, err)
+		}
+		compiled.dn = target
+
+		switch compiled.scope {
+		case "":
+			compiled.scope = "subtree"
+		case "base", "one", "subtree", "children":
+		default:
+			return nil, fmt.Errorf("acl rule %d: unknown scope '%s'", i, rule.To.Scope)
+		}
+
+		if rule.To.Filter != "" {
+			compiled.filter, _, err = query.Parse(rule.To.Filter, 0)
+			if err != nil {
+				return nil, fmt.Errorf("acl rule %d: %w", i, err)
+			}
+		}
+
+		if len(rule.To.Attrs) > 0 {
+			compiled.attrs = make(map[string]bool)
+			for _, a := range rule.To.Attrs {
+				compiled.attrs[strings.ToLower(a)] = true
+			}
+		}
+
+		for _, by := range rule.By {
+			level, ok := accessLevels[strings.ToLower(by.Access)]
+			if !ok {
+				return nil, fmt.Errorf("acl rule %d: unknown access level '%s'", i, by.Access)
+			}
+			grant := aclGrant{access: level}
+
+			switch who := strings.TrimSpace(by.Who); {
+			case who == "*" || who == "anonymous" || who == "users" || who == "self":
+				grant.who = who
+			case strings.HasPrefix(who, "dn.subtree="):
+				grant.who = "dn.subtree"
+				grant.dn, err = dn.Parse(strings.TrimPrefix(who, "dn.subtree="))
+			default:
+				grant.who = "dn"
+				grant.dn, err = dn.Parse(strings.TrimPrefix(strings.TrimPrefix(who, "dn.exact="), "dn="))
+			}
+			if err != nil {
+				return nil, fmt.Errorf("acl rule %d: %w", i, err)
+			}
+			compiled.by = append(compiled.by, grant)
+		}
+		out = append(out, compiled)
+	}
+	return out, nil
+}
+
+// matchesEntry returns true if the rule's target DN, scope and filter match the entry.
+func (r aclRule) matchesEntry(name dn.DN, entry *ldap.Entry) bool {
+	switch r.scope {
+	case "base":
+		if !name.Equal(r.dn) {
+			return false
+		}
+	case "one":
+		if !name.Parent().Equal(r.dn) || name.IsRoot() {
+			return false
+		}
+	case "subtree":
+		if !name.Equal(r.dn) && !name.IsDescendantOf(r.dn) {
+			return false
+		}
+	case "children":
+		if !name.IsDescendantOf(r.dn) {
+			return false
+		}
+	}
+	return r.filter == nil || r.filter.Evaluate(entry)
+}
+
+// matchesAttribute returns true if the rule applies to the attribute.
+func (r aclRule) matchesAttribute(attribute string) bool {
+	return r.attrs == nil || r.attrs[strings.ToLower(attribute)]
+}
+
+// matches returns true if the grant applies to the bound DN when accessing the named entry.
+func (g aclGrant) matches(bound dn.DN, name dn.DN) bool {
+	switch g.who {
+	case "*":
+		return true
+	case "anonymous":
+		return bound.IsRoot()
+	case "users":
+		return !bound.IsRoot()
+	case "self":
+		return !bound.IsRoot() && bound.Equal(name)
+	case "dn.subtree":
+		return !bound.IsRoot() && (bound.Equal(g.dn) || bound.IsDescendantOf(g.dn))
+	default:
+		return !bound.IsRoot() && bound.Equal(g.dn)
+	}
+}
+
+// accessControl decides what a client is allowed to do based on the DN it is bound as.
+type accessControl struct {
+	admins    map[string]bool // The normalised DNs of the admin users
+	sensitive map[string]bool // The lower-cased names of the sensitive attributes
+	rules     []aclRule       // The compiled ACL rules, empty if none are configured
+}
+
+// newAccessControl creates the access control layer from the server settings.
+func newAccessControl(settings Settings, logger zerolog.Logger) accessControl {
+	ac := accessControl{admins: make(map[string]bool), sensitive: map[string]bool{strings.ToLower(passwordAttribute): true}}
+
+	for _, admin := range settings.AdminDNs {
+		key, err := dn.Normalize(admin)
+		if err != nil {
+			logger.Warn().Err(err).Msgf("ignoring invalid admin dn '%s'", admin)
+			continue
+		}
+		ac.admins[key] = true
+	}
+
+	for _, attribute := range settings.SensitiveAttributes {
+		ac.sensitive[strings.ToLower(attribute)] = true
+	}
+
+	rules, err := compileACL(settings.ACL)
+	if err != nil {
+		// LoadSettings validates the rules so this only happens for hand built settings. Fail closed.
+		logger.Error().Err(err).Msg("invalid acl rules, denying all access to non-admins")
+		rules = []aclRule{{scope: "subtree", by: []aclGrant{{who: "*", access: accessNone}}}}
+	}
+	ac.rules = rules
+	return ac
+}
+
+// isAdmin returns true if the bound DN is one of the configured admins.
+func (ac accessControl) isAdmin(boundDN string) bool {
+	if boundDN == "" {
+		return false
+	}
+	key, err := dn.Normalize(boundDN)
+	return err == nil && ac.admins[key]
+}
+
+// isSensitive returns true if the attribute should only be visible to the entry itself and admins.
+func (ac accessControl) isSensitive(attribute string) bool {
+	return ac.sensitive[strings.ToLower(attribute)]
+}
+
+// decision holds the ACL rules which apply to a single entry for a single client.
+type decision struct {
+	ac    accessControl
+	admin bool      // The client is an admin and may do anything
+	self  bool      // The client is bound as the entry
+	bound dn.DN     // The DN the client is bound as
+	name  dn.DN     // The DN of the entry
+	rules []aclRule // The rules whose target matches the entry
+}
+
+// decide works out which rules apply when the bound DN accesses the entry.
+func (ac accessControl) decide(boundDN string, entry *ldap.Entry) decision {
+	d := decision{ac: ac, admin: ac.isAdmin(boundDN)}
+	if d.admin {
+		return d
+	}
+
+	bound, err := dn.Parse(boundDN)
+	if err != nil {
+		bound = dn.DN{}
+	}
+	name, err := dn.Parse(entry.DN)
+	if err != nil {
+		return d
+	}
+	d.bound = bound
+	d.name = name
+	d.self = !bound.IsRoot() && bound.Equal(name)
+
+	for _, rule := range ac.rules {
+		if rule.matchesEntry(name, entry) {
+			d.rules = append(d.rules, rule)
+		}
+	}
+	return d
+}
+
+// level returns the access the client has to the attribute of the entry.
+func (d decision) level(attribute string) access {
+	if d.admin {
+		return accessWrite
+	}
+
+	level := d.ruleLevel(attribute)
+
+	// sensitive attributes can be used to authenticate but otherwise only the entry itself may see them.
+	if d.ac.isSensitive(attribute) && !d.self && level > accessAuth {
+		return accessAuth
+	}
+	return level
+}
+
+// ruleLevel returns the access granted by the first rule which applies to the attribute.
+func (d decision) ruleLevel(attribute string) access {
+	// with no rules configured everyone may read everything.
+	if len(d.ac.rules) == 0 {
+		return accessRead
+	}
+
+	for _, rule := range d.rules {
+		if !rule.matchesAttribute(attribute) {
+			continue
+		}
+		for _, grant := range rule.by {
+			if grant.matches(d.bound, d.name) {
+				return grant.access
+			}
+		}
+		return accessNone
+	}
+	return accessNone
+}
+
+// allows returns true if the bound DN has at least the given access to the attribute of the entry.
+func (ac accessControl) allows(boundDN string, entry *ldap.Entry, attribute string, level access) bool {
+	return ac.decide(boundDN, entry).level(attribute) >= level
+}
+
+// view returns the parts of the entry that the bound DN may use in search filters and the
+// parts that it may read. Both are nil if the client may not see the entry at all.
+func (ac accessControl) view(boundDN string, entry *ldap.Entry) (searchable *ldap.Entry, readable *ldap.Entry) {
+	d := ac.decide(boundDN, entry)
+	if d.admin {
+		return entry, entry
+	}
+	if d.level(entryAttribute) < accessRead {
+		return nil, nil
+	}
+
+	searchable = &ldap.Entry{DN: entry.DN, Attributes: make([]*ldap.EntryAttribute, 0, len(entry.Attributes))}
+	readable = &ldap.Entry{DN: entry.DN, Attributes: make([]*ldap.EntryAttribute, 0, len(entry.Attributes))}
+	for _, a := range entry.Attributes {
+		level := d.level(a.Name)
+		if level >= accessSearch {
+			searchable.Attributes = append(searchable.Attributes, a)
+		}
+		if level >= accessRead {
+			readable.Attributes = append(readable.Attributes, a)
+		}
+	}
+	return searchable, readable
+}
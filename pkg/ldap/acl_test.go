@@ -0,0 +1,139 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+)
+
+// passwordVisible searches for the user entry as boundDN and reports whether the password was returned.
+func passwordVisible(t *testing.T, s *Server, boundDN string) bool {
+	result, err := s.Search(boundDN, ldap.SearchRequest{BaseDN: "cn=user,ou=users,dc=home,dc=lab", Scope: ldap.ScopeBaseObject, Filter: "(objectClass=*)"}, testConn(t))
+	if err != nil || len(result.Entries) != 1 {
+		t.Fatalf("expected exactly one entry, got %v (%v)", result.Entries, err)
+	}
+	return result.Entries[0].GetAttributeValue("userPassword") != ""
+}
+
+func TestPasswordHiddenFromOtherUsers(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	settings := DefaultSettings()
+	settings.AdminDNs = []string{"cn=root,dc=home,dc=lab"}
+	s := newTestServer(t, testConfig, settings)
+
+	Ω(passwordVisible(t, s, "")).Should(gomega.Equal(false))
+	Ω(passwordVisible(t, s, "cn=someone,dc=home,dc=lab")).Should(gomega.Equal(false))
+	Ω(passwordVisible(t, s, "CN=User,OU=Users,DC=Home,DC=Lab")).Should(gomega.Equal(true))
+	Ω(passwordVisible(t, s, "cn=root,dc=home,dc=lab")).Should(gomega.Equal(true))
+}
+
+func TestPasswordCannotBeProbedWithFilters(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, testConfig, DefaultSettings())
+
+	result, err := s.Search("", ldap.SearchRequest{BaseDN: "dc=home,dc=lab", Scope: ldap.ScopeWholeSubtree, Filter: "(userPassword={SSHA}*)"}, testConn(t))
+	Ω(err).Should(gomega.BeNil())
+	Ω(result.Entries).Should(gomega.BeEmpty())
+}
+
+func TestConfigurableSensitiveAttributes(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	settings := DefaultSettings()
+	settings.SensitiveAttributes = []string{"sn"}
+	s := newTestServer(t, testConfig, settings)
+
+	result, _ := s.Search("", ldap.SearchRequest{BaseDN: "cn=user,ou=users,dc=home,dc=lab", Scope: ldap.ScopeBaseObject, Filter: "(objectClass=*)"}, testConn(t))
+	Ω(result.Entries[0].GetAttributeValue("sn")).Should(gomega.Equal(""))
+	Ω(result.Entries[0].GetAttributeValue("uid")).Should(gomega.Equal("user"))
+}
+
+// serviceSettings limits a service account to reading uid and cn from ou=users.
+func serviceSettings() Settings {
+	settings := DefaultSettings()
+	settings.ACL = []ACLRule{
+		{
+			To: ACLTarget{DN: "ou=users,dc=home,dc=lab", Attrs: []string{"entry", "uid", "cn", "objectClass"}},
+			By: []ACLGrant{{Who: "cn=svc,dc=home,dc=lab", Access: "read"}, {Who: "users", Access: "search"}},
+		},
+		{
+			To: ACLTarget{DN: "dc=home,dc=lab", Attrs: []string{"userPassword"}},
+			By: []ACLGrant{{Who: "anonymous", Access: "auth"}},
+		},
+	}
+	return settings
+}
+
+func TestACLLimitsServiceAccount(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, testConfig, serviceSettings())
+
+	result, err := s.Search("cn=svc,dc=home,dc=lab", ldap.SearchRequest{BaseDN: "dc=home,dc=lab", Scope: ldap.ScopeWholeSubtree, Filter: "(objectClass=*)"}, testConn(t))
+	Ω(err).Should(gomega.BeNil())
+	Ω(dns(result)).Should(gomega.ConsistOf("ou=users,dc=home,dc=lab", "cn=user,ou=users,dc=home,dc=lab"))
+	Ω(names(result.Entries[1])).Should(gomega.ConsistOf("cn", "uid", "objectClass"))
+
+	// other users may match entries using a filter but not read them.
+	result, _ = s.Search("cn=root,dc=home,dc=lab", ldap.SearchRequest{BaseDN: "dc=home,dc=lab", Scope: ldap.ScopeWholeSubtree, Filter: "(uid=user)"}, testConn(t))
+	Ω(result.Entries).Should(gomega.BeEmpty())
+
+	// anonymous clients cannot see anything.
+	result, _ = s.Search("", ldap.SearchRequest{BaseDN: "dc=home,dc=lab", Scope: ldap.ScopeWholeSubtree, Filter: "(objectClass=*)"}, testConn(t))
+	Ω(result.Entries).Should(gomega.BeEmpty())
+}
+
+func TestACLBind(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	settings := serviceSettings()
+	s := newTestServer(t, testConfig, settings)
+
+	code, _ := s.Bind("cn=user,ou=users,dc=home,dc=lab", "test", testConn(t))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+
+	code, _ = s.Bind("cn=user,ou=users,dc=home,dc=lab", "", testConn(t))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultUnwillingToPerform)))
+
+	code, _ = s.Bind("", "", testConn(t))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+
+	// without auth access the password cannot be used to bind.
+	settings.ACL = settings.ACL[:1]
+	settings.DisableAnonymous = true
+	s = newTestServer(t, testConfig, settings)
+
+	code, _ = s.Bind("cn=user,ou=users,dc=home,dc=lab", "test", testConn(t))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultInvalidCredentials)))
+
+	code, _ = s.Bind("", "", testConn(t))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultUnwillingToPerform)))
+}
+
+func TestLoadSettingsRejectsInvalidACL(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	_, err := compileACL([]ACLRule{{To: ACLTarget{DN: "dc=home,dc=lab"}, By: []ACLGrant{{Who: "*", Access: "everything"}}}})
+	Ω(err).ShouldNot(gomega.BeNil())
+
+	_, err = compileACL([]ACLRule{{To: ACLTarget{DN: "dc=home,dc=lab", Filter: "uid=user"}, By: []ACLGrant{{Who: "*", Access: "read"}}}})
+	Ω(err).ShouldNot(gomega.BeNil())
+
+	_, err = compileACL(serviceSettings().ACL)
+	Ω(err).Should(gomega.BeNil())
+}
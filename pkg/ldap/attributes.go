@@ -0,0 +1,114 @@
+package ldap
+
+import (
+	"strings"
+
+	"github.com/nmcclain/ldap"
+)
+
+const (
+	allUserAttributes        = "*"   // Requests every user attribute
+	allOperationalAttributes = "+"   // Requests every operational attribute (RFC 3673)
+	noAttributes             = "1.1" // Requests no attributes at all
+)
+
+// operationalAttributes is the set of attributes which are maintained by the server and are
+// only returned when they are explicitly requested or '+' is requested.
+var operationalAttributes = map[string]bool{
+	"altserver":               true,
+	"attributetypes":          true,
+	"createtimestamp":         true,
+	"creatorsname":            true,
+	"entrydn":                 true,
+	"entryuuid":               true,
+	"hassubordinates":         true,
+	"ldapsyntaxes":            true,
+	"matchingrules":           true,
+	"matchingruleuse":         true,
+	"modifiersname":           true,
+	"modifytimestamp":         true,
+	"namingcontexts":          true,
+	"numsubordinates":         true,
+	"objectclasses":           true,
+	"structuralobjectclass":   true,
+	"subschemasubentry":       true,
+	"subtreespecification":    true,
+	"supportedcontrol":        true,
+	"supportedextension":      true,
+	"supportedfeatures":       true,
+	"supportedldapversion":    true,
+	"supportedsaslmechanisms": true,
+	"vendorname":              true,
+	"vendorversion":           true,
+}
+
+// isOperational returns true if the named attribute is an operational attribute.
+func isOperational(name string) bool {
+	return operationalAttributes[strings.ToLower(name)]
+}
+
+// attributeSelection describes which attributes a client asked for in a search request.
+type attributeSelection struct {
+	all         bool            // all user attributes were requested
+	operational bool            // all operational attributes were requested
+	names       map[string]bool // attributes requested by name, lower-cased
+}
+
+// newAttributeSelection interprets the attribute list of a search request as described in RFC 4511 section 4.5.1.8.
+func newAttributeSelection(attributes []string) attributeSelection {
+	sel := attributeSelection{names: make(map[string]bool)}
+
+	// an empty list is the same as asking for all user attributes.
+	if len(attributes) == 0 || (len(attributes) == 1 && attributes[0] == "") {
+		sel.all = true
+		return sel
+	}
+
+	for _, a := range attributes {
+		switch a {
+		case allUserAttributes:
+			sel.all = true
+		case allOperationalAttributes:
+			sel.operational = true
+		case noAttributes:
+			// 1.1 only means no attributes when it appears on its own, otherwise it is ignored.
+		default:
+			// strip any attribute options (e.g. cn;lang-en) as they are not supported.
+			name := strings.SplitN(a, ";", 2)[0]
+			sel.names[strings.ToLower(name)] = true
+		}
+	}
+	return sel
+}
+
+// includes returns true if the named attribute should be returned to the client.
+func (sel attributeSelection) includes(name string) bool {
+	if sel.names[strings.ToLower(name)] {
+		return true
+	}
+	if isOperational(name) {
+		return sel.operational
+	}
+	return sel.all
+}
+
+// selectAttributes returns a copy of the entry containing only the requested attributes. If
+// typesOnly is set then the attribute values are omitted. The original entry is never modified.
+func selectAttributes(entry *ldap.Entry, attributes []string, typesOnly bool) *ldap.Entry {
+	sel := newAttributeSelection(attributes)
+	out := &ldap.Entry{DN: entry.DN, Attributes: make([]*ldap.EntryAttribute, 0, len(entry.Attributes))}
+
+	for _, a := range entry.Attributes {
+		if !sel.includes(a.Name) {
+			continue
+		}
+
+		values := []string{}
+		if !typesOnly {
+			values = make([]string, len(a.Values))
+			copy(values, a.Values)
+		}
+		out.Attributes = append(out.Attributes, &ldap.EntryAttribute{Name: a.Name, Values: values})
+	}
+	return out
+}
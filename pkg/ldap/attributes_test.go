@@ -0,0 +1,62 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+)
+
+var attributeEntry = ldap.Entry{DN: "cn=user,dc=home,dc=lab",
+	Attributes: []*ldap.EntryAttribute{
+		{Name: "cn", Values: []string{"user"}},
+		{Name: "objectClass", Values: []string{"inetOrgPerson", "posixAccount"}},
+		{Name: "userPassword", Values: []string{"{SSHA}I8wq1+4gyJVJUtQW96JGcmCL46ADyPnW"}},
+		{Name: "createTimestamp", Values: []string{"20200101000000Z"}},
+	},
+}
+
+// names extracts the attribute names from an entry.
+func names(entry *ldap.Entry) []string {
+	out := make([]string, 0)
+	for _, a := range entry.Attributes {
+		out = append(out, a.Name)
+	}
+	return out
+}
+
+func TestSelectAttributesDefault(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	Ω(names(selectAttributes(&attributeEntry, nil, false))).Should(gomega.Equal([]string{"cn", "objectClass", "userPassword"}))
+	Ω(names(selectAttributes(&attributeEntry, []string{"*"}, false))).Should(gomega.Equal([]string{"cn", "objectClass", "userPassword"}))
+	Ω(names(selectAttributes(&attributeEntry, []string{"+"}, false))).Should(gomega.Equal([]string{"createTimestamp"}))
+	Ω(names(selectAttributes(&attributeEntry, []string{"*", "+"}, false))).Should(gomega.HaveLen(4))
+}
+
+func TestSelectAttributesExplicit(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	Ω(names(selectAttributes(&attributeEntry, []string{"CN", "objectclass"}, false))).Should(gomega.Equal([]string{"cn", "objectClass"}))
+	Ω(names(selectAttributes(&attributeEntry, []string{"cn", "createTimestamp"}, false))).Should(gomega.Equal([]string{"cn", "createTimestamp"}))
+	Ω(names(selectAttributes(&attributeEntry, []string{"1.1"}, false))).Should(gomega.BeEmpty())
+	Ω(names(selectAttributes(&attributeEntry, []string{"1.1", "cn"}, false))).Should(gomega.Equal([]string{"cn"}))
+}
+
+func TestSelectAttributesTypesOnly(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	entry := selectAttributes(&attributeEntry, []string{"objectClass"}, true)
+	Ω(names(entry)).Should(gomega.Equal([]string{"objectClass"}))
+	Ω(entry.Attributes[0].Values).Should(gomega.BeEmpty())
+
+	// the shared entry must not be modified.
+	Ω(attributeEntry.Attributes).Should(gomega.HaveLen(4))
+	Ω(attributeEntry.Attributes[1].Values).Should(gomega.HaveLen(2))
+}
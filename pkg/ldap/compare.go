@@ -0,0 +1,109 @@
+package ldap
+
+import (
+	"net"
+	"strings"
+
+	ber "github.com/nmcclain/asn1-ber"
+	"github.com/nmcclain/ldap"
+	"github.com/rs/zerolog"
+	"github.com/shauncampbell/dapper/pkg/query"
+)
+
+// compareRequest is a compare request as the client sent it.
+type compareRequest struct {
+	dn        string // The DN of the entry being compared
+	attribute string // The attribute description of the assertion
+	value     string // The value asserted
+}
+
+// decodeCompare decodes a compare request. It returns nil if the request is malformed, which
+// the ldap library reports to the client.
+func decodeCompare(request *ber.Packet) *compareRequest {
+	if len(request.Children) != 2 || len(request.Children[1].Children) != 2 {
+		return nil
+	}
+	name, ok := request.Children[0].Value.(string)
+	if !ok {
+		return nil
+	}
+	ava := request.Children[1]
+	attribute, ok := ava.Children[0].Value.(string)
+	if !ok {
+		return nil
+	}
+	value, ok := ava.Children[1].Value.(string)
+	if !ok {
+		return nil
+	}
+	return &compareRequest{dn: name, attribute: attribute, value: value}
+}
+
+// takeCompare returns the compare request being handled, if it arrived over a wrapped
+// connection and was well formed.
+func takeCompare(c net.Conn) (*compareRequest, bool) {
+	wrapped, ok := c.(*conn)
+	if !ok {
+		return nil, false
+	}
+	wrapped.lock.Lock()
+	defer wrapped.lock.Unlock()
+	compare := wrapped.compare
+	wrapped.compare = nil
+	return compare, compare != nil
+}
+
+// Compare is a handler for an incoming compare request.
+func (s *Server) Compare(boundDN string, req ldap.CompareRequest, conn net.Conn) (ldap.LDAPResultCode, error) {
+	logger := s.Logger.With().Str("operation", "compare").Str("request_ip", conn.RemoteAddr().String()).Str("bindDN", boundDN).Logger()
+
+	// the library doesn't pass on the assertion, only the connection has it.
+	compare, ok := takeCompare(conn)
+	if !ok {
+		logger.Error().Msgf("compare request was rejected because the assertion is not available")
+		return ldap.LDAPResultOperationsError, nil
+	}
+	logger.Debug().Str("dn", compare.dn).Str("attribute", compare.attribute).Msgf("request received")
+	return s.compare(boundDN, *compare, logger), nil
+}
+
+// compare checks whether the entry holds the asserted value, comparing values with the
+// equality matching rule of the attribute in the same way as an equality filter.
+func (s *Server) compare(boundDN string, req compareRequest, logger zerolog.Logger) ldap.LDAPResultCode {
+	if boundDN == "" && s.settings.DisableAnonymous {
+		logger.Error().Msgf("compare request was rejected because anonymous access is disabled")
+		return ldap.LDAPResultInsufficientAccessRights
+	}
+
+	// entries the client can't see are reported as missing, so compare can't reveal them.
+	entry := s.snapshot().find(req.dn)
+	if entry == nil {
+		logger.Debug().Msgf("the entry '%s' does not exist", req.dn)
+		return ldap.LDAPResultNoSuchObject
+	}
+	d := s.access.decide(boundDN, entry)
+	if d.level(entryAttribute) < accessCompare {
+		logger.Error().Msgf("compare request was rejected because the client may not see the entry")
+		return ldap.LDAPResultNoSuchObject
+	}
+	if d.level(req.attribute) < accessCompare {
+		logger.Error().Msgf("compare request was rejected because the client may not compare the attribute '%s'", req.attribute)
+		return ldap.LDAPResultInsufficientAccessRights
+	}
+
+	present := false
+	for _, a := range entry.Attributes {
+		present = present || strings.EqualFold(a.Name, req.attribute)
+	}
+	if !present {
+		logger.Debug().Msgf("the entry does not have the attribute '%s'", req.attribute)
+		return ldap.LDAPResultNoSuchAttribute
+	}
+
+	if query.NewAssertion(req.attribute, req.value).Evaluate(entry) {
+		logger.Debug().Msgf("compare request matched")
+		return ldap.LDAPResultCompareTrue
+	}
+	logger.Debug().Msgf("compare request did not match")
+	return ldap.LDAPResultCompareFalse
+}
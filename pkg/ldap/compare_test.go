@@ -0,0 +1,99 @@
+package ldap
+
+import (
+	"net"
+	"testing"
+
+	ber "github.com/nmcclain/asn1-ber"
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+	"github.com/rs/zerolog"
+)
+
+func TestCompare(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, groupConfig, adminSettings())
+
+	for _, test := range []struct {
+		request compareRequest
+		code    int
+	}{
+		// values are compared with the equality rule of the attribute.
+		{compareRequest{dn: "cn=admins,dc=home,dc=lab", attribute: "member", value: "cn=user, ou=users, dc=home, dc=lab"}, ldap.LDAPResultCompareTrue},
+		{compareRequest{dn: "cn=admins,dc=home,dc=lab", attribute: "member", value: "cn=other,dc=home,dc=lab"}, ldap.LDAPResultCompareFalse},
+		{compareRequest{dn: "CN=User,OU=Users,DC=Home,DC=Lab", attribute: "UID", value: "USER"}, ldap.LDAPResultCompareTrue},
+		{compareRequest{dn: "cn=user,ou=users,dc=home,dc=lab", attribute: "uid", value: "u*"}, ldap.LDAPResultCompareFalse},
+		{compareRequest{dn: "cn=user,ou=users,dc=home,dc=lab", attribute: "objectClass", value: "inetorgperson"}, ldap.LDAPResultCompareTrue},
+		{compareRequest{dn: "cn=user,ou=users,dc=home,dc=lab", attribute: "mail", value: "user@home.lab"}, ldap.LDAPResultNoSuchAttribute},
+		{compareRequest{dn: "cn=missing,dc=home,dc=lab", attribute: "cn", value: "missing"}, ldap.LDAPResultNoSuchObject},
+		// passwords can't be probed with compare.
+		{compareRequest{dn: "cn=user,ou=users,dc=home,dc=lab", attribute: "userPassword", value: "{SSHA}I8wq1+4gyJVJUtQW96JGcmCL46ADyPnW"}, ldap.LDAPResultInsufficientAccessRights},
+	} {
+		code := s.compare("", test.request, zerolog.Nop())
+		Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(test.code)), test.request.attribute+"="+test.request.value)
+	}
+
+	code := s.compare("cn=root,dc=home,dc=lab", compareRequest{dn: "cn=user,ou=users,dc=home,dc=lab", attribute: "userPassword", value: "{SSHA}I8wq1+4gyJVJUtQW96JGcmCL46ADyPnW"}, zerolog.Nop())
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultCompareTrue)))
+}
+
+func TestCompareAccessControl(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	settings := DefaultSettings()
+	settings.ACL = []ACLRule{
+		{To: ACLTarget{DN: "cn=admins,dc=home,dc=lab", Attrs: []string{"entry", "member"}}, By: []ACLGrant{{Who: "users", Access: "compare"}}},
+		{To: ACLTarget{DN: "ou=users,dc=home,dc=lab"}, By: []ACLGrant{{Who: "users", Access: "read"}}},
+	}
+	s := newTestServer(t, groupConfig, settings)
+	member := compareRequest{dn: "cn=admins,dc=home,dc=lab", attribute: "member", value: "cn=root,dc=home,dc=lab"}
+
+	// compare access is enough to check a membership.
+	Ω(s.compare("cn=user,ou=users,dc=home,dc=lab", member, zerolog.Nop())).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultCompareTrue)))
+	code := s.compare("cn=user,ou=users,dc=home,dc=lab", compareRequest{dn: "cn=admins,dc=home,dc=lab", attribute: "cn", value: "admins"}, zerolog.Nop())
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultInsufficientAccessRights)))
+
+	// entries which can't be seen look as if they don't exist.
+	Ω(s.compare("", member, zerolog.Nop())).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultNoSuchObject)))
+	code = s.compare("cn=user,ou=users,dc=home,dc=lab", compareRequest{dn: "cn=root,dc=home,dc=lab", attribute: "cn", value: "root"}, zerolog.Nop())
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultNoSuchObject)))
+
+	s.settings.DisableAnonymous = true
+	code = s.compare("", compareRequest{dn: "cn=user,ou=users,dc=home,dc=lab", attribute: "cn", value: "user"}, zerolog.Nop())
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultInsufficientAccessRights)))
+}
+
+// compareRequestPacket builds a compare request.
+func compareRequestPacket(name, attribute, value string) *ber.Packet {
+	request := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationCompareRequest, nil, "Compare Request")
+	request.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Entry"))
+	ava := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Assertion")
+	ava.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attribute, "Attribute"))
+	ava.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+	request.AppendChild(ava)
+	return request
+}
+
+func TestCompareOverConnection(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	c, err := net.Dial("tcp", listen(t, newTestServer(t, groupConfig, DefaultSettings())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	code, _ := sendRequest(t, c, 1, compareRequestPacket("cn=admins,dc=home,dc=lab", "member", "cn=user,ou=users,dc=home,dc=lab"))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultCompareTrue)))
+	code, _ = sendRequest(t, c, 2, compareRequestPacket("cn=admins,dc=home,dc=lab", "member", "cn=other,dc=home,dc=lab"))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultCompareFalse)))
+	code, _ = sendRequest(t, c, 3, compareRequestPacket("cn=missing,dc=home,dc=lab", "member", "cn=other,dc=home,dc=lab"))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultNoSuchObject)))
+}
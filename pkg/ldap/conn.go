@@ -0,0 +1,264 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+
+	ber "github.com/nmcclain/asn1-ber"
+	"github.com/nmcclain/ldap"
+)
+
+// listener wraps a net.Listener so that every accepted connection is a *conn.
+type listener struct {
+	net.Listener
+	tlsConfig *tls.Config          // The configuration used when clients start TLS, nil if TLS isn't available
+	secure    bool                 // Connections are already protected by TLS, as on the LDAPS port
+	mappings  []certificateMapping // The mappings of client certificates for SASL EXTERNAL, empty if it isn't available
+}
+
+// Accept waits for the next connection and wraps it.
+func (l listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: c, tlsConfig: l.tlsConfig, secure: l.secure, mappings: l.mappings}, nil
+}
+
+// conn wraps a client connection. The ldap library always reports success at the end of
+// a search and drops any response controls returned by the handler, so the handler records
+// its result on the connection and the final SearchResultDone message is rewritten with it
+// as it is sent.
+//
+// The library also turns the filter of a search request into a string, which loses escaped
+// values and substrings, so requests are read a message at a time and the original filter
+// is kept on the connection for the handler.
+//
+// StartTLS requests are answered here too, as the library can't switch its connection to TLS.
+// The library reads and writes from a single goroutine, so the underlying connection can be
+// replaced while reading. The library doesn't support SASL either, so SASL EXTERNAL binds are
+// turned into simple binds of the DN the client certificate maps onto.
+//
+// Add requests reach the handler without their attributes, and modify requests without the
+// order of their changes, so those are kept on the connection for the handler as well. The
+// library can't decode the new superior of a modify DN request at all, so the request is kept
+// and the library is given it without the new superior. Compare requests also reach the
+// handler without their assertion, so they are kept too.
+type conn struct {
+	net.Conn
+	tlsConfig *tls.Config              // The configuration used when the client starts TLS, nil if TLS isn't available
+	secure    bool                     // The connection is protected by TLS
+	mappings  []certificateMapping     // The mappings of client certificates for SASL EXTERNAL, empty if it isn't available
+	lock      sync.Mutex               // A lock protecting the pending result
+	result    *ldap.ServerSearchResult // The result of the search currently being sent
+	pending   []byte                   // The part of the current request the library hasn't read yet
+	filter    *ber.Packet              // The filter of the search request currently being handled
+	external  *externalBind            // The outcome of the SASL EXTERNAL bind currently being handled
+	update    *updateRequest           // The add or modify request currently being handled
+	rename    *renameRequest           // The modify DN request currently being handled
+	compare   *compareRequest          // The compare request currently being handled
+}
+
+// Read reads the next request from the client a whole message at a time so that it can be
+// inspected before the ldap library sees it.
+func (c *conn) Read(b []byte) (int, error) {
+	for len(c.pending) == 0 {
+		packet, err := ber.ReadPacket(c.Conn)
+		if err != nil {
+			return 0, err
+		}
+		if c.pending, err = c.intercept(packet); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// intercept inspects a request before it is handed to the ldap library and returns the
+// encoded message that the library should see instead, or nothing if the request has been
+// dealt with.
+func (c *conn) intercept(packet *ber.Packet) ([]byte, error) {
+	if len(packet.Children) < 2 {
+		return packet.Bytes(), nil
+	}
+
+	request := packet.Children[1]
+	if messageID, ok := packet.Children[0].Value.(uint64); ok && c.tlsConfig != nil && request.ClassType == ber.ClassApplication && isStartTLS(request) {
+		return nil, c.startTLS(messageID)
+	}
+	if request.ClassType == ber.ClassApplication && request.Tag == ldap.ApplicationBindRequest {
+		// only the request which was just rewritten may be treated as a SASL EXTERNAL bind.
+		var bind *externalBind
+		if len(c.mappings) > 0 && isSASLBind(request, saslExternal) {
+			mapped := c.mapExternalBind(request)
+			bind = &mapped
+		}
+		c.lock.Lock()
+		c.external = bind
+		c.lock.Unlock()
+		if bind != nil {
+			return externalBindRequest(packet, *bind).Bytes(), nil
+		}
+	}
+	if request.ClassType == ber.ClassApplication && (request.Tag == ldap.ApplicationAddRequest || request.Tag == ldap.ApplicationModifyRequest) {
+		update := decodeUpdate(request)
+		c.lock.Lock()
+		c.update = update
+		c.lock.Unlock()
+	}
+	if request.ClassType == ber.ClassApplication && request.Tag == ldap.ApplicationModifyDNRequest {
+		rename := decodeRename(request)
+		c.lock.Lock()
+		c.rename = rename
+		c.lock.Unlock()
+		if rename != nil && len(request.Children) > 3 {
+			request.Children = request.Children[:3]
+			return rebuild(packet).Bytes(), nil
+		}
+	}
+	if request.ClassType == ber.ClassApplication && request.Tag == ldap.ApplicationCompareRequest {
+		compare := decodeCompare(request)
+		c.lock.Lock()
+		c.compare = compare
+		c.lock.Unlock()
+	}
+	if request.ClassType == ber.ClassApplication && request.Tag == ldap.ApplicationSearchRequest && len(request.Children) > 6 {
+		// keep the original filter and give the library one that survives its string conversion.
+		c.lock.Lock()
+		c.filter = request.Children[6]
+		c.lock.Unlock()
+		request.Children[6] = ber.NewString(ber.ClassContext, ber.TypePrimitive, ldap.FilterPresent, "objectClass", "Present")
+		return rebuild(packet).Bytes(), nil
+	}
+	return packet.Bytes(), nil
+}
+
+// takeFilter returns the original filter of the search request being handled, or nil if
+// there isn't one.
+func (c *conn) takeFilter() *ber.Packet {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	filter := c.filter
+	c.filter = nil
+	return filter
+}
+
+// setSearchResult records the result of the search which is currently being processed.
+func (c *conn) setSearchResult(result ldap.ServerSearchResult) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.result = &result
+}
+
+// Write sends an LDAP message to the client, rewriting SearchResultDone messages with the
+// pending search result if there is one.
+func (c *conn) Write(b []byte) (int, error) {
+	c.lock.Lock()
+	result := c.result
+	if result != nil && operationTag(b) == ldap.ApplicationSearchResultDone {
+		c.result = nil
+	} else {
+		result = nil
+	}
+	c.lock.Unlock()
+
+	if result == nil {
+		return c.Conn.Write(b)
+	}
+
+	messageID, ok := messageID(b)
+	if !ok {
+		return c.Conn.Write(b)
+	}
+
+	if _, err := c.Conn.Write(encodeSearchDone(messageID, *result).Bytes()); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// rebuild re-encodes a constructed packet after its children have been changed.
+func rebuild(packet *ber.Packet) *ber.Packet {
+	if packet.TagType != ber.TypeConstructed {
+		return packet
+	}
+	out := ber.Encode(packet.ClassType, packet.TagType, packet.Tag, nil, packet.Description)
+	for _, child := range packet.Children {
+		out.AppendChild(rebuild(child))
+	}
+	return out
+}
+
+// operationTag returns the application tag of the protocol operation within an encoded LDAP
+// message without decoding the whole message. It returns 0xff if the message is malformed.
+func operationTag(b []byte) uint8 {
+	// skip the tag and length of the outer sequence.
+	pos, ok := skipHeader(b, 0)
+	if !ok {
+		return 0xff
+	}
+
+	// skip the message id.
+	next, ok := skipHeader(b, pos)
+	if !ok || next >= len(b) {
+		return 0xff
+	}
+	next += int(b[pos+1])
+	if next >= len(b) {
+		return 0xff
+	}
+	return b[next] & ber.TagBitmask
+}
+
+// skipHeader returns the position of the contents of the element at pos.
+func skipHeader(b []byte, pos int) (int, bool) {
+	if pos+2 > len(b) {
+		return 0, false
+	}
+	length := b[pos+1]
+	if length&0x80 == 0 {
+		return pos + 2, true
+	}
+	return pos + 2 + int(length&0x7f), pos+2+int(length&0x7f) <= len(b)
+}
+
+// messageID decodes the message id of an encoded LDAP message.
+func messageID(b []byte) (id uint64, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+		}
+	}()
+
+	packet := ber.DecodePacket(b)
+	if len(packet.Children) < 2 {
+		return 0, false
+	}
+	id, ok = packet.Children[0].Value.(uint64)
+	return id, ok
+}
+
+// encodeSearchDone builds a SearchResultDone message carrying the result code and response controls.
+func encodeSearchDone(messageID uint64, result ldap.ServerSearchResult) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+
+	done := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultDone, nil, "Search result done")
+	done.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, uint64(result.ResultCode), "resultCode: "))
+	done.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN: "))
+	done.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "errorMessage: "))
+	packet.AppendChild(done)
+
+	if len(result.Controls) > 0 {
+		controls := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
+		for _, control := range result.Controls {
+			controls.AppendChild(control.Encode())
+		}
+		packet.AppendChild(controls)
+	}
+	return packet
+}
@@ -0,0 +1,146 @@
+package ldap
+
+import (
+	"net"
+	"testing"
+
+	ber "github.com/nmcclain/asn1-ber"
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+)
+
+// listen starts the server on a random local port and returns its address.
+func listen(t *testing.T, s *Server) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.s.Serve(s.listener(ln, false))
+	t.Cleanup(func() { s.s.Quit <- true })
+	return ln.Addr().String()
+}
+
+// serve starts the server on a random local port and returns a connected client.
+func serve(t *testing.T, s *Server) *ldap.Conn {
+	client, err := ldap.Dial("tcp", listen(t, s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestSearchResultCodeReachesClient(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	client := serve(t, newTestServer(t, testConfig, DefaultSettings()))
+
+	result, err := client.Search(ldap.NewSearchRequest("ou=users,dc=home,dc=lab", ldap.ScopeSingleLevel, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	Ω(err).Should(gomega.BeNil())
+	Ω(len(result.Entries)).Should(gomega.Equal(1))
+
+	_, err = client.Search(ldap.NewSearchRequest("ou=missing,dc=home,dc=lab", ldap.ScopeSingleLevel, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	Ω(err).ShouldNot(gomega.BeNil())
+	Ω(err.(*ldap.Error).ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultNoSuchObject)))
+
+	// the connection should still be usable after a failed search.
+	result, err = client.Search(ldap.NewSearchRequest("dc=home,dc=lab", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	Ω(err).Should(gomega.BeNil())
+	Ω(len(result.Entries)).Should(gomega.Equal(1))
+}
+
+// rawSearch sends a subtree search with a hand built filter, which the ldap client can't
+// encode, and returns the DNs of the entries found and the result code.
+func rawSearch(t *testing.T, addr string, filter *ber.Packet) ([]string, ldap.LDAPResultCode) {
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	return searchConn(t, c, 1, filter)
+}
+
+// searchConn sends a subtree search of dc=home,dc=lab over an open connection and returns the
+// DNs of the entries found along with the result code.
+func searchConn(t *testing.T, c net.Conn, messageID uint64, filter *ber.Packet) ([]string, ldap.LDAPResultCode) {
+	request := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchRequest, nil, "Search Request")
+	request.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "dc=home,dc=lab", "Base DN"))
+	request.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, ldap.ScopeWholeSubtree, "Scope"))
+	request.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, ldap.NeverDerefAliases, "Deref Aliases"))
+	request.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 0, "Size Limit"))
+	request.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 0, "Time Limit"))
+	request.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, false, "Types Only"))
+	request.AppendChild(filter)
+	request.AppendChild(ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes"))
+
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	packet.AppendChild(request)
+	if _, err := c.Write(packet.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	found := make([]string, 0)
+	for {
+		response, err := ber.ReadPacket(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		op := response.Children[1]
+		switch op.Tag {
+		case ldap.ApplicationSearchResultEntry:
+			found = append(found, op.Children[0].Value.(string))
+		case ldap.ApplicationSearchResultDone:
+			return found, ldap.LDAPResultCode(op.Children[0].Value.(uint64))
+		}
+	}
+}
+
+// equalityFilter builds an equality match filter.
+func equalityFilter(attribute, value string) *ber.Packet {
+	filter := ber.Encode(ber.ClassContext, ber.TypeConstructed, ldap.FilterEqualityMatch, nil, "Equality Match")
+	filter.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attribute, "Attribute"))
+	filter.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+	return filter
+}
+
+func TestSearchUsesClientFilter(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	config := testConfig + `---
+dn: cn=Smith (John),ou=users,dc=home,dc=lab
+cn: Smith (John)
+description: "a*b"
+objectClass: inetOrgPerson
+`
+	addr := listen(t, newTestServer(t, config, DefaultSettings()))
+
+	// values with brackets can't survive the library's string form of the filter.
+	found, code := rawSearch(t, addr, equalityFilter("cn", "Smith (John)"))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(found).Should(gomega.Equal([]string{"cn=Smith (John),ou=users,dc=home,dc=lab"}))
+
+	// an equality match on a value with a star is not a wildcard.
+	found, _ = rawSearch(t, addr, equalityFilter("description", "a*"))
+	Ω(found).Should(gomega.BeEmpty())
+	found, _ = rawSearch(t, addr, equalityFilter("description", "a*b"))
+	Ω(found).Should(gomega.HaveLen(1))
+
+	// every part of a substring filter is used, not just the first.
+	substrings := ber.Encode(ber.ClassContext, ber.TypeConstructed, ldap.FilterSubstrings, nil, "Substrings")
+	substrings.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "cn", "Attribute"))
+	parts := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Substrings")
+	parts.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, ldap.FilterSubstringsInitial, "s", "Initial"))
+	parts.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, ldap.FilterSubstringsFinal, "(john)", "Final"))
+	substrings.AppendChild(parts)
+	found, _ = rawSearch(t, addr, substrings)
+	Ω(found).Should(gomega.Equal([]string{"cn=Smith (John),ou=users,dc=home,dc=lab"}))
+
+	// a malformed filter is rejected.
+	_, code = rawSearch(t, addr, ber.Encode(ber.ClassContext, ber.TypeConstructed, ldap.FilterNot, nil, "Not"))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultUnwillingToPerform)))
+}
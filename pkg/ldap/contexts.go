@@ -0,0 +1,168 @@
+package ldap
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nmcclain/ldap"
+	"github.com/shauncampbell/dapper/pkg/dn"
+)
+
+// NamingContext is a suffix served by the server alongside the base DN given on the command
+// line. Each naming context holds the entries read from its own configuration file.
+type NamingContext struct {
+	Suffix string `yaml:"suffix"` // The base DN of the naming context, e.g. dc=iot,dc=lab
+	File   string `yaml:"file"`   // The configuration file holding the entries of the naming context
+}
+
+// namingContext is a suffix served by the server and the entries last loaded for it.
+type namingContext struct {
+	suffix     dn.DN           // The parsed suffix
+	baseDN     string          // The suffix as it was configured
+	configFile string          // The configuration file holding the entries
+	entries    []*ldap.Entry   // The entries loaded from the configuration file, nil until it has been loaded
+	plaintext  map[string]bool // The normalised DNs of entries with passwords in plain text in the configuration file
+}
+
+// checkNamingContexts makes sure every naming context has a valid suffix, a configuration
+// file and a suffix which no other naming context uses.
+func checkNamingContexts(contexts []NamingContext) error {
+	seen := make(map[string]bool)
+	for _, nc := range contexts {
+		suffix, err := dn.Parse(nc.Suffix)
+		if err != nil {
+			return fmt.Errorf("naming context '%s' is not a valid dn: %w", nc.Suffix, err)
+		}
+		if suffix.IsRoot() {
+			return fmt.Errorf("naming context must have a suffix")
+		}
+		if nc.File == "" {
+			return fmt.Errorf("naming context '%s' has no configuration file", nc.Suffix)
+		}
+		if seen[suffix.Normalize()] {
+			return fmt.Errorf("naming context '%s' is configured more than once", nc.Suffix)
+		}
+		seen[suffix.Normalize()] = true
+	}
+	return nil
+}
+
+// newNamingContexts creates the naming contexts served by the server: the base DN with its
+// configuration file, followed by any extra naming contexts in the settings. Naming contexts
+// which are invalid or repeat a suffix are logged and left out.
+func (s *Server) newNamingContexts(baseDN, configFile string, extra []NamingContext) []*namingContext {
+	contexts := make([]*namingContext, 0, len(extra)+1)
+	seen := make(map[string]bool)
+	for _, nc := range append([]NamingContext{{Suffix: baseDN, File: configFile}}, extra...) {
+		suffix, err := dn.Parse(nc.Suffix)
+		if err != nil {
+			s.Logger.Error().Err(err).Msgf("ignoring naming context '%s' because it is not a valid dn", nc.Suffix)
+			continue
+		}
+		if seen[suffix.Normalize()] {
+			s.Logger.Error().Msgf("ignoring naming context '%s' because it is already served", nc.Suffix)
+			continue
+		}
+		seen[suffix.Normalize()] = true
+		contexts = append(contexts, &namingContext{suffix: suffix, baseDN: nc.Suffix, configFile: nc.File})
+	}
+	return contexts
+}
+
+// suffixes returns the suffixes of the naming contexts as they were configured.
+func (s *Server) suffixes() []string {
+	out := make([]string, 0, len(s.contexts))
+	for _, nc := range s.contexts {
+		out = append(out, nc.baseDN)
+	}
+	return out
+}
+
+// contextFor returns the naming context which holds the DN, which is the one with the longest
+// suffix of the DN, or nil if the DN isn't in any naming context.
+func (s *Server) contextFor(name dn.DN) *namingContext {
+	var best *namingContext
+	for _, nc := range s.contexts {
+		if !name.Equal(nc.suffix) && !name.IsDescendantOf(nc.suffix) {
+			continue
+		}
+		if best == nil || len(nc.suffix) > len(best.suffix) {
+			best = nc
+		}
+	}
+	return best
+}
+
+// assignEntries shares the entries read from a configuration file between the naming contexts
+// which it backs. Every entry must fall within one of those naming contexts, so one file can't
+// add entries to a naming context backed by another.
+func (s *Server) assignEntries(contexts []*namingContext, entries []*ldap.Entry) (map[*namingContext][]*ldap.Entry, error) {
+	assigned := make(map[*namingContext][]*ldap.Entry)
+	for _, nc := range contexts {
+		assigned[nc] = make([]*ldap.Entry, 0)
+	}
+
+	outside := make([]string, 0)
+	for _, entry := range entries {
+		name, err := dn.Parse(entry.DN)
+		if err != nil {
+			return nil, err
+		}
+		nc := s.contextFor(name)
+		if _, ok := assigned[nc]; !ok {
+			outside = append(outside, entry.DN)
+			continue
+		}
+		assigned[nc] = append(assigned[nc], entry)
+	}
+	if len(outside) > 0 {
+		return nil, fmt.Errorf("entries '%s' are not within a naming context backed by the file", strings.Join(outside, "', '"))
+	}
+	return assigned, nil
+}
+
+// contextsForFile returns the naming contexts whose entries are held in the file.
+func (s *Server) contextsForFile(filename string) []*namingContext {
+	out := make([]*namingContext, 0)
+	info, err := os.Stat(filename)
+	for _, nc := range s.contexts {
+		if nc.configFile == filename {
+			out = append(out, nc)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if other, err := os.Stat(nc.configFile); err == nil && os.SameFile(info, other) {
+			out = append(out, nc)
+		}
+	}
+	return out
+}
+
+// buildDirectory arranges the entries of every naming context into a single tree, along with
+// the subschema subentry. It must be called with the lock held.
+func (s *Server) buildDirectory() (*directory, error) {
+	suffixes := make([]dn.DN, 0, len(s.contexts))
+	entries := make([]*ldap.Entry, 0)
+	for _, nc := range s.contexts {
+		suffixes = append(suffixes, nc.suffix)
+		entries = append(entries, nc.entries...)
+	}
+
+	d, err := newDirectory(suffixes, entries)
+	if err != nil {
+		return nil, err
+	}
+	for _, nc := range s.contexts {
+		for key := range nc.plaintext {
+			d.plaintext[key] = true
+		}
+	}
+	if err := d.addSubentry(s.subschema); err != nil {
+		return nil, err
+	}
+	d.indexEntries(s.indexes)
+	return d, nil
+}
@@ -0,0 +1,101 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+	"github.com/rs/zerolog"
+)
+
+const iotConfig = `
+dn: dc=iot,dc=lab
+dc: iot
+objectClass: domain
+---
+dn: cn=sensor,dc=iot,dc=lab
+cn: sensor
+sn: Sensor
+objectClass: person
+userPassword: "{SSHA}I8wq1+4gyJVJUtQW96JGcmCL46ADyPnW"
+`
+
+// newTwoContextServer creates a server for dc=home,dc=lab with dc=iot,dc=lab as a second
+// naming context, and returns it with the configuration file of the second context.
+func newTwoContextServer(t *testing.T) (*Server, string) {
+	settings := DefaultSettings()
+	settings.NamingContexts = []NamingContext{{Suffix: "dc=iot,dc=lab", File: writeConfig(t, iotConfig)}}
+	s := NewServer("dc=home,dc=lab", writeConfig(t, testConfig), 0, settings)
+	s.Logger = zerolog.Nop()
+	s.ReloadAll()
+	return s, settings.NamingContexts[0].File
+}
+
+func TestNamingContexts(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s, iotFile := newTwoContextServer(t)
+	conn := testConn(t)
+
+	result, _ := s.Search("", ldap.SearchRequest{BaseDN: "dc=iot,dc=lab", Scope: ldap.ScopeWholeSubtree, Filter: "(objectClass=*)"}, conn)
+	Ω(dns(result)).Should(gomega.ConsistOf("dc=iot,dc=lab", "cn=sensor,dc=iot,dc=lab"))
+	result, _ = s.Search("", ldap.SearchRequest{BaseDN: "dc=home,dc=lab", Scope: ldap.ScopeWholeSubtree, Filter: "(objectClass=*)"}, conn)
+	Ω(dns(result)).Should(gomega.HaveLen(4))
+
+	// both suffixes are advertised and entries in either can bind.
+	result, _ = s.Search("", ldap.SearchRequest{BaseDN: "", Scope: ldap.ScopeBaseObject, Filter: "(objectClass=*)"}, conn)
+	Ω(result.Entries[0].GetAttributeValues("namingContexts")).Should(gomega.Equal([]string{"dc=home,dc=lab", "dc=iot,dc=lab"}))
+	code, _ := s.Bind("cn=sensor,dc=iot,dc=lab", "test", conn)
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+
+	// reloading one naming context leaves the other alone.
+	writeFile(t, iotFile, iotConfig+"---\ndn: cn=camera,dc=iot,dc=lab\ncn: camera\nsn: Camera\nobjectClass: person\n")
+	s.ReloadConfiguration(iotFile)
+	result, _ = s.Search("", ldap.SearchRequest{BaseDN: "dc=home,dc=lab", Scope: ldap.ScopeWholeSubtree, Filter: "(sn=*)"}, conn)
+	Ω(dns(result)).Should(gomega.ConsistOf("cn=user,ou=users,dc=home,dc=lab"))
+	result, _ = s.Search("", ldap.SearchRequest{BaseDN: "dc=iot,dc=lab", Scope: ldap.ScopeWholeSubtree, Filter: "(sn=*)"}, conn)
+	Ω(dns(result)).Should(gomega.ConsistOf("cn=sensor,dc=iot,dc=lab", "cn=camera,dc=iot,dc=lab"))
+
+	// a file can't add entries to another naming context.
+	writeFile(t, iotFile, iotConfig+"---\ndn: cn=intruder,dc=home,dc=lab\ncn: intruder\nsn: Intruder\nobjectClass: person\n")
+	s.ReloadConfiguration(iotFile)
+	result, _ = s.Search("", ldap.SearchRequest{BaseDN: "dc=home,dc=lab", Scope: ldap.ScopeWholeSubtree, Filter: "(cn=intruder)"}, conn)
+	Ω(result.Entries).Should(gomega.BeEmpty())
+	result, _ = s.Search("", ldap.SearchRequest{BaseDN: "dc=iot,dc=lab", Scope: ldap.ScopeWholeSubtree, Filter: "(cn=camera)"}, conn)
+	Ω(result.Entries).Should(gomega.HaveLen(1))
+
+	// the server doesn't hold anything above the suffixes, even though both are below dc=lab.
+	for _, base := range []string{"dc=lab", ""} {
+		result, _ = s.Search("", ldap.SearchRequest{BaseDN: base, Scope: ldap.ScopeWholeSubtree, Filter: "(objectClass=*)"}, conn)
+		Ω(result.ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultNoSuchObject)), base)
+		Ω(result.Entries).Should(gomega.BeEmpty())
+	}
+}
+
+func TestNamingContextsReachClient(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s, _ := newTwoContextServer(t)
+	client := serve(t, s)
+
+	Ω(client.Bind("cn=sensor,dc=iot,dc=lab", "test")).Should(gomega.Succeed())
+	result, err := client.Search(ldap.NewSearchRequest("dc=iot,dc=lab", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(cn=sensor)", nil, nil))
+	Ω(err).Should(gomega.BeNil())
+	Ω(result.Entries).Should(gomega.HaveLen(1))
+}
+
+func TestCheckNamingContexts(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	Ω(checkNamingContexts([]NamingContext{{Suffix: "dc=iot,dc=lab", File: "iot.yaml"}})).Should(gomega.Succeed())
+	Ω(checkNamingContexts([]NamingContext{{Suffix: "dc=iot,dc=lab"}})).ShouldNot(gomega.Succeed())
+	Ω(checkNamingContexts([]NamingContext{{Suffix: "", File: "iot.yaml"}})).ShouldNot(gomega.Succeed())
+	Ω(checkNamingContexts([]NamingContext{{Suffix: "dc=iot,,", File: "iot.yaml"}})).ShouldNot(gomega.Succeed())
+	Ω(checkNamingContexts([]NamingContext{{Suffix: "dc=iot,dc=lab", File: "a.yaml"}, {Suffix: "DC=IoT, DC=Lab", File: "b.yaml"}})).ShouldNot(gomega.Succeed())
+}
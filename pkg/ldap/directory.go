@@ -0,0 +1,206 @@
+package ldap
+
+import (
+	"sort"
+
+	"github.com/nmcclain/ldap"
+	"github.com/shauncampbell/dapper/pkg/dn"
+	"github.com/shauncampbell/dapper/pkg/query"
+)
+
+// node is a position within the directory tree. Nodes without an entry are glue
+// nodes which exist because an entry further down the tree implies them.
+type node struct {
+	dn       dn.DN       // The parsed distinguished name of this position in the tree
+	entry    *ldap.Entry // The entry stored at this position, or nil for glue nodes
+	children []*node     // The immediate subordinates of this node in insertion order
+	subentry bool        // The entry is a subentry, which only base object searches find
+	held     bool        // The node is at or below the suffix of a naming context
+	position int         // The position of the node when the whole tree is walked
+}
+
+// directory is the in-memory tree of entries read from the configuration file.
+// Once it has been built the directory is never changed, a new one is built instead.
+type directory struct {
+	entries    []*ldap.Entry    // All entries in the order they were read
+	entryNodes []*node          // The node of each entry, in the same order as entries
+	nodes      map[string]*node // Every node in the tree keyed by normalised DN
+	plaintext  map[string]bool  // The normalised DNs of entries with passwords in plain text in the configuration file
+	index      *query.Index     // The indexed attributes of the entries, nil if nothing is indexed
+}
+
+// newDirectory arranges the entries into a tree. The suffixes are always present in the tree
+// even if the configuration does not contain an entry for them.
+func newDirectory(suffixes []dn.DN, entries []*ldap.Entry) (*directory, error) {
+	d := &directory{entries: make([]*ldap.Entry, 0, len(entries)), nodes: make(map[string]*node), plaintext: make(map[string]bool)}
+	d.nodes[""] = &node{dn: dn.DN{}}
+	for _, suffix := range suffixes {
+		d.ensure(suffix)
+	}
+	for _, suffix := range suffixes {
+		d.nodes[suffix.Normalize()].hold()
+	}
+
+	for _, entry := range entries {
+		parsed, err := dn.Parse(entry.DN)
+		if err != nil {
+			return nil, err
+		}
+		n := d.ensure(parsed)
+		n.entry = entry
+		d.entries = append(d.entries, entry)
+		d.entryNodes = append(d.entryNodes, n)
+	}
+	return d, nil
+}
+
+// indexEntries indexes the attributes of the entries, so that searches can find the entries
+// which could match without looking at all of them. It must be called once the tree is complete.
+func (d *directory) indexEntries(attributes map[string][]query.IndexType) {
+	position := 0
+	var number func(n *node)
+	number = func(n *node) {
+		n.position = position
+		position++
+		for _, child := range n.children {
+			number(child)
+		}
+	}
+	number(d.nodes[""])
+
+	if len(attributes) > 0 {
+		d.index = query.NewIndex(d.entries, attributes)
+	}
+}
+
+// addSubentry places a subentry in the tree. Subentries hold information about the directory
+// rather than directory data, so they are only found by base object searches (RFC 3672 section 2.4).
+func (d *directory) addSubentry(entry *ldap.Entry) error {
+	parsed, err := dn.Parse(entry.DN)
+	if err != nil {
+		return err
+	}
+	n := d.ensure(parsed)
+	n.entry = entry
+	n.subentry = true
+	return nil
+}
+
+// ensure returns the node for the DN, creating it and any missing ancestors as glue.
+func (d *directory) ensure(name dn.DN) *node {
+	key := name.Normalize()
+	if n, ok := d.nodes[key]; ok {
+		return n
+	}
+
+	parent := d.ensure(name.Parent())
+	n := &node{dn: name, held: parent.held}
+	parent.children = append(parent.children, n)
+	d.nodes[key] = n
+	return n
+}
+
+// lookup finds the node with the given DN. It returns nil if the DN is invalid or not in the
+// tree. Glue nodes above the suffixes don't exist as far as clients are concerned, the server
+// doesn't hold them.
+func (d *directory) lookup(name string) *node {
+	key, err := dn.Normalize(name)
+	if err != nil {
+		return nil
+	}
+	n := d.nodes[key]
+	if n == nil || (n.entry == nil && !n.held) {
+		return nil
+	}
+	return n
+}
+
+// find returns the entry with the given DN or nil if there is no such entry.
+func (d *directory) find(name string) *ldap.Entry {
+	if n := d.lookup(name); n != nil {
+		return n.entry
+	}
+	return nil
+}
+
+// scope collects the entries that fall within the search scope rooted at the base node.
+func (d *directory) scope(base *node, scope int) []*ldap.Entry {
+	result := make([]*ldap.Entry, 0)
+	switch scope {
+	case ldap.ScopeBaseObject:
+		if base.entry != nil {
+			result = append(result, base.entry)
+		}
+	case ldap.ScopeSingleLevel:
+		for _, child := range base.children {
+			if child.entry != nil && !child.subentry {
+				result = append(result, child.entry)
+			}
+		}
+	case ldap.ScopeWholeSubtree:
+		result = base.walk(result)
+	}
+	return result
+}
+
+// candidates returns the entries within the scope of the search which could match the filter,
+// in the same order as scope. When the index can answer the filter only the entries it finds
+// are returned, otherwise every entry in scope is. It also returns whether the index was used.
+func (d *directory) candidates(base *node, scope int, q query.Evaluator) ([]*ldap.Entry, bool) {
+	if scope == ldap.ScopeBaseObject {
+		return d.scope(base, scope), false
+	}
+	ids, ok := d.index.Plan(q)
+	if !ok {
+		return d.scope(base, scope), false
+	}
+
+	nodes := make([]*node, 0, len(ids))
+	for _, id := range ids {
+		// entries with the same dn as a later entry aren't in the tree.
+		n := d.entryNodes[id]
+		if n.entry != d.entries[id] || n.subentry || !n.within(base, scope) {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].position < nodes[j].position })
+
+	result := make([]*ldap.Entry, 0, len(nodes))
+	for _, n := range nodes {
+		result = append(result, n.entry)
+	}
+	return result, true
+}
+
+// hold marks the node and all of its subordinates as held by a naming context.
+func (n *node) hold() {
+	n.held = true
+	for _, child := range n.children {
+		child.hold()
+	}
+}
+
+// within returns true if the node is within the one level or subtree scope rooted at the base.
+func (n *node) within(base *node, scope int) bool {
+	switch scope {
+	case ldap.ScopeSingleLevel:
+		return !n.dn.IsRoot() && n.dn.Parent().Equal(base.dn)
+	case ldap.ScopeWholeSubtree:
+		return n == base || n.dn.IsDescendantOf(base.dn)
+	}
+	return false
+}
+
+// walk appends the entry for this node and all of its subordinates to the result.
+func (n *node) walk(result []*ldap.Entry) []*ldap.Entry {
+	if n.entry != nil {
+		result = append(result, n.entry)
+	}
+	for _, child := range n.children {
+		if !child.subentry {
+			result = child.walk(result)
+		}
+	}
+	return result
+}
@@ -0,0 +1,102 @@
+package ldap
+
+import (
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+)
+
+const extraEntry = `
+---
+cn: extra
+uid: extra
+dn: cn=extra,ou=users,dc=home,dc=lab
+userPassword: "{SSHA}I8wq1+4gyJVJUtQW96JGcmCL46ADyPnW"
+objectClass: "posixAccount"
+`
+
+func TestSnapshotIsUnchangedByReload(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, testConfig, DefaultSettings())
+	filename := s.contexts[0].configFile
+	before := s.snapshot()
+
+	writeFile(t, filename, testConfig+extraEntry)
+	s.ReloadConfiguration(filename)
+
+	// requests which started before the reload carry on with the directory they started with.
+	Ω(before.find("cn=extra,ou=users,dc=home,dc=lab")).Should(gomega.BeNil())
+	Ω(before.entries).Should(gomega.HaveLen(4))
+	Ω(s.snapshot().find("cn=extra,ou=users,dc=home,dc=lab")).ShouldNot(gomega.BeNil())
+	Ω(s.snapshot().entries).Should(gomega.HaveLen(5))
+
+	// a configuration which can't be loaded leaves the published directory alone.
+	current := s.snapshot()
+	writeFile(t, filename, testConfig+extraEntry+"---\ndn: cn=other,dc=elsewhere\ncn: other\n")
+	s.ReloadConfiguration(filename)
+	Ω(s.snapshot()).Should(gomega.BeIdenticalTo(current))
+}
+
+func TestConcurrentReloads(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, testConfig, DefaultSettings())
+	filename := s.contexts[0].configFile
+
+	// the configuration file switches between having the extra entry and not having it.
+	done := make(chan struct{})
+	reloads := &sync.WaitGroup{}
+	reloads.Add(1)
+	go func() {
+		defer reloads.Done()
+		configs := []string{testConfig + extraEntry, testConfig}
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if err := ioutil.WriteFile(filename, []byte(configs[i%2]), 0600); err != nil {
+				return
+			}
+			s.ReloadConfiguration(filename)
+		}
+	}()
+
+	requests := &sync.WaitGroup{}
+	codes := make(chan ldap.LDAPResultCode, 1000)
+	sizes := make(chan int, 1000)
+	for i := 0; i < 10; i++ {
+		requests.Add(1)
+		go func() {
+			defer requests.Done()
+			for j := 0; j < 50; j++ {
+				code, _ := s.Bind("cn=user,ou=users,dc=home,dc=lab", "test", testConn(t))
+				codes <- code
+				result, _ := s.Search("cn=root,dc=home,dc=lab", ldap.SearchRequest{BaseDN: "dc=home,dc=lab", Scope: ldap.ScopeWholeSubtree, Filter: "(objectClass=*)"}, testConn(t))
+				sizes <- len(result.Entries)
+			}
+		}()
+	}
+	requests.Wait()
+	close(done)
+	reloads.Wait()
+	close(codes)
+	close(sizes)
+
+	// every request saw a whole directory, either before or after a reload.
+	for code := range codes {
+		Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	}
+	for size := range sizes {
+		Ω(size).Should(gomega.BeElementOf(4, 5))
+	}
+}
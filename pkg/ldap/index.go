@@ -0,0 +1,38 @@
+package ldap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shauncampbell/dapper/pkg/query"
+)
+
+// IndexSettings names an attribute whose values are indexed so that searches for it don't
+// have to look at every entry, in the style of OpenLDAP's "index uid eq,pres,sub".
+type IndexSettings struct {
+	Attribute string   `yaml:"attribute"` // The attribute to index, e.g. uid
+	Types     []string `yaml:"types"`     // Any of eq, pres and sub (initial substrings), every type if empty
+}
+
+// compileIndexes checks the index settings and returns the lookups wanted for each attribute.
+func compileIndexes(settings []IndexSettings) (map[string][]query.IndexType, error) {
+	out := make(map[string][]query.IndexType)
+	for _, index := range settings {
+		if index.Attribute == "" {
+			return nil, fmt.Errorf("index must name an attribute")
+		}
+		names := index.Types
+		if len(names) == 0 {
+			names = []string{string(query.IndexEquality), string(query.IndexPresence), string(query.IndexSubstrings)}
+		}
+		for _, name := range names {
+			t, err := query.ParseIndexType(name)
+			if err != nil {
+				return nil, fmt.Errorf("index of attribute '%s' is invalid: %w", index.Attribute, err)
+			}
+			key := strings.ToLower(index.Attribute)
+			out[key] = append(out[key], t)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,115 @@
+package ldap
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+	"github.com/shauncampbell/dapper/pkg/query"
+)
+
+// deviceConfig returns a configuration holding the test entries and some devices in their
+// own organisational unit.
+func deviceConfig(n int) string {
+	b := &strings.Builder{}
+	b.WriteString(testConfig)
+	b.WriteString("---\ndn: ou=devices,dc=home,dc=lab\nou: devices\nobjectClass: organizationalUnit\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(b, "---\ndn: cn=device%d,ou=devices,dc=home,dc=lab\ncn: device%d\nobjectClass: device\nserialNumber: \"%d\"\n", i, i, i%7)
+	}
+	return b.String()
+}
+
+func TestCompileIndexes(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	indexes, err := compileIndexes([]IndexSettings{{Attribute: "uid", Types: []string{"eq"}}, {Attribute: "objectClass"}, {Attribute: "UID", Types: []string{"sub"}}})
+	Ω(err).Should(gomega.BeNil())
+	Ω(indexes).Should(gomega.Equal(map[string][]query.IndexType{
+		"uid":         {query.IndexEquality, query.IndexSubstrings},
+		"objectclass": {query.IndexEquality, query.IndexPresence, query.IndexSubstrings},
+	}))
+
+	_, err = compileIndexes([]IndexSettings{{Types: []string{"eq"}}})
+	Ω(err).Should(gomega.MatchError("index must name an attribute"))
+	_, err = compileIndexes([]IndexSettings{{Attribute: "uid", Types: []string{"approx"}}})
+	Ω(err).Should(gomega.MatchError("index of attribute 'uid' is invalid: unknown index type 'approx', expected one of eq, pres or sub"))
+}
+
+// TestIndexedSearch checks that searches which use the indexes find the same entries, in the
+// same order, as searches which look at every entry.
+func TestIndexedSearch(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	config := deviceConfig(50)
+	settings := serviceSettings()
+	scanned := newTestServer(t, config, settings)
+	settings.Indexes = []IndexSettings{{Attribute: "cn"}, {Attribute: "objectClass", Types: []string{"eq", "pres"}}, {Attribute: "uid", Types: []string{"eq"}}}
+	indexed := newTestServer(t, config, settings)
+	Ω(indexed.snapshot().index).ShouldNot(gomega.BeNil())
+	Ω(indexed.snapshot().entries).Should(gomega.HaveLen(55))
+
+	filters := []string{
+		"(objectClass=*)",
+		"(objectClass=device)",
+		"(cn=device4*)",
+		"(&(objectClass=device)(serialNumber=3))",
+		"(|(cn=device1)(cn=user)(uid=root))",
+		"(&(objectClass=posixAccount)(!(uid=root)))",
+		"(uid=user)",
+		"(cn=missing)",
+	}
+	bases := map[string]int{
+		"dc=home,dc=lab":                       ldap.ScopeWholeSubtree,
+		"ou=devices,dc=home,dc=lab":            ldap.ScopeSingleLevel,
+		"ou=users,dc=home,dc=lab":              ldap.ScopeWholeSubtree,
+		"cn=device7,ou=devices,dc=home,dc=lab": ldap.ScopeBaseObject,
+	}
+	for _, boundDN := range []string{"cn=root,dc=home,dc=lab", "cn=svc,dc=home,dc=lab"} {
+		for base, scope := range bases {
+			for _, filter := range filters {
+				request := ldap.SearchRequest{BaseDN: base, Scope: scope, Filter: filter}
+				expected, err := scanned.Search(boundDN, request, testConn(t))
+				Ω(err).Should(gomega.BeNil())
+				result, err := indexed.Search(boundDN, request, testConn(t))
+				Ω(err).Should(gomega.BeNil())
+				Ω(dns(result)).Should(gomega.Equal(dns(expected)), boundDN+" "+base+" "+filter)
+			}
+		}
+	}
+}
+
+func TestIndexCandidates(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	settings := DefaultSettings()
+	settings.Indexes = []IndexSettings{{Attribute: "cn"}}
+	s := newTestServer(t, deviceConfig(20), settings)
+	d := s.snapshot()
+	base := d.lookup("dc=home,dc=lab")
+
+	q, _, err := query.Parse("(cn=device1*)", 0)
+	Ω(err).Should(gomega.BeNil())
+	entries, indexed := d.candidates(base, ldap.ScopeWholeSubtree, q)
+	Ω(indexed).Should(gomega.BeTrue())
+	Ω(entries).Should(gomega.HaveLen(11))
+
+	// the entries are only narrowed down in scope.
+	entries, _ = d.candidates(base, ldap.ScopeSingleLevel, q)
+	Ω(entries).Should(gomega.BeEmpty())
+
+	// filters the indexes can't answer look at every entry.
+	q, _, err = query.Parse("(serialNumber=1)", 0)
+	Ω(err).Should(gomega.BeNil())
+	entries, indexed = d.candidates(base, ldap.ScopeWholeSubtree, q)
+	Ω(indexed).Should(gomega.BeFalse())
+	Ω(entries).Should(gomega.HaveLen(len(d.entries)))
+}
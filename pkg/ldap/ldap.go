@@ -1,42 +1,99 @@
 package ldap
 
 import (
+	"crypto/tls"
 	"fmt"
 	"github.com/nmcclain/ldap"
 	"github.com/radovskyb/watcher"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/shauncampbell/dapper/pkg/password"
 	query "github.com/shauncampbell/dapper/pkg/query"
+	"github.com/shauncampbell/dapper/pkg/schema"
 	"gopkg.in/yaml.v2"
 	"io"
 	"net"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Server is a struct holding all of the state information about your LDAP server
 type Server struct {
-	port       int            // The port number of the LDAP server
-	baseDN     string         // The baseDN that the LDAP server will service
-	configFile string         // The configFile containing the config for the server
-	s          *ldap.Server   // The underlying ldap.Server implementation
-	Logger     zerolog.Logger // The logger being used for console printing
-	lock       sync.Mutex     // A lock to prevent multiple updates clashing
-	entries    []*ldap.Entry  // The ldap entries read from the configuration file
+	port        int                          // The port number of the LDAP server
+	contexts    []*namingContext             // The naming contexts the LDAP server will service
+	s           *ldap.Server                 // The underlying ldap.Server implementation
+	Logger      zerolog.Logger               // The logger being used for console printing
+	lock        sync.Mutex                   // A lock to prevent multiple updates clashing
+	writeLock   sync.Mutex                   // A lock to prevent multiple writes to the configuration files clashing
+	directory   atomic.Value                 // The current *directory, which is replaced rather than changed when the configuration is reloaded
+	settings    Settings                     // The server wide settings
+	access      accessControl                // The access control rules derived from the settings
+	pages       *pager                       // The unfinished paged searches for each connection
+	schema      *schema.Schema               // The schema the entries are checked against
+	subschema   *ldap.Entry                  // The subentry publishing the schema
+	tlsConfig   *tls.Config                  // The configuration for LDAPS and StartTLS, nil if TLS isn't configured
+	certificate *certificateStore            // The certificate presented to TLS clients, nil if TLS isn't configured
+	mappings    []certificateMapping         // The mappings of client certificates onto entries for SASL EXTERNAL
+	indexes     map[string][]query.IndexType // The lookups wanted for each indexed attribute
+	rehashing   sync.Map                     // The normalised DNs of the entries whose passwords are being rehashed
+	rehashes    sync.WaitGroup               // The password rehashes running in the background
 }
 
 // NewServer creates a new server instance which manages a given baseDN and stores
-// user information in the specified configFile.
-func NewServer(baseDN, configFile string, port int) *Server {
-	server := &Server{baseDN: baseDN, configFile: configFile, port: port, Logger: log.Output(zerolog.ConsoleWriter{Out: os.Stderr}).Level(zerolog.InfoLevel)}
+// user information in the specified configFile. Any naming contexts in the settings are
+// served alongside the baseDN.
+func NewServer(baseDN, configFile string, port int, settings Settings) *Server {
+	server := &Server{port: port, settings: settings, Logger: log.Output(zerolog.ConsoleWriter{Out: os.Stderr}).Level(zerolog.InfoLevel)}
+	server.contexts = server.newNamingContexts(baseDN, configFile, settings.NamingContexts)
+	empty, _ := newDirectory(nil, nil)
+	server.directory.Store(empty)
+	server.access = newAccessControl(settings, server.Logger)
+	server.pages = newPager()
+	server.schema = server.newSchema(settings.Schema)
+	server.subschema = server.schema.Subentry(subschemaDN)
+	tlsConfig, certificate, err := newTLSConfig(settings.TLS)
+	if err != nil {
+		server.Logger.Error().Err(err).Msg("failed to configure tls, only unencrypted connections will be accepted")
+	}
+	server.tlsConfig, server.certificate = tlsConfig, certificate
+	server.mappings, err = compileCertificateMappings(settings.TLS.CertificateMappings)
+	if err != nil {
+		server.Logger.Error().Err(err).Msg("failed to configure certificate mappings, sasl external binds will be refused")
+	}
+	server.indexes, err = compileIndexes(settings.Indexes)
+	if err != nil {
+		server.Logger.Error().Err(err).Msg("failed to configure indexes, every search will look at every entry")
+	}
 	s := ldap.NewServer()
 	server.s = s
 
-	// register Bind and Search function handlers
-	s.BindFunc(baseDN, server)
-	s.SearchFunc(baseDN, server)
+	// register Bind, Search, Compare, Add, Modify, Delete and ModifyDN function handlers for each naming context
+	for _, nc := range server.contexts {
+		s.BindFunc(nc.baseDN, server)
+		s.SearchFunc(nc.baseDN, server)
+		s.CompareFunc(nc.baseDN, server)
+		s.AddFunc(nc.baseDN, server)
+		s.ModifyFunc(nc.baseDN, server)
+		s.DeleteFunc(nc.baseDN, server)
+		s.ModifyDNFunc(nc.baseDN, server)
+	}
+
+	// anonymous binds have an empty dn so are routed to the default handler, as are searches
+	// of the subschema subentry and base dns which differ from the suffix only by case. The
+	// other operations are routed by the dn the client is bound as.
+	s.BindFunc("", server)
+	s.SearchFunc("", server)
+	s.CompareFunc("", server)
+	s.AddFunc("", server)
+	s.ModifyFunc("", server)
+	s.DeleteFunc("", server)
+	s.ModifyDNFunc("", server)
+
+	// clean up per connection state when clients disconnect
+	s.CloseFunc("", server)
 
 	return server
 }
@@ -46,20 +103,75 @@ func (s *Server) Listen() error {
 	// start the server
 	listen := fmt.Sprintf("0.0.0.0:%d", s.port)
 	s.Logger = s.Logger.Level(zerolog.DebugLevel)
-	s.Logger.Info().Msgf("starting LDAP server on %s for %s", listen, s.baseDN)
+	s.Logger.Info().Msgf("starting LDAP server on %s for %s", listen, strings.Join(s.suffixes(), ", "))
 
-	// Start waiting for configuration changes
+	// Start waiting for configuration and certificate changes
 	go s.WatchForConfigChanges()
+	go s.WatchForCertificateChanges()
 
 	// Load the initial configuration the first time
-	s.ReloadConfiguration(s.configFile)
+	s.ReloadAll()
+
+	// Listen for LDAPS connections as well if a port has been configured for them
+	errs := make(chan error, 2)
+	if s.tlsConfig != nil && s.settings.TLS.Port != 0 {
+		listenTLS := fmt.Sprintf("0.0.0.0:%d", s.settings.TLS.Port)
+		ln, err := net.Listen("tcp", listenTLS)
+		if err != nil {
+			return err
+		}
+		s.Logger.Info().Msgf("starting LDAPS server on %s", listenTLS)
+		go func() { errs <- s.s.Serve(s.listener(tls.NewListener(ln, s.tlsConfig), true)) }()
+	}
 
 	// Listen
-	return s.s.ListenAndServe(listen)
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	go func() { errs <- s.s.Serve(s.listener(ln, false)) }()
+	return <-errs
+}
+
+// listener wraps a network listener so that the server can see the requests its connections
+// carry and start TLS on them.
+func (s *Server) listener(ln net.Listener, secure bool) listener {
+	l := listener{Listener: ln, tlsConfig: s.tlsConfig, secure: secure}
+	if len(s.saslMechanisms()) > 0 {
+		l.mappings = s.mappings
+	}
+	return l
 }
 
 // WatchForConfigChanges starts watching the configuration file for writes and applies changes automatically.
 func (s *Server) WatchForConfigChanges() {
+	// Watch the file of each naming context for changes.
+	files := make([]string, 0, len(s.contexts))
+	for _, nc := range s.contexts {
+		files = append(files, nc.configFile)
+	}
+	s.watchFiles(files, s.ReloadConfiguration)
+}
+
+// WatchForCertificateChanges starts watching the TLS certificate and key for writes and
+// switches to the new certificate automatically. Clients which are already connected keep
+// their connections, and new connections use the new certificate.
+func (s *Server) WatchForCertificateChanges() {
+	if s.certificate == nil {
+		return
+	}
+	s.watchFiles([]string{s.certificate.certFile, s.certificate.keyFile}, func(string) {
+		if err := s.certificate.load(); err != nil {
+			s.Logger.Error().Err(err).Msg("failed to reload the tls certificate, the previous certificate will be used")
+			return
+		}
+		s.Logger.Info().Msg("reloaded the tls certificate")
+	})
+}
+
+// watchFiles polls the files for changes and calls changed with the path of a file which
+// has changed. It blocks until the watcher stops.
+func (s *Server) watchFiles(files []string, changed func(path string)) {
 	// Set up the file watcher
 	w := watcher.New()
 
@@ -72,7 +184,7 @@ func (s *Server) WatchForConfigChanges() {
 		for {
 			select {
 			case event := <-w.Event:
-				s.ReloadConfiguration(event.Path)
+				changed(event.Path)
 			case err := <-w.Error:
 				s.Logger.Err(err)
 			case <-w.Closed:
@@ -80,10 +192,11 @@ func (s *Server) WatchForConfigChanges() {
 			}
 		}
 	}()
-	// Watch this file for changes.
-	if err := w.Add(s.configFile); err != nil {
-		s.Logger.Error().Err(err)
-		return
+	for _, file := range files {
+		if err := w.Add(file); err != nil {
+			s.Logger.Error().Err(err)
+			return
+		}
 	}
 
 	if err := w.Start(10 * time.Second); err != nil {
@@ -120,7 +233,7 @@ func parseUser(dn string, user map[string]interface{}, logger zerolog.Logger) (*
 
 	for name, v := range user {
 		// skip the dn key as we already know about that one.
-		if name == dn {
+		if name == "dn" {
 			continue
 		}
 
@@ -168,7 +281,8 @@ func parseUsers(users []interface{}, logger zerolog.Logger) ([]*ldap.Entry, erro
 	return entries, nil
 }
 
-// ReloadConfiguration reads the configuration file and applies the changes.
+// ReloadConfiguration reads a configuration file and applies the changes to the naming
+// contexts it backs.
 func (s *Server) ReloadConfiguration(filename string) {
 	s.Logger.Debug().Msgf("reloading configuration file '%s'", filename)
 	yamlFile, err := os.Open(filename)
@@ -176,6 +290,7 @@ func (s *Server) ReloadConfiguration(filename string) {
 		s.Logger.Error().Err(err).Msg("failed to read file")
 		return
 	}
+	defer yamlFile.Close()
 	var users []interface{}
 
 	decoder := yaml.NewDecoder(yamlFile)
@@ -193,54 +308,152 @@ func (s *Server) ReloadConfiguration(filename string) {
 		users = append(users, user)
 	}
 
+	if err := s.loadConfiguration(filename, users, nil); err != nil {
+		s.Logger.Error().Err(err).Msgf("configuration file '%s' was not loaded", filename)
+	}
+}
+
+// loadConfiguration checks the entries read from a configuration file and publishes a new
+// directory holding them. If commit is given it is called before the directory is published,
+// and the directory is only published if it succeeds.
+func (s *Server) loadConfiguration(filename string, users []interface{}, commit func() error) error {
+	contexts := s.contextsForFile(filename)
+	if len(contexts) == 0 {
+		return fmt.Errorf("configuration file '%s' does not back any naming context", filename)
+	}
+
 	// extract users
-	var entries []*ldap.Entry
+	entries, err := parseUsers(users, s.Logger)
+	if err != nil {
+		return fmt.Errorf("failed to parse users: %w", err)
+	}
+
+	if err := s.validateEntries(entries); err != nil {
+		return fmt.Errorf("configuration does not conform to the schema: %w", err)
+	}
 
-	entries, err = parseUsers(users, s.Logger)
+	assigned, err := s.assignEntries(contexts, entries)
 	if err != nil {
-		s.Logger.Error().Err(err).Msg("failed to parse users")
-		return
+		return fmt.Errorf("configuration contains entries from another naming context: %w", err)
+	}
+	withSubschemaSubentry(entries)
+	plaintext := plaintextPasswords(users)
+
+	// reloads are serialised, but requests carry on with the previous directory until the new
+	// one is published.
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	previous := make(map[*namingContext]namingContext)
+	for nc, entries := range assigned {
+		previous[nc] = *nc
+		nc.entries, nc.plaintext = entries, plaintext
 	}
 
-	if entries != nil {
-		s.lock.Lock()
-		s.entries = entries
-		defer s.lock.Unlock()
+	d, err := s.buildDirectory()
+	if err != nil {
+		err = fmt.Errorf("failed to build directory tree: %w", err)
+	} else if commit != nil {
+		err = commit()
 	}
+	if err != nil {
+		for nc, old := range previous {
+			*nc = old
+		}
+		return err
+	}
+	s.directory.Store(d)
+	return nil
 }
 
-// parsePassword parses a password string and ensures that it is in SSHA format.
-func parsePassword(password string) (string, error) {
-	if strings.HasPrefix(password, "{SSHA}") {
-		return password, nil
-	} else if strings.HasPrefix(password, "{") {
-		return "", fmt.Errorf("unsupported password encoding scheme")
-	} else {
-		encoder := SSHAEncoder{}
-		ssha, err := encoder.Encode([]byte(password))
-		if err != nil {
+// snapshot returns the directory as it is now. The directory is never changed once it has been
+// published, so it can be used without holding the lock.
+func (s *Server) snapshot() *directory {
+	return s.directory.Load().(*directory)
+}
+
+// ReloadAll reads the configuration file of every naming context.
+func (s *Server) ReloadAll() {
+	loaded := make(map[string]bool)
+	for _, nc := range s.contexts {
+		if !loaded[nc.configFile] {
+			loaded[nc.configFile] = true
+			s.ReloadConfiguration(nc.configFile)
+		}
+	}
+}
+
+// parsePassword parses a password string and ensures that it is stored with a known scheme.
+// Passwords without a scheme are encoded with {SSHA}, and hashes which would take too much
+// work to check are refused.
+func parsePassword(pwd string) (string, error) {
+	if scheme, ok := password.Scheme([]byte(pwd)); ok {
+		if _, ok := password.Identify([]byte(pwd)); !ok {
+			return "", fmt.Errorf("unsupported password encoding scheme '%s'", scheme)
+		}
+		if err := password.CheckCost([]byte(pwd)); err != nil {
 			return "", err
-		} else {
-			return string(ssha), nil
 		}
+		return pwd, nil
+	}
+
+	encoder, _ := password.Lookup("SSHA")
+	ssha, err := encoder.Encode([]byte(pwd))
+	if err != nil {
+		return "", err
 	}
+	return string(ssha), nil
 }
 
 // Bind is a handler for an incoming bind request.
 func (s *Server) Bind(bindDN, bindSimplePw string, conn net.Conn) (ldap.LDAPResultCode, error) {
 	logger := s.Logger.With().Str("operation", "bind").Str("request_ip", conn.RemoteAddr().String()).Str("bindDN", bindDN).Logger()
 	logger.Debug().Msgf("request received")
-	for _, entry := range s.entries {
-		if entry.DN == bindDN {
-			encoder := SSHAEncoder{}
-			pwd := entry.GetAttributeValue("userPassword")
-			if encoder.Matches([]byte(pwd), []byte(bindSimplePw)) {
+
+	// sasl external binds reach here as simple binds once the client certificate has been mapped
+	if bind, ok := takeExternalBind(conn); ok {
+		return s.bindExternal(bind, logger)
+	}
+
+	// an empty dn and password is an anonymous bind (RFC 4513 section 5.1.1)
+	if bindDN == "" && bindSimplePw == "" {
+		if s.settings.DisableAnonymous {
+			logger.Error().Msgf("anonymous bind request was rejected because anonymous access is disabled")
+			return ldap.LDAPResultUnwillingToPerform, nil
+		}
+		logger.Debug().Msgf("anonymous bind request was accepted")
+		return ldap.LDAPResultSuccess, nil
+	}
+
+	// a dn without a password is an unauthenticated bind which must not be treated as a success (RFC 4513 section 5.1.2)
+	if bindSimplePw == "" {
+		logger.Error().Msgf("bind request was rejected because no password was provided")
+		return ldap.LDAPResultUnwillingToPerform, nil
+	}
+
+	// passwords must not cross the network in the clear if TLS is required (RFC 4513 section 5.1.3)
+	if s.settings.TLS.RequireForBind && !isSecure(conn) {
+		logger.Error().Msgf("bind request was rejected because the connection is not encrypted")
+		return ldap.LDAPResultConfidentialityRequired, nil
+	}
+
+	if entry := s.snapshot().find(bindDN); entry != nil {
+		pwds := entry.GetAttributeValues(passwordAttribute)
+		if len(pwds) == 0 || !s.access.allows("", entry, passwordAttribute, accessAuth) {
+			logger.Error().Msgf("bind request was rejected because the entry may not be used to authenticate")
+			return ldap.LDAPResultInvalidCredentials, nil
+		}
+		// the scheme of each password picks the encoder which checks it
+		for _, pwd := range pwds {
+			if password.Matches([]byte(pwd), []byte(bindSimplePw)) {
 				logger.Debug().Msgf("bind request was accepted")
+				if s.needsRehash(entry.DN, pwd) {
+					s.rehashInBackground(entry.DN, bindSimplePw, logger)
+				}
 				return ldap.LDAPResultSuccess, nil
 			}
-			logger.Error().Msgf("bind request was rejected because of an invalid password")
-			return ldap.LDAPResultInvalidCredentials, nil
 		}
+		logger.Error().Msgf("bind request was rejected because of an invalid password")
+		return ldap.LDAPResultInvalidCredentials, nil
 	}
 
 	logger.Error().Msgf("bind request was rejected because the dn does not exist")
@@ -251,24 +464,125 @@ func (s *Server) Bind(bindDN, bindSimplePw string, conn net.Conn) (ldap.LDAPResu
 func (s *Server) Search(boundDN string, searchReq ldap.SearchRequest, conn net.Conn) (ldap.ServerSearchResult, error) {
 	logger := s.Logger.With().Str("operation", "search").Str("request_ip", conn.RemoteAddr().String()).Str("bindDN", boundDN).Logger()
 
+	if boundDN == "" && s.settings.DisableAnonymous && !isRootDSESearch(searchReq) {
+		logger.Error().Msgf("search request was rejected because anonymous access is disabled")
+		return s.respond(conn, ldap.ServerSearchResult{Entries: []*ldap.Entry{}, Referrals: []string{}, Controls: []ldap.Control{}, ResultCode: ldap.LDAPResultInsufficientAccessRights})
+	}
+
 	// Parse the search query
-	logger.Debug().Msgf("beginning search with query: %s", searchReq.Filter)
-	q, _, err := query.Parse(searchReq.Filter, 0)
+	q, err := searchFilter(searchReq, conn)
 	if err != nil {
 		logger.Error().Err(err).Msgf("the client submitted an invalid query: %s", searchReq.Filter)
-		return ldap.ServerSearchResult{Entries: nil, Referrals: nil, Controls: nil, ResultCode: ldap.LDAPResultUnwillingToPerform}, nil
+		return s.respond(conn, ldap.ServerSearchResult{Entries: nil, Referrals: nil, Controls: nil, ResultCode: ldap.LDAPResultUnwillingToPerform})
+	}
+	searchReq.Filter = q.ToString()
+
+	if isRootDSESearch(searchReq) {
+		return s.searchRootDSE(searchReq, q, conn, logger)
+	}
+
+	// Continue a paged search if the client sent a cookie
+	paging := pagingControl(searchReq.Controls)
+	if paging != nil && len(paging.Cookie) > 0 {
+		logger.Debug().Msgf("continuing paged search with query: %s", searchReq.Filter)
+		return s.respond(conn, s.nextPage(boundDN, searchReq, paging, conn))
+	}
+	logger.Debug().Msgf("beginning search with query: %s", searchReq.Filter)
+
+	// Find the base object of the search in the directory as it is now, the whole search sees
+	// the same version of the directory even if it is reloaded in the meantime
+	d := s.snapshot()
+	base := d.lookup(searchReq.BaseDN)
+	if base == nil {
+		logger.Debug().Msgf("the base dn '%s' does not exist", searchReq.BaseDN)
+		return s.respond(conn, ldap.ServerSearchResult{Entries: []*ldap.Entry{}, Referrals: []string{}, Controls: []ldap.Control{}, ResultCode: ldap.LDAPResultNoSuchObject})
+	}
+
+	// An unsupported sort request only fails the search if the client marked it as critical (RFC 2891)
+	sorting := sortControl(searchReq.Controls)
+	if sorting != nil && sorting.result != ldap.LDAPResultSuccess && sorting.critical {
+		logger.Error().Msgf("search request was rejected because the results can't be sorted")
+		return s.respond(conn, ldap.ServerSearchResult{Entries: []*ldap.Entry{}, Referrals: []string{}, Controls: []ldap.Control{sorting.response()}, ResultCode: ldap.LDAPResultUnavailableCriticalExtension})
 	}
+	sorted := sorting != nil && sorting.result == ldap.LDAPResultSuccess
+
+	limits := s.newSearchLimits(boundDN, searchReq)
+	code := ldap.LDAPResultCode(ldap.LDAPResultSuccess)
 
 	var result = make([]*ldap.Entry, 0)
-	for _, entry := range s.entries {
-		if q.Evaluate(entry) {
+	entries, indexed := d.candidates(base, searchReq.Scope, q)
+	if indexed {
+		logger.Debug().Msgf("the indexes narrowed the search down to %d entries", len(entries))
+	}
+	for _, entry := range entries {
+		if limits.expired() {
+			logger.Debug().Msgf("search exceeded the time limit")
+			code = ldap.LDAPResultTimeLimitExceeded
+			break
+		}
+
+		// only evaluate the query against the parts of the entry the client may search.
+		searchable, readable := s.access.view(boundDN, entry)
+		if searchable == nil {
+			continue
+		}
+		if q.Evaluate(searchable) {
+			// sorted results need every match before the size limit can be applied.
+			if !sorted && limits.full(len(result)) {
+				logger.Debug().Msgf("search exceeded the size limit")
+				code = ldap.LDAPResultSizeLimitExceeded
+				break
+			}
 			logger.Debug().Msgf("dn '%s' matches search criteria", entry.DN)
-			result = append(result, entry)
+			result = append(result, readable)
+		}
+	}
+
+	// sort on the readable values so the order doesn't reveal attributes the client can't see.
+	if sorted {
+		sortEntries(result, sorting.keys)
+		if limits.size > 0 && len(result) > limits.size {
+			logger.Debug().Msgf("search exceeded the size limit")
+			code = ldap.LDAPResultSizeLimitExceeded
+			result = result[:limits.size]
 		}
 	}
 	logger.Debug().Msgf("search completed with %d results", len(result))
 
-	return ldap.ServerSearchResult{Entries: result, Referrals: []string{}, Controls: []ldap.Control{}, ResultCode: ldap.LDAPResultSuccess}, nil
+	for i, entry := range result {
+		result[i] = selectAttributes(entry, searchReq.Attributes, searchReq.TypesOnly)
+	}
+
+	controls := []ldap.Control{}
+	if sorting != nil {
+		controls = append(controls, sorting.response())
+	}
+
+	if paging != nil {
+		return s.respond(conn, s.firstPage(boundDN, searchReq, paging, result, code, controls, conn))
+	}
+	return s.respond(conn, ldap.ServerSearchResult{Entries: result, Referrals: []string{}, Controls: controls, ResultCode: code})
+}
+
+// searchFilter returns the filter of the search request. The filter the client sent is used
+// when the request arrived over a wrapped connection, otherwise the string form is parsed.
+func searchFilter(searchReq ldap.SearchRequest, c net.Conn) (query.Evaluator, error) {
+	if wrapped, ok := c.(*conn); ok {
+		if filter := wrapped.takeFilter(); filter != nil {
+			return query.ParsePacket(filter)
+		}
+	}
+	q, _, err := query.Parse(searchReq.Filter, 0)
+	return q, err
+}
+
+// respond records the result of a search on the connection so that the result code
+// reaches the client, and returns it to the ldap library.
+func (s *Server) respond(c net.Conn, result ldap.ServerSearchResult) (ldap.ServerSearchResult, error) {
+	if wrapped, ok := c.(*conn); ok {
+		wrapped.setSearchResult(result)
+	}
+	return result, nil
 }
 
 func (s *Server) SearchInternal(queryStr string) ([]*ldap.Entry, error) {
@@ -279,7 +593,7 @@ func (s *Server) SearchInternal(queryStr string) ([]*ldap.Entry, error) {
 	}
 
 	var result = make([]*ldap.Entry, 0)
-	for _, entry := range s.entries {
+	for _, entry := range s.snapshot().entries {
 		if q.Evaluate(entry) {
 			result = append(result, entry)
 		}
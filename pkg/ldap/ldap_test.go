@@ -0,0 +1,190 @@
+package ldap
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+	"github.com/rs/zerolog"
+)
+
+const testConfig = `
+dn: dc=home,dc=lab
+dc: home
+objectClass: domain
+---
+dn: ou=users,dc=home,dc=lab
+ou: users
+objectClass: organizationalUnit
+---
+cn: user
+dn: cn=user,ou=users,dc=home,dc=lab
+uid: user
+objectClass:
+  - "posixAccount"
+  - "inetOrgPerson"
+sn: User
+userPassword: "{SSHA}I8wq1+4gyJVJUtQW96JGcmCL46ADyPnW"
+---
+cn: root
+uid: root
+dn: cn=root,dc=home,dc=lab
+userPassword: "{SSHA}I8wq1+4gyJVJUtQW96JGcmCL46ADyPnW"
+objectClass: "posixAccount"
+`
+
+// writeConfig writes the configuration to a temporary file and returns its name.
+func writeConfig(t *testing.T, config string) string {
+	f, err := ioutil.TempFile("", "dapper-*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(f.Name()) })
+
+	if _, err := f.WriteString(config); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	return f.Name()
+}
+
+// writeFile replaces the contents of a file.
+func writeFile(t *testing.T, filename, contents string) {
+	if err := ioutil.WriteFile(filename, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readFile returns the contents of a file.
+func readFile(t *testing.T, filename string) string {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(contents)
+}
+
+// newTestServer creates a server loaded with the given configuration and settings.
+func newTestServer(t *testing.T, config string, settings Settings) *Server {
+	filename := writeConfig(t, config)
+	s := NewServer("dc=home,dc=lab", filename, 0, settings)
+	s.Logger = zerolog.Nop()
+	s.ReloadConfiguration(filename)
+	return s
+}
+
+// testConn returns one end of an in-memory connection.
+func testConn(t *testing.T) net.Conn {
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return server
+}
+
+// dns extracts the DNs of the entries in a search result.
+func dns(result ldap.ServerSearchResult) []string {
+	out := make([]string, 0)
+	for _, entry := range result.Entries {
+		out = append(out, entry.DN)
+	}
+	return out
+}
+
+func TestSearchScopes(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, testConfig, DefaultSettings())
+	conn := testConn(t)
+
+	result, err := s.Search("", ldap.SearchRequest{BaseDN: "ou=users,dc=home,dc=lab", Scope: ldap.ScopeSingleLevel, Filter: "(objectClass=*)"}, conn)
+	Ω(err).Should(gomega.BeNil())
+	Ω(result.ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(dns(result)).Should(gomega.Equal([]string{"cn=user,ou=users,dc=home,dc=lab"}))
+
+	result, _ = s.Search("", ldap.SearchRequest{BaseDN: "dc=home,dc=lab", Scope: ldap.ScopeSingleLevel, Filter: "(objectClass=*)"}, conn)
+	Ω(dns(result)).Should(gomega.ConsistOf("ou=users,dc=home,dc=lab", "cn=root,dc=home,dc=lab"))
+
+	result, _ = s.Search("", ldap.SearchRequest{BaseDN: "DC=Home,DC=Lab", Scope: ldap.ScopeBaseObject, Filter: "(objectClass=*)"}, conn)
+	Ω(dns(result)).Should(gomega.Equal([]string{"dc=home,dc=lab"}))
+
+	result, _ = s.Search("", ldap.SearchRequest{BaseDN: "dc=home,dc=lab", Scope: ldap.ScopeWholeSubtree, Filter: "(objectClass=*)"}, conn)
+	Ω(len(result.Entries)).Should(gomega.Equal(4))
+}
+
+func TestSearchNoSuchObject(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, testConfig, DefaultSettings())
+
+	result, err := s.Search("", ldap.SearchRequest{BaseDN: "ou=groups,dc=home,dc=lab", Scope: ldap.ScopeWholeSubtree, Filter: "(objectClass=*)"}, testConn(t))
+	Ω(err).Should(gomega.BeNil())
+	Ω(result.ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultNoSuchObject)))
+	Ω(result.Entries).Should(gomega.BeEmpty())
+
+	// glue above the suffix isn't held by the server, glue below it is.
+	s = newTestServer(t, testConfig+"---\ndn: cn=printer,ou=devices,dc=home,dc=lab\ncn: printer\nobjectClass: device\n", DefaultSettings())
+	result, _ = s.Search("", ldap.SearchRequest{BaseDN: "dc=lab", Scope: ldap.ScopeWholeSubtree, Filter: "(objectClass=*)"}, testConn(t))
+	Ω(result.ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultNoSuchObject)))
+	Ω(result.Entries).Should(gomega.BeEmpty())
+	result, _ = s.Search("", ldap.SearchRequest{BaseDN: "ou=devices,dc=home,dc=lab", Scope: ldap.ScopeSingleLevel, Filter: "(objectClass=*)"}, testConn(t))
+	Ω(result.ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(dns(result)).Should(gomega.Equal([]string{"cn=printer,ou=devices,dc=home,dc=lab"}))
+}
+
+func TestBindPasswordSchemes(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, testConfig+`---
+cn: migrated
+dn: cn=migrated,dc=home,dc=lab
+objectClass: "posixAccount"
+userPassword:
+  - "{SSHA512}aCu7JRc+kLsuEmFs1zTY+AiP7DSGnjjG+dH28Dp+E5usqoAixeTPihKqZmkWal4mUfp63tqvCAkFV1LKTDFH6XNhbHRzYWx0"
+  - "{CRYPT}$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1"
+  - "{BCRYPT}$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW"
+  - "{MD5}Xr4ilOzQ4PCOq3aQ0qbuaQ=="
+---
+cn: plain
+dn: cn=plain,dc=home,dc=lab
+objectClass: "posixAccount"
+userPassword: "plaintext"
+`, DefaultSettings())
+
+	// each of the passwords can be used and is checked by the scheme it names.
+	for _, pwd := range []string{"secret", "Hello world!", "U*U"} {
+		code, _ := s.Bind("cn=migrated,dc=home,dc=lab", pwd, testConn(t))
+		Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)), pwd)
+	}
+	code, _ := s.Bind("cn=migrated,dc=home,dc=lab", "{MD5}Xr4ilOzQ4PCOq3aQ0qbuaQ==", testConn(t))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultInvalidCredentials)))
+
+	// passwords without a scheme are stored as {SSHA}.
+	entry := s.snapshot().find("cn=plain,dc=home,dc=lab")
+	Ω(entry.GetAttributeValue("userPassword")).Should(gomega.HavePrefix("{SSHA}"))
+	code, _ = s.Bind("cn=plain,dc=home,dc=lab", "plaintext", testConn(t))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+}
+
+func TestParsePassword(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	pwd, err := parsePassword("{ARGON2}$argon2id$v=19$m=64,t=1,p=1$c2FsdA$a2V5")
+	Ω(err).Should(gomega.BeNil())
+	Ω(pwd).Should(gomega.Equal("{ARGON2}$argon2id$v=19$m=64,t=1,p=1$c2FsdA$a2V5"))
+
+	_, err = parsePassword("{MD5}Xr4ilOzQ4PCOq3aQ0qbuaQ==")
+	Ω(err).Should(gomega.MatchError("unsupported password encoding scheme 'MD5'"))
+}
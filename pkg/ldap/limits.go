@@ -0,0 +1,55 @@
+package ldap
+
+import (
+	"time"
+
+	"github.com/nmcclain/ldap"
+)
+
+// Limits restricts how much work a single search is allowed to do. Clients may ask for
+// lower limits but cannot raise them. Admins are not subject to the server limits.
+type Limits struct {
+	SizeLimit int `yaml:"sizeLimit"` // The maximum number of entries returned by a search, 0 for no limit
+	TimeLimit int `yaml:"timeLimit"` // The maximum number of seconds a search may take, 0 for no limit
+}
+
+// effectiveLimit combines the limit requested by a client with the server maximum, where 0 means no limit.
+func effectiveLimit(requested, maximum int) int {
+	if requested <= 0 {
+		return maximum
+	}
+	if maximum <= 0 || requested < maximum {
+		return requested
+	}
+	return maximum
+}
+
+// searchLimits tracks the size and time limits of a single search.
+type searchLimits struct {
+	size     int       // The maximum number of entries to return, 0 for no limit
+	deadline time.Time // The time at which the search must stop, zero for no limit
+}
+
+// newSearchLimits works out the limits which apply to a search made by the bound DN.
+func (s *Server) newSearchLimits(boundDN string, searchReq ldap.SearchRequest) searchLimits {
+	maximum := s.settings.Limits
+	if s.access.isAdmin(boundDN) {
+		maximum = Limits{}
+	}
+
+	limits := searchLimits{size: effectiveLimit(searchReq.SizeLimit, maximum.SizeLimit)}
+	if seconds := effectiveLimit(searchReq.TimeLimit, maximum.TimeLimit); seconds > 0 {
+		limits.deadline = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	return limits
+}
+
+// expired returns true if the search has run out of time.
+func (l searchLimits) expired() bool {
+	return !l.deadline.IsZero() && time.Now().After(l.deadline)
+}
+
+// full returns true if the search has already found as many entries as it may return.
+func (l searchLimits) full(found int) bool {
+	return l.size > 0 && found >= l.size
+}
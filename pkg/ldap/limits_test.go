@@ -0,0 +1,67 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+)
+
+func TestEffectiveLimit(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	Ω(effectiveLimit(0, 0)).Should(gomega.Equal(0))
+	Ω(effectiveLimit(5, 0)).Should(gomega.Equal(5))
+	Ω(effectiveLimit(0, 10)).Should(gomega.Equal(10))
+	Ω(effectiveLimit(5, 10)).Should(gomega.Equal(5))
+	Ω(effectiveLimit(50, 10)).Should(gomega.Equal(10))
+}
+
+func TestSearchSizeLimit(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	settings := DefaultSettings()
+	settings.AdminDNs = []string{"cn=root,dc=home,dc=lab"}
+	settings.Limits = Limits{SizeLimit: 2}
+	s := newTestServer(t, testConfig, settings)
+
+	request := ldap.SearchRequest{BaseDN: "dc=home,dc=lab", Scope: ldap.ScopeWholeSubtree, Filter: "(objectClass=*)", SizeLimit: 1}
+	result, err := s.Search("", request, testConn(t))
+	Ω(err).Should(gomega.BeNil())
+	Ω(result.ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSizeLimitExceeded)))
+	Ω(result.Entries).Should(gomega.HaveLen(1))
+
+	// the server maximum caps what the client asks for.
+	request.SizeLimit = 100
+	result, _ = s.Search("", request, testConn(t))
+	Ω(result.ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSizeLimitExceeded)))
+	Ω(result.Entries).Should(gomega.HaveLen(2))
+
+	// admins are not subject to the server maximum.
+	result, _ = s.Search("cn=root,dc=home,dc=lab", request, testConn(t))
+	Ω(result.ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(result.Entries).Should(gomega.HaveLen(4))
+
+	// a limit equal to the number of results is not exceeded.
+	request.SizeLimit = 1
+	request.Filter = "(uid=user)"
+	result, _ = s.Search("", request, testConn(t))
+	Ω(result.ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+}
+
+func TestSearchSizeLimitReachesClient(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	client := serve(t, newTestServer(t, testConfig, DefaultSettings()))
+
+	result, err := client.Search(ldap.NewSearchRequest("dc=home,dc=lab", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false, "(objectClass=*)", nil, nil))
+	Ω(err).ShouldNot(gomega.BeNil())
+	Ω(err.(*ldap.Error).ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSizeLimitExceeded)))
+	Ω(result.Entries).Should(gomega.HaveLen(2))
+}
@@ -0,0 +1,146 @@
+package ldap
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/nmcclain/ldap"
+)
+
+// maxPagedSearches is the maximum number of unfinished paged searches a single connection may have.
+const maxPagedSearches = 16
+
+// pagedSearch is a paged search (RFC 2696) which the client has not finished reading yet.
+type pagedSearch struct {
+	boundDN  string              // The DN the client was bound as when the search started
+	request  string              // A fingerprint of the search request the cookie belongs to
+	entries  []*ldap.Entry       // The entries which have not been sent yet
+	code     ldap.LDAPResultCode // The result code to send with the final page
+	total    int                 // The total number of entries in the search result
+	controls []ldap.Control      // The other response controls sent with every page, such as the sort result
+}
+
+// pager holds the state of the unfinished paged searches for every connection.
+type pager struct {
+	lock     sync.Mutex                           // A lock protecting the searches
+	searches map[net.Conn]map[string]*pagedSearch // The unfinished searches keyed by connection and cookie
+}
+
+// newPager creates an empty pager.
+func newPager() *pager {
+	return &pager{searches: make(map[net.Conn]map[string]*pagedSearch)}
+}
+
+// store saves an unfinished search for the connection and returns the cookie that identifies it.
+func (p *pager) store(conn net.Conn, search *pagedSearch) (string, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	searches, ok := p.searches[conn]
+	if !ok {
+		searches = make(map[string]*pagedSearch)
+		p.searches[conn] = searches
+	}
+	if len(searches) >= maxPagedSearches {
+		return "", fmt.Errorf("too many unfinished paged searches on this connection")
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	cookie := hex.EncodeToString(b)
+	searches[cookie] = search
+	return cookie, nil
+}
+
+// take removes and returns the unfinished search identified by the cookie.
+func (p *pager) take(conn net.Conn, cookie string) *pagedSearch {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	search := p.searches[conn][cookie]
+	delete(p.searches[conn], cookie)
+	return search
+}
+
+// forget discards every unfinished search for the connection.
+func (p *pager) forget(conn net.Conn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.searches, conn)
+}
+
+// pagingControl returns the paged results control from the request, or nil if there isn't one.
+func pagingControl(controls []ldap.Control) *ldap.ControlPaging {
+	if c, ok := ldap.FindControl(controls, ldap.ControlTypePaging).(*ldap.ControlPaging); ok {
+		return c
+	}
+	return nil
+}
+
+// fingerprint identifies a search request so that a cookie can only be used to continue the
+// search it was issued for.
+func fingerprint(searchReq ldap.SearchRequest) string {
+	return fmt.Sprintf("%s|%d|%t|%s|%s", strings.ToLower(searchReq.BaseDN), searchReq.Scope, searchReq.TypesOnly, searchReq.Filter, strings.ToLower(strings.Join(searchReq.Attributes, ",")))
+}
+
+// firstPage returns the first page of a search result and saves the rest of the result so
+// the client can ask for it with the cookie returned in the response control. The other
+// response controls are saved too, as they describe the whole result and go with every page.
+func (s *Server) firstPage(boundDN string, searchReq ldap.SearchRequest, paging *ldap.ControlPaging, entries []*ldap.Entry, code ldap.LDAPResultCode, controls []ldap.Control, conn net.Conn) ldap.ServerSearchResult {
+	search := &pagedSearch{boundDN: boundDN, request: fingerprint(searchReq), entries: entries, code: code, total: len(entries), controls: controls}
+
+	// a page size of zero asks for no entries, only the size estimate.
+	if paging.PagingSize == 0 {
+		return pageResult([]*ldap.Entry{}, "", search.total, ldap.LDAPResultSuccess, search.controls)
+	}
+	return s.sendPage(search, paging.PagingSize, conn)
+}
+
+// nextPage continues a paged search using the cookie in the request control.
+func (s *Server) nextPage(boundDN string, searchReq ldap.SearchRequest, paging *ldap.ControlPaging, conn net.Conn) ldap.ServerSearchResult {
+	search := s.pages.take(conn, string(paging.Cookie))
+	if search == nil || search.request != fingerprint(searchReq) || search.boundDN != boundDN {
+		return pageResult([]*ldap.Entry{}, "", 0, ldap.LDAPResultUnwillingToPerform, nil)
+	}
+
+	// a page size of zero with a cookie abandons the search.
+	if paging.PagingSize == 0 {
+		return pageResult([]*ldap.Entry{}, "", search.total, ldap.LDAPResultSuccess, search.controls)
+	}
+	return s.sendPage(search, paging.PagingSize, conn)
+}
+
+// sendPage returns the next page of the search, saving the remainder if there is any.
+func (s *Server) sendPage(search *pagedSearch, size uint32, conn net.Conn) ldap.ServerSearchResult {
+	if int(size) >= len(search.entries) {
+		return pageResult(search.entries, "", search.total, search.code, search.controls)
+	}
+
+	page := search.entries[:size]
+	search.entries = search.entries[size:]
+	cookie, err := s.pages.store(conn, search)
+	if err != nil {
+		return pageResult([]*ldap.Entry{}, "", search.total, ldap.LDAPResultAdminLimitExceeded, search.controls)
+	}
+	return pageResult(page, cookie, search.total, ldap.LDAPResultSuccess, search.controls)
+}
+
+// pageResult builds a search result carrying a paged results response control along with
+// the other response controls of the search.
+func pageResult(entries []*ldap.Entry, cookie string, total int, code ldap.LDAPResultCode, controls []ldap.Control) ldap.ServerSearchResult {
+	control := ldap.NewControlPaging(uint32(total))
+	control.SetCookie([]byte(cookie))
+	return ldap.ServerSearchResult{Entries: entries, Referrals: []string{}, Controls: append([]ldap.Control{control}, controls...), ResultCode: code}
+}
+
+// Close is a handler called when a client connection is closed.
+func (s *Server) Close(boundDN string, conn net.Conn) error {
+	s.pages.forget(conn)
+	return nil
+}
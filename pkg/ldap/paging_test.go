@@ -0,0 +1,68 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+)
+
+func TestPagedSearch(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, testConfig, DefaultSettings())
+	conn := testConn(t)
+
+	paging := ldap.NewControlPaging(3)
+	request := ldap.SearchRequest{BaseDN: "dc=home,dc=lab", Scope: ldap.ScopeWholeSubtree, Filter: "(objectClass=*)", Controls: []ldap.Control{paging}}
+
+	result, err := s.Search("", request, conn)
+	Ω(err).Should(gomega.BeNil())
+	Ω(result.Entries).Should(gomega.HaveLen(3))
+	response := pagingControl(result.Controls)
+	Ω(response).ShouldNot(gomega.BeNil())
+	Ω(response.PagingSize).Should(gomega.Equal(uint32(4)))
+	Ω(response.Cookie).ShouldNot(gomega.BeEmpty())
+
+	// the cookie cannot be used for a different search.
+	paging.SetCookie(response.Cookie)
+	other := request
+	other.Filter = "(uid=*)"
+	result, _ = s.Search("", other, conn)
+	Ω(result.ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultUnwillingToPerform)))
+
+	// cookies are single use, so start again and fetch the remaining page.
+	paging.SetCookie(nil)
+	result, _ = s.Search("", request, conn)
+	paging.SetCookie(pagingControl(result.Controls).Cookie)
+	result, _ = s.Search("", request, conn)
+	Ω(result.ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(result.Entries).Should(gomega.HaveLen(1))
+	Ω(pagingControl(result.Controls).Cookie).Should(gomega.BeEmpty())
+
+	// cookies belong to the connection they were issued on.
+	paging.SetCookie(nil)
+	result, _ = s.Search("", request, conn)
+	paging.SetCookie(pagingControl(result.Controls).Cookie)
+	result, _ = s.Search("", request, testConn(t))
+	Ω(result.ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultUnwillingToPerform)))
+
+	// closing the connection discards its searches.
+	s.Close("", conn)
+	Ω(s.pages.searches).Should(gomega.BeEmpty())
+}
+
+func TestPagedSearchReachesClient(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	client := serve(t, newTestServer(t, testConfig, DefaultSettings()))
+
+	result, err := client.SearchWithPaging(ldap.NewSearchRequest("dc=home,dc=lab", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil), 1)
+	Ω(err).Should(gomega.BeNil())
+	Ω(result.Entries).Should(gomega.HaveLen(4))
+	Ω(result.Controls).Should(gomega.HaveLen(4))
+}
@@ -0,0 +1,153 @@
+package ldap
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// readDocuments reads every entry in a configuration file, keeping the attributes in the order
+// they were written so that the file can be written back without reordering it.
+func readDocuments(filename string) ([]yaml.MapSlice, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	documents := make([]yaml.MapSlice, 0)
+	decoder := yaml.NewDecoder(f)
+	for {
+		var document yaml.MapSlice
+		if err := decoder.Decode(&document); err != nil {
+			if err == io.EOF {
+				return documents, nil
+			}
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+}
+
+// hasComments returns true if the yaml has any comments, which are lost when the entries are
+// written back. It errs on the side of finding a comment, so a '#' in a block scalar or in a
+// quoted value spanning several lines is taken to start one.
+func hasComments(data []byte) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		quote := byte(0)
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			switch {
+			case quote == '"' && c == '\\':
+				i++
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+			case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+				return true
+			case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" \t:-[{,", line[i-1]) >= 0):
+				quote = c
+			}
+		}
+	}
+	return false
+}
+
+// fileHasComments returns true if the configuration file has any comments.
+func fileHasComments(filename string) (bool, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return false, err
+	}
+	return hasComments(data), nil
+}
+
+// writeDocuments replaces the configuration file with the entries. The entries are written to
+// a temporary file in the same directory which is then renamed over the configuration file, so
+// the file is never seen half written, either by the server or by anyone else reading it.
+func writeDocuments(filename string, documents []yaml.MapSlice) error {
+	buf := &bytes.Buffer{}
+	for i, document := range documents {
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		out, err := yaml.Marshal(document)
+		if err != nil {
+			return err
+		}
+		buf.Write(out)
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// updateDocuments reads the configuration file, lets update change the entries and writes the
+// file back if update reports that it changed anything. Writes to the configuration files are
+// serialised so that concurrent updates don't overwrite each other.
+func (s *Server) updateDocuments(filename string, update func(documents []yaml.MapSlice) bool) (bool, error) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	documents, err := readDocuments(filename)
+	if err != nil {
+		return false, err
+	}
+	if !update(documents) {
+		return false, nil
+	}
+	return true, writeDocuments(filename, documents)
+}
+
+// documentDN returns the dn of an entry read by readDocuments.
+func documentDN(document yaml.MapSlice) (string, bool) {
+	for _, item := range document {
+		if key, ok := item.Key.(string); ok && key == "dn" {
+			name, ok := item.Value.(string)
+			return name, ok
+		}
+	}
+	return "", false
+}
+
+// setDocumentDN changes the dn of an entry read by readDocuments, keeping its place in the entry.
+func setDocumentDN(document yaml.MapSlice, name string) yaml.MapSlice {
+	out := make(yaml.MapSlice, 0, len(document))
+	for _, item := range document {
+		if key, ok := item.Key.(string); ok && key == "dn" {
+			item.Value = name
+		}
+		out = append(out, item)
+	}
+	return out
+}
@@ -0,0 +1,85 @@
+package ldap
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+)
+
+func TestWriteDocuments(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	filename := writeConfig(t, testConfig)
+	Ω(os.Chmod(filename, 0640)).Should(gomega.Succeed())
+
+	documents, err := readDocuments(filename)
+	Ω(err).Should(gomega.BeNil())
+	Ω(documents).Should(gomega.HaveLen(4))
+	name, ok := documentDN(documents[2])
+	Ω(ok).Should(gomega.BeTrue())
+	Ω(name).Should(gomega.Equal("cn=user,ou=users,dc=home,dc=lab"))
+
+	// the attributes keep their order and the file keeps its permissions.
+	documents[3] = append(documents[3], yaml.MapItem{Key: "description", Value: "changed"})
+	Ω(writeDocuments(filename, documents)).Should(gomega.Succeed())
+	Ω(readFile(t, filename)).Should(gomega.HavePrefix("dn: dc=home,dc=lab\ndc: home\nobjectClass: domain\n---\n"))
+	Ω(readFile(t, filename)).Should(gomega.HaveSuffix("objectClass: posixAccount\ndescription: changed\n"))
+	info, err := os.Stat(filename)
+	Ω(err).Should(gomega.BeNil())
+	Ω(info.Mode().Perm()).Should(gomega.Equal(os.FileMode(0640)))
+
+	// the temporary file is renamed over the configuration file.
+	others, err := filepath.Glob(filepath.Join(filepath.Dir(filename), "."+filepath.Base(filename)+".*"))
+	Ω(err).Should(gomega.BeNil())
+	Ω(others).Should(gomega.BeEmpty())
+
+	// what is written can be read back by the server.
+	s := newTestServer(t, readFile(t, filename), DefaultSettings())
+	Ω(s.snapshot().find("cn=root,dc=home,dc=lab").GetAttributeValue("description")).Should(gomega.Equal("changed"))
+}
+
+func TestUpdateDocuments(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, testConfig, DefaultSettings())
+	filename := s.contexts[0].configFile
+
+	// the file is left alone if nothing changes.
+	before, err := ioutil.ReadFile(filename)
+	Ω(err).Should(gomega.BeNil())
+	changed, err := s.updateDocuments(filename, func([]yaml.MapSlice) bool { return false })
+	Ω(err).Should(gomega.BeNil())
+	Ω(changed).Should(gomega.BeFalse())
+	Ω(readFile(t, filename)).Should(gomega.Equal(string(before)))
+
+	_, err = s.updateDocuments(filepath.Join(filepath.Dir(filename), "missing.yaml"), func([]yaml.MapSlice) bool { return true })
+	Ω(err).ShouldNot(gomega.BeNil())
+}
+
+func TestHasComments(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	for config, expected := range map[string]bool{
+		testConfig:                                         false,
+		"# a comment\ncn: root\n":                          true,
+		"cn: root # a comment\n":                           true,
+		"objectClass:\n  - top\n\t# a comment\n":           true,
+		"uniqueMember: cn=user,dc=home#'0101'B\n":          false,
+		"description: \"#1 fan\"\n":                        false,
+		"description: \"say \\\"hi\\\" #1\"\n":             false,
+		"description: \"say \\\"hi\\\" #1\" # a comment\n": true,
+		"description: John's #1 fan\n":                     true,
+	} {
+		Ω(hasComments([]byte(config))).Should(gomega.Equal(expected), config)
+	}
+}
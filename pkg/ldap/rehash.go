@@ -0,0 +1,176 @@
+package ldap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/shauncampbell/dapper/pkg/dn"
+	"github.com/shauncampbell/dapper/pkg/password"
+	"gopkg.in/yaml.v2"
+)
+
+// checkPasswordScheme makes sure that passwords can be rehashed with the scheme.
+func checkPasswordScheme(scheme string) error {
+	if scheme == "" {
+		return nil
+	}
+	if _, ok := password.Lookup(scheme); !ok {
+		return fmt.Errorf("password scheme '%s' is not supported, use one of %s", scheme, strings.Join(password.Schemes(), ", "))
+	}
+	return nil
+}
+
+// plaintextPasswords returns the normalised DNs of the entries read from a configuration file
+// which have a password without a scheme. Those passwords are hashed when they are read, so
+// the entries look no different from ones whose passwords are hashed in the file.
+func plaintextPasswords(users []interface{}) map[string]bool {
+	out := make(map[string]bool)
+	for _, user := range users {
+		u, ok := user.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, ok := u["dn"].(string)
+		if !ok {
+			continue
+		}
+		values, _ := parseAttributeValues(u[passwordAttribute])
+		for _, v := range values {
+			if _, ok := password.Scheme([]byte(v)); !ok {
+				if key, err := dn.Normalize(name); err == nil {
+					out[key] = true
+				}
+			}
+		}
+	}
+	return out
+}
+
+// needsRehash returns true if the password which the entry was bound with should be stored
+// with the preferred scheme instead, either because it uses another scheme or because the
+// configuration file holds it in plain text.
+func (s *Server) needsRehash(name, pwd string) bool {
+	preferred := s.settings.PasswordScheme
+	if preferred == "" {
+		return false
+	}
+	if scheme, _ := password.Scheme([]byte(pwd)); !strings.EqualFold(scheme, preferred) {
+		return true
+	}
+
+	key, err := dn.Normalize(name)
+	return err == nil && s.snapshot().plaintext[key]
+}
+
+// rehashInBackground rehashes the password which the entry was bound with without holding up
+// the bind, as the configuration file has to be rewritten and reloaded. Binds made while the
+// entry's password is being rehashed don't start another rehash.
+func (s *Server) rehashInBackground(name, pwd string, logger zerolog.Logger) {
+	key, err := dn.Normalize(name)
+	if err != nil {
+		logger.Error().Err(err).Msg("password was not rehashed because the dn is invalid")
+		return
+	}
+	if _, running := s.rehashing.LoadOrStore(key, true); running {
+		return
+	}
+
+	s.rehashes.Add(1)
+	go func() {
+		defer s.rehashes.Done()
+		defer s.rehashing.Delete(key)
+		s.rehashPassword(name, pwd, logger)
+	}()
+}
+
+// rehashPassword replaces the entry's passwords which match the password it was bound with,
+// but aren't stored with the preferred scheme, with the password hashed with the preferred
+// scheme. The configuration file holding the entry is rewritten and reloaded, unless it has
+// comments which rewriting it would lose and the settings don't allow that.
+func (s *Server) rehashPassword(name, pwd string, logger zerolog.Logger) {
+	preferred := s.settings.PasswordScheme
+	encoder, ok := password.Lookup(preferred)
+	if !ok {
+		logger.Error().Msgf("password was not rehashed because the scheme '%s' is not supported", preferred)
+		return
+	}
+	parsed, err := dn.Parse(name)
+	if err != nil {
+		logger.Error().Err(err).Msg("password was not rehashed because the dn is invalid")
+		return
+	}
+	nc := s.contextFor(parsed)
+	if nc == nil {
+		return
+	}
+
+	encoded, err := encoder.Encode([]byte(pwd))
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to rehash password")
+		return
+	}
+
+	// passwords without a scheme are stored in plain text, the others are checked by their scheme.
+	stale := func(v string) bool {
+		scheme, ok := password.Scheme([]byte(v))
+		if !ok {
+			return v == pwd
+		}
+		return !strings.EqualFold(scheme, preferred) && password.Matches([]byte(v), []byte(pwd))
+	}
+	changed, err := s.updateDocuments(nc.configFile, func(documents []yaml.MapSlice) bool {
+		if !s.settings.RehashCommented {
+			commented, err := fileHasComments(nc.configFile)
+			if err != nil {
+				logger.Error().Err(err).Msgf("password was not rehashed because '%s' could not be read", nc.configFile)
+				return false
+			}
+			if commented {
+				logger.Warn().Msgf("password was not rehashed because '%s' has comments which would be lost, set rehashCommented to rehash it anyway", nc.configFile)
+				return false
+			}
+		}
+
+		changed := false
+		for _, document := range documents {
+			if other, ok := documentDN(document); !ok || !dnEqual(other, parsed) {
+				continue
+			}
+			for i, item := range document {
+				if key, ok := item.Key.(string); !ok || key != passwordAttribute {
+					continue
+				}
+				switch v := item.Value.(type) {
+				case string:
+					if stale(v) {
+						document[i].Value = string(encoded)
+						changed = true
+					}
+				case []interface{}:
+					for j, value := range v {
+						if str, ok := value.(string); ok && stale(str) {
+							v[j] = string(encoded)
+							changed = true
+						}
+					}
+				}
+			}
+		}
+		return changed
+	})
+	if err != nil {
+		logger.Error().Err(err).Msgf("failed to write the rehashed password to '%s'", nc.configFile)
+		return
+	}
+	if changed {
+		logger.Info().Msgf("password was rehashed with {%s}", strings.ToUpper(preferred))
+		s.ReloadConfiguration(nc.configFile)
+	}
+}
+
+// dnEqual returns true if the text is a valid dn equal to the parsed one.
+func dnEqual(text string, name dn.DN) bool {
+	other, err := dn.Parse(text)
+	return err == nil && other.Equal(name)
+}
@@ -0,0 +1,125 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+)
+
+func TestRehashOnBind(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	settings := DefaultSettings()
+	settings.PasswordScheme = "pbkdf2-sha256"
+	s := newTestServer(t, testConfig, settings)
+	filename := s.contexts[0].configFile
+
+	// a failed bind leaves the password alone.
+	code, _ := s.Bind("cn=root,dc=home,dc=lab", "wrong", testConn(t))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultInvalidCredentials)))
+	Ω(readFile(t, filename)).Should(gomega.Equal(testConfig))
+
+	// a successful bind stores the password with the preferred scheme, in the file and the directory,
+	// once the rehash running in the background has finished.
+	code, _ = s.Bind("cn=root,dc=home,dc=lab", "test", testConn(t))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	s.rehashes.Wait()
+	Ω(s.snapshot().find("cn=root,dc=home,dc=lab").GetAttributeValue("userPassword")).Should(gomega.HavePrefix("{PBKDF2-SHA256}10000$"))
+	Ω(s.snapshot().find("cn=user,ou=users,dc=home,dc=lab").GetAttributeValue("userPassword")).Should(gomega.HavePrefix("{SSHA}"))
+	Ω(readFile(t, filename)).Should(gomega.ContainSubstring("{PBKDF2-SHA256}10000$"))
+	Ω(readFile(t, filename)).Should(gomega.ContainSubstring("{SSHA}I8wq1+4gyJVJUtQW96JGcmCL46ADyPnW"))
+
+	code, _ = s.Bind("cn=root,dc=home,dc=lab", "test", testConn(t))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+}
+
+func TestRehashPlaintext(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	config := `
+dn: dc=home,dc=lab
+dc: home
+objectClass: domain
+---
+cn: plain
+dn: cn=plain,dc=home,dc=lab
+objectClass: "posixAccount"
+userPassword:
+  - "plaintext"
+  - "{SSHA}I8wq1+4gyJVJUtQW96JGcmCL46ADyPnW"
+`
+	settings := DefaultSettings()
+	settings.PasswordScheme = "SSHA"
+	s := newTestServer(t, config, settings)
+	filename := s.contexts[0].configFile
+
+	// the password is already hashed with the preferred scheme, so nothing changes.
+	code, _ := s.Bind("cn=plain,dc=home,dc=lab", "test", testConn(t))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	s.rehashes.Wait()
+	Ω(readFile(t, filename)).Should(gomega.Equal(config))
+
+	// the password is hashed in the directory, but the file only holds it in plain text.
+	code, _ = s.Bind("cn=plain,dc=home,dc=lab", "plaintext", testConn(t))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	s.rehashes.Wait()
+	Ω(readFile(t, filename)).ShouldNot(gomega.ContainSubstring("plaintext"))
+	Ω(readFile(t, filename)).Should(gomega.ContainSubstring("{SSHA}I8wq1+4gyJVJUtQW96JGcmCL46ADyPnW"))
+	Ω(s.contexts[0].plaintext).Should(gomega.BeEmpty())
+
+	code, _ = s.Bind("cn=plain,dc=home,dc=lab", "plaintext", testConn(t))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+}
+
+func TestRehashDisabled(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, testConfig, DefaultSettings())
+	code, _ := s.Bind("cn=root,dc=home,dc=lab", "test", testConn(t))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	s.rehashes.Wait()
+	Ω(readFile(t, s.contexts[0].configFile)).Should(gomega.Equal(testConfig))
+}
+
+func TestRehashCommented(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	config := "# the administrator\n" + testConfig
+	settings := DefaultSettings()
+	settings.PasswordScheme = "pbkdf2-sha256"
+	s := newTestServer(t, config, settings)
+	filename := s.contexts[0].configFile
+
+	// the comment would be lost, so the file is left alone.
+	code, _ := s.Bind("cn=root,dc=home,dc=lab", "test", testConn(t))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	s.rehashes.Wait()
+	Ω(readFile(t, filename)).Should(gomega.Equal(config))
+	Ω(s.snapshot().find("cn=root,dc=home,dc=lab").GetAttributeValue("userPassword")).Should(gomega.HavePrefix("{SSHA}"))
+
+	s.settings.RehashCommented = true
+	code, _ = s.Bind("cn=root,dc=home,dc=lab", "test", testConn(t))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	s.rehashes.Wait()
+	Ω(readFile(t, filename)).Should(gomega.ContainSubstring("{PBKDF2-SHA256}10000$"))
+	Ω(readFile(t, filename)).ShouldNot(gomega.ContainSubstring("# the administrator"))
+}
+
+func TestCheckPasswordScheme(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	Ω(checkPasswordScheme("")).Should(gomega.Succeed())
+	Ω(checkPasswordScheme("argon2")).Should(gomega.Succeed())
+	Ω(checkPasswordScheme("MD5")).Should(gomega.MatchError(gomega.HavePrefix("password scheme 'MD5' is not supported")))
+}
@@ -0,0 +1,250 @@
+package ldap
+
+import (
+	"net"
+	"strings"
+
+	ber "github.com/nmcclain/asn1-ber"
+	"github.com/nmcclain/ldap"
+	"github.com/rs/zerolog"
+	"github.com/shauncampbell/dapper/pkg/dn"
+	"gopkg.in/yaml.v2"
+)
+
+// referenceAttributes are the attributes holding the DNs of other entries which are kept
+// pointing at entries when they are renamed, as OpenLDAP's refint overlay does.
+var referenceAttributes = []string{"member", "uniqueMember"}
+
+// renameRequest is a modify DN request as the client sent it.
+type renameRequest struct {
+	dn           string // The DN of the entry being renamed
+	newRDN       string // The RDN the entry is given
+	deleteOldRDN bool   // The values of the old RDN are removed from the entry
+	newSuperior  string // The DN of the entry's new parent, empty if the entry stays under its parent
+}
+
+// decodeRename decodes a modify DN request. It returns nil if the request is malformed, which
+// the ldap library reports to the client.
+func decodeRename(request *ber.Packet) *renameRequest {
+	if len(request.Children) != 3 && len(request.Children) != 4 {
+		return nil
+	}
+	name, ok := request.Children[0].Value.(string)
+	if !ok {
+		return nil
+	}
+	newRDN, ok := request.Children[1].Value.(string)
+	if !ok {
+		return nil
+	}
+	deleteOldRDN, ok := request.Children[2].Value.(bool)
+	if !ok {
+		return nil
+	}
+
+	rename := &renameRequest{dn: name, newRDN: newRDN, deleteOldRDN: deleteOldRDN}
+	if len(request.Children) == 4 {
+		superior := request.Children[3]
+		if superior.ClassType != ber.ClassContext || superior.Tag != 0 {
+			return nil
+		}
+		rename.newSuperior = ber.DecodeString(superior.Data.Bytes())
+	}
+	return rename
+}
+
+// takeRename returns the modify DN request being handled, if it arrived over a wrapped
+// connection and was well formed.
+func takeRename(c net.Conn) (*renameRequest, bool) {
+	wrapped, ok := c.(*conn)
+	if !ok {
+		return nil, false
+	}
+	wrapped.lock.Lock()
+	defer wrapped.lock.Unlock()
+	rename := wrapped.rename
+	wrapped.rename = nil
+	return rename, rename != nil
+}
+
+// ModifyDN is a handler for an incoming modify DN request.
+func (s *Server) ModifyDN(boundDN string, req ldap.ModifyDNRequest, conn net.Conn) (ldap.LDAPResultCode, error) {
+	logger := s.Logger.With().Str("operation", "modifydn").Str("request_ip", conn.RemoteAddr().String()).Str("bindDN", boundDN).Logger()
+
+	// the library doesn't pass on the request, only the connection has it.
+	rename, ok := takeRename(conn)
+	if !ok {
+		logger.Error().Msgf("modify dn request was rejected because the request is not available")
+		return ldap.LDAPResultOperationsError, nil
+	}
+	logger.Debug().Str("dn", rename.dn).Str("newrdn", rename.newRDN).Str("newSuperior", rename.newSuperior).Msgf("request received")
+	return s.rename(boundDN, *rename, logger), nil
+}
+
+// rename gives a leaf entry a new RDN and optionally moves it under another parent within the
+// same naming context. The member and uniqueMember values of other entries which refer to the
+// entry are changed to refer to its new DN.
+func (s *Server) rename(boundDN string, req renameRequest, logger zerolog.Logger) ldap.LDAPResultCode {
+	old, nc, code := s.updateTarget(req.dn, logger)
+	if code != ldap.LDAPResultSuccess {
+		return code
+	}
+
+	rdn, err := dn.Parse(req.newRDN)
+	if err != nil || len(rdn) != 1 {
+		logger.Error().Msgf("modify dn request was rejected because the new rdn '%s' is invalid", req.newRDN)
+		return ldap.LDAPResultInvalidDNSyntax
+	}
+	parent := old.Parent()
+	if req.newSuperior != "" {
+		if parent, err = dn.Parse(req.newSuperior); err != nil {
+			logger.Error().Err(err).Msgf("modify dn request was rejected because the new superior is invalid")
+			return ldap.LDAPResultInvalidDNSyntax
+		}
+	}
+	renamed := append(dn.DN{rdn[0]}, parent...)
+
+	// each naming context has its own file, so entries can't be moved between them.
+	if s.contextFor(renamed) != nc {
+		logger.Error().Msgf("modify dn request was rejected because the entry would move to another naming context")
+		return ldap.LDAPResultAffectsMultipleDSAs
+	}
+
+	code, err = s.commit(nc, func(documents []yaml.MapSlice) ([]yaml.MapSlice, ldap.LDAPResultCode) {
+		d := s.snapshot()
+		n := d.nodes[old.Normalize()]
+		i := findDocument(documents, old)
+		if n == nil || n.entry == nil || i < 0 {
+			logger.Error().Msgf("modify dn request was rejected because the entry does not exist")
+			return nil, ldap.LDAPResultNoSuchObject
+		}
+		if len(n.children) > 0 {
+			logger.Error().Msgf("modify dn request was rejected because the entry has subordinates")
+			return nil, ldap.LDAPResultNotAllowedOnNonLeaf
+		}
+		if !renamed.Equal(old) && (d.find(renamed.String()) != nil || findDocument(documents, renamed) >= 0) {
+			logger.Error().Msgf("modify dn request was rejected because the entry '%s' already exists", renamed.String())
+			return nil, ldap.LDAPResultEntryAlreadyExists
+		}
+		if parent.Equal(old) || (!renamed.Equal(nc.suffix) && d.nodes[parent.Normalize()] == nil) {
+			logger.Error().Msgf("modify dn request was rejected because the new superior does not exist")
+			return nil, ldap.LDAPResultNoSuchObject
+		}
+		if !s.mayWrite(boundDN, n.entry, entryAttribute) {
+			logger.Error().Msgf("modify dn request was rejected because the client may not write the entry")
+			return nil, ldap.LDAPResultInsufficientAccessRights
+		}
+
+		document := setDocumentDN(documents[i], renamed.String())
+		for _, ava := range rdn[0] {
+			if values := documentValues(document, ava.Type); !containsValue(ava.Type, values, ava.Value) {
+				document = setDocumentValues(document, ava.Type, append(values, ava.Value))
+			}
+		}
+		if req.deleteOldRDN {
+			document = removeRDNValues(document, old[0], rdn[0])
+		}
+
+		entry, code := s.checkUpdate(boundDN, document, logger)
+		if code != ldap.LDAPResultSuccess {
+			return nil, code
+		}
+		if !s.mayWrite(boundDN, entry, entryAttribute) {
+			logger.Error().Msgf("modify dn request was rejected because the client may not write the entry in its new place")
+			return nil, ldap.LDAPResultInsufficientAccessRights
+		}
+		documents[i] = document
+		renameReferences(documents, old, renamed)
+		return documents, ldap.LDAPResultSuccess
+	})
+	if err != nil {
+		logger.Error().Err(err).Msgf("failed to write the renamed entry to '%s'", nc.configFile)
+		return code
+	}
+	if code != ldap.LDAPResultSuccess {
+		return code
+	}
+	logger.Info().Msgf("entry '%s' was renamed to '%s'", req.dn, renamed.String())
+	s.renameOtherReferences(nc, old, renamed, logger)
+	return code
+}
+
+// renameOtherReferences changes the references to a renamed entry held in the configuration
+// files of the other naming contexts. The entry has already been renamed, so a file which
+// can't be changed is logged and left as it is.
+func (s *Server) renameOtherReferences(nc *namingContext, old, renamed dn.DN, logger zerolog.Logger) {
+	done := map[*namingContext]bool{}
+	for _, other := range s.contextsForFile(nc.configFile) {
+		done[other] = true
+	}
+	for _, other := range s.contexts {
+		if done[other] {
+			continue
+		}
+		for _, same := range s.contextsForFile(other.configFile) {
+			done[same] = true
+		}
+
+		changed, err := s.updateDocuments(other.configFile, func(documents []yaml.MapSlice) bool {
+			return renameReferences(documents, old, renamed)
+		})
+		if err != nil {
+			logger.Error().Err(err).Msgf("failed to update the references to the renamed entry in '%s'", other.configFile)
+			continue
+		}
+		if changed {
+			s.ReloadConfiguration(other.configFile)
+		}
+	}
+}
+
+// removeRDNValues removes the values of the old RDN which aren't part of the new RDN.
+func removeRDNValues(document yaml.MapSlice, old, rdn dn.RDN) yaml.MapSlice {
+	for _, ava := range old {
+		kept := false
+		for _, other := range rdn {
+			kept = kept || (strings.EqualFold(ava.Type, other.Type) && sameValue(ava.Type, ava.Value, other.Value))
+		}
+		if kept {
+			continue
+		}
+
+		values := make([]string, 0)
+		for _, v := range documentValues(document, ava.Type) {
+			if !sameValue(ava.Type, v, ava.Value) {
+				values = append(values, v)
+			}
+		}
+		document = setDocumentValues(document, ava.Type, values)
+	}
+	return document
+}
+
+// renameReferences changes the member and uniqueMember values which refer to the old DN to
+// refer to the renamed DN. It returns true if any values were changed.
+func renameReferences(documents []yaml.MapSlice, old, renamed dn.DN) bool {
+	changed := false
+	for i, document := range documents {
+		for _, attribute := range referenceAttributes {
+			values := documentValues(document, attribute)
+			found := false
+			for j, v := range values {
+				// uniqueMember values may end with the unique identifier of the entry (RFC 4517 section 3.3.21).
+				name, uid := v, ""
+				if pos := strings.LastIndex(v, "#'"); pos >= 0 && strings.HasSuffix(v, "'B") {
+					name, uid = v[:pos], v[pos:]
+				}
+				if dnEqual(name, old) {
+					values[j] = renamed.String() + uid
+					found = true
+				}
+			}
+			if found {
+				document = setDocumentValues(document, attribute, values)
+				changed = true
+			}
+		}
+		documents[i] = document
+	}
+	return changed
+}
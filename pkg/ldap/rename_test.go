@@ -0,0 +1,158 @@
+package ldap
+
+import (
+	"net"
+	"testing"
+
+	ber "github.com/nmcclain/asn1-ber"
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+	"github.com/rs/zerolog"
+)
+
+// groupConfig adds an ou for people and a group with the user as a member to the test configuration.
+const groupConfig = testConfig + `---
+dn: ou=people,dc=home,dc=lab
+ou: people
+objectClass: organizationalUnit
+---
+dn: cn=admins,dc=home,dc=lab
+cn: admins
+objectClass: groupOfNames
+member:
+  - CN=User,OU=Users,DC=Home,DC=Lab
+  - cn=root,dc=home,dc=lab
+---
+dn: cn=unique,dc=home,dc=lab
+cn: unique
+objectClass: groupOfUniqueNames
+uniqueMember: "cn=user,ou=users,dc=home,dc=lab#'0101'B"
+`
+
+func TestRename(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, groupConfig, adminSettings())
+	filename := s.contexts[0].configFile
+	admin, logger := "cn=root,dc=home,dc=lab", zerolog.Nop()
+
+	// the old rdn value is removed and references to the entry follow it.
+	code := s.rename(admin, renameRequest{dn: "cn=user,ou=users,dc=home,dc=lab", newRDN: "cn=renamed", deleteOldRDN: true}, logger)
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(s.snapshot().find("cn=user,ou=users,dc=home,dc=lab")).Should(gomega.BeNil())
+	entry := s.snapshot().find("cn=renamed,ou=users,dc=home,dc=lab")
+	Ω(entry).ShouldNot(gomega.BeNil())
+	Ω(entry.GetAttributeValues("cn")).Should(gomega.Equal([]string{"renamed"}))
+	Ω(s.snapshot().find("cn=admins,dc=home,dc=lab").GetAttributeValues("member")).Should(gomega.Equal([]string{"cn=renamed,ou=users,dc=home,dc=lab", "cn=root,dc=home,dc=lab"}))
+	Ω(s.snapshot().find("cn=unique,dc=home,dc=lab").GetAttributeValues("uniqueMember")).Should(gomega.Equal([]string{"cn=renamed,ou=users,dc=home,dc=lab#'0101'B"}))
+	Ω(readFile(t, filename)).Should(gomega.ContainSubstring("cn: renamed\ndn: cn=renamed,ou=users,dc=home,dc=lab\nuid: user\n"))
+	code, _ = s.Bind("cn=renamed,ou=users,dc=home,dc=lab", "test", testConn(t))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+
+	// moving the entry keeps its rdn, and the old rdn value can be kept when it changes.
+	code = s.rename(admin, renameRequest{dn: "cn=renamed,ou=users,dc=home,dc=lab", newRDN: "cn=moved", newSuperior: "ou=people,dc=home,dc=lab"}, logger)
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	entry = s.snapshot().find("cn=moved,ou=people,dc=home,dc=lab")
+	Ω(entry.GetAttributeValues("cn")).Should(gomega.Equal([]string{"renamed", "moved"}))
+	Ω(s.snapshot().find("cn=admins,dc=home,dc=lab").GetAttributeValues("member")).Should(gomega.ContainElement("cn=moved,ou=people,dc=home,dc=lab"))
+
+	// only the case of the rdn changes.
+	code = s.rename(admin, renameRequest{dn: "cn=moved,ou=people,dc=home,dc=lab", newRDN: "cn=Moved", deleteOldRDN: true}, logger)
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(s.snapshot().find("cn=moved,ou=people,dc=home,dc=lab").DN).Should(gomega.Equal("cn=Moved,ou=people,dc=home,dc=lab"))
+}
+
+func TestRenameRejected(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s, _ := newTwoContextServer(t)
+	s.access = newAccessControl(adminSettings(), zerolog.Nop())
+	filename := s.contexts[0].configFile
+	admin, logger := "cn=root,dc=home,dc=lab", zerolog.Nop()
+
+	for _, test := range []struct {
+		request renameRequest
+		code    int
+	}{
+		{renameRequest{dn: "ou=users,dc=home,dc=lab", newRDN: "ou=people"}, ldap.LDAPResultNotAllowedOnNonLeaf},
+		{renameRequest{dn: "cn=missing,dc=home,dc=lab", newRDN: "cn=other"}, ldap.LDAPResultNoSuchObject},
+		{renameRequest{dn: "cn=user,ou=users,dc=home,dc=lab", newRDN: "cn=root", newSuperior: "dc=home,dc=lab"}, ldap.LDAPResultEntryAlreadyExists},
+		{renameRequest{dn: "cn=user,ou=users,dc=home,dc=lab", newRDN: "cn=user", newSuperior: "ou=missing,dc=home,dc=lab"}, ldap.LDAPResultNoSuchObject},
+		{renameRequest{dn: "cn=root,dc=home,dc=lab", newRDN: "cn=root", newSuperior: "cn=root,dc=home,dc=lab"}, ldap.LDAPResultNoSuchObject},
+		{renameRequest{dn: "cn=user,ou=users,dc=home,dc=lab", newRDN: "cn=user", newSuperior: "dc=iot,dc=lab"}, ldap.LDAPResultAffectsMultipleDSAs},
+		{renameRequest{dn: "cn=user,ou=users,dc=home,dc=lab", newRDN: "cn=user,ou=other"}, ldap.LDAPResultInvalidDNSyntax},
+		{renameRequest{dn: "cn=user,ou=users,dc=home,dc=lab", newRDN: "cn=other", newSuperior: "dc=home,,"}, ldap.LDAPResultInvalidDNSyntax},
+		{renameRequest{dn: "cn=Subschema", newRDN: "cn=other"}, ldap.LDAPResultUnwillingToPerform},
+	} {
+		Ω(s.rename(admin, test.request, logger)).Should(gomega.Equal(ldap.LDAPResultCode(test.code)), test.request.newRDN)
+	}
+
+	code := s.rename("cn=user,ou=users,dc=home,dc=lab", renameRequest{dn: "cn=user,ou=users,dc=home,dc=lab", newRDN: "cn=other"}, logger)
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultInsufficientAccessRights)))
+	Ω(readFile(t, filename)).Should(gomega.Equal(testConfig))
+}
+
+func TestRenameReferencesInOtherContexts(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	settings := adminSettings()
+	settings.NamingContexts = []NamingContext{{Suffix: "dc=iot,dc=lab", File: writeConfig(t, iotConfig+`---
+dn: cn=operators,dc=iot,dc=lab
+cn: operators
+objectClass: groupOfNames
+member: cn=user,ou=users,dc=home,dc=lab
+`)}}
+	s := NewServer("dc=home,dc=lab", writeConfig(t, testConfig), 0, settings)
+	s.Logger = zerolog.Nop()
+	s.ReloadAll()
+
+	code := s.rename("cn=root,dc=home,dc=lab", renameRequest{dn: "cn=user,ou=users,dc=home,dc=lab", newRDN: "cn=renamed"}, zerolog.Nop())
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(s.snapshot().find("cn=operators,dc=iot,dc=lab").GetAttributeValues("member")).Should(gomega.Equal([]string{"cn=renamed,ou=users,dc=home,dc=lab"}))
+	Ω(readFile(t, settings.NamingContexts[0].File)).Should(gomega.ContainSubstring("member: cn=renamed,ou=users,dc=home,dc=lab"))
+}
+
+// modifyDNRequest builds a modify DN request, with a new superior unless it is empty.
+func modifyDNRequest(name, newRDN string, deleteOldRDN bool, newSuperior string) *ber.Packet {
+	request := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationModifyDNRequest, nil, "Modify DN Request")
+	request.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Entry"))
+	request.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, newRDN, "New RDN"))
+	request.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, deleteOldRDN, "Delete Old RDN"))
+	if newSuperior != "" {
+		request.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, newSuperior, "New Superior"))
+	}
+	return request
+}
+
+func TestRenameOverConnection(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, groupConfig, adminSettings())
+	c, err := net.Dial("tcp", listen(t, s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	code, _ := sendRequest(t, c, 1, simpleBindRequest("cn=root,dc=home,dc=lab", "test"))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+
+	// the library can't decode the new superior, so it only works if the connection keeps it.
+	code, _ = sendRequest(t, c, 2, modifyDNRequest("cn=user,ou=users,dc=home,dc=lab", "cn=user", true, "ou=people,dc=home,dc=lab"))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(s.snapshot().find("cn=user,ou=people,dc=home,dc=lab")).ShouldNot(gomega.BeNil())
+
+	code, _ = sendRequest(t, c, 3, modifyDNRequest("cn=user,ou=people,dc=home,dc=lab", "uid=user", false, ""))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	entry := s.snapshot().find("uid=user,ou=people,dc=home,dc=lab")
+	Ω(entry.GetAttributeValues("cn")).Should(gomega.Equal([]string{"user"}))
+	Ω(s.snapshot().find("cn=admins,dc=home,dc=lab").GetAttributeValues("member")).Should(gomega.ContainElement("uid=user,ou=people,dc=home,dc=lab"))
+}
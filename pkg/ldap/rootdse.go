@@ -0,0 +1,69 @@
+package ldap
+
+import (
+	"net"
+
+	"github.com/nmcclain/ldap"
+	"github.com/rs/zerolog"
+	"github.com/shauncampbell/dapper/pkg/query"
+)
+
+const (
+	featureAllOperationalAttributes = "1.3.6.1.4.1.4203.1.5.1" // '+' requests every operational attribute (RFC 3673)
+	featureAbsoluteFilters          = "1.3.6.1.4.1.4203.1.5.3" // (&) and (|) are absolute true and false filters (RFC 4526)
+)
+
+// supportedControls are the OIDs of the request controls the server understands.
+var supportedControls = []string{ldap.ControlTypePaging, controlTypeSortRequest}
+
+// supportedFeatures are the OIDs of the optional LDAP features the server implements.
+var supportedFeatures = []string{featureAllOperationalAttributes, featureAbsoluteFilters}
+
+// isRootDSESearch returns true if the search is for the root DSE, which is a base object
+// search with an empty base DN (RFC 4512 section 5.1).
+func isRootDSESearch(searchReq ldap.SearchRequest) bool {
+	return searchReq.BaseDN == "" && searchReq.Scope == ldap.ScopeBaseObject
+}
+
+// rootDSE returns the entry which describes the server so that clients can discover the
+// naming contexts it holds and the features it supports. Attributes the server has no values
+// for, such as supportedExtension when TLS isn't configured, are left out.
+func (s *Server) rootDSE() *ldap.Entry {
+	entry := &ldap.Entry{DN: "", Attributes: make([]*ldap.EntryAttribute, 0)}
+	add := func(name string, values ...string) {
+		if len(values) > 0 {
+			entry.Attributes = append(entry.Attributes, &ldap.EntryAttribute{Name: name, Values: values})
+		}
+	}
+
+	add("objectClass", "top")
+	add("namingContexts", s.suffixes()...)
+	add("subschemaSubentry", subschemaDN)
+	add("supportedLDAPVersion", "3")
+	add("supportedControl", supportedControls...)
+	if s.tlsConfig != nil {
+		add("supportedExtension", extensionStartTLS)
+	}
+	add("supportedFeatures", supportedFeatures...)
+	add("supportedSASLMechanisms", s.saslMechanisms()...)
+	add("vendorName", "Dapper")
+	return entry
+}
+
+// searchRootDSE answers a search for the root DSE. Everyone may read the root DSE as clients
+// read it before they bind, and since all of its attributes are operational they are returned
+// when the client asks for all user attributes as well as when they are asked for by name.
+func (s *Server) searchRootDSE(searchReq ldap.SearchRequest, q query.Evaluator, conn net.Conn, logger zerolog.Logger) (ldap.ServerSearchResult, error) {
+	logger.Debug().Msgf("reading the root dse with query: %s", searchReq.Filter)
+
+	entries := []*ldap.Entry{}
+	entry := s.rootDSE()
+	if q.Evaluate(entry) {
+		attributes := searchReq.Attributes
+		if newAttributeSelection(attributes).all {
+			attributes = []string{allUserAttributes, allOperationalAttributes}
+		}
+		entries = append(entries, selectAttributes(entry, attributes, searchReq.TypesOnly))
+	}
+	return s.respond(conn, ldap.ServerSearchResult{Entries: entries, Referrals: []string{}, Controls: []ldap.Control{}, ResultCode: ldap.LDAPResultSuccess})
+}
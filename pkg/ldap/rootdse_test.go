@@ -0,0 +1,78 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+)
+
+func TestRootDSE(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, testConfig, DefaultSettings())
+	conn := testConn(t)
+
+	// asking for all user attributes returns the whole root dse.
+	result, err := s.Search("", ldap.SearchRequest{BaseDN: "", Scope: ldap.ScopeBaseObject, Filter: "(objectClass=*)"}, conn)
+	Ω(err).Should(gomega.BeNil())
+	Ω(result.ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(dns(result)).Should(gomega.Equal([]string{""}))
+	entry := result.Entries[0]
+	Ω(entry.GetAttributeValues("namingContexts")).Should(gomega.Equal([]string{"dc=home,dc=lab"}))
+	Ω(entry.GetAttributeValues("supportedLDAPVersion")).Should(gomega.Equal([]string{"3"}))
+	Ω(entry.GetAttributeValues("supportedControl")).Should(gomega.ConsistOf(ldap.ControlTypePaging, controlTypeSortRequest))
+	Ω(entry.GetAttributeValue("subschemaSubentry")).Should(gomega.Equal(subschemaDN))
+	Ω(entry.GetAttributeValue("vendorName")).Should(gomega.Equal("Dapper"))
+
+	// attributes can be asked for by name.
+	result, _ = s.Search("", ldap.SearchRequest{BaseDN: "", Scope: ldap.ScopeBaseObject, Filter: "(objectClass=*)", Attributes: []string{"namingContexts"}}, conn)
+	Ω(result.Entries[0].Attributes).Should(gomega.HaveLen(1))
+
+	// the filter still applies.
+	result, _ = s.Search("", ldap.SearchRequest{BaseDN: "", Scope: ldap.ScopeBaseObject, Filter: "(vendorName=OpenLDAP)"}, conn)
+	Ω(result.ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(result.Entries).Should(gomega.BeEmpty())
+
+	// searches below the root dse don't return it.
+	result, _ = s.Search("", ldap.SearchRequest{BaseDN: "", Scope: ldap.ScopeSingleLevel, Filter: "(objectClass=*)"}, conn)
+	Ω(dns(result)).ShouldNot(gomega.ContainElement(""))
+}
+
+func TestRootDSEReachesClient(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	// clients read the root dse before they bind.
+	settings := DefaultSettings()
+	settings.DisableAnonymous = true
+	client := serve(t, newTestServer(t, testConfig, settings))
+
+	result, err := client.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"namingContexts", "supportedControl"}, nil))
+	Ω(err).Should(gomega.BeNil())
+	Ω(result.Entries).Should(gomega.HaveLen(1))
+	Ω(result.Entries[0].GetAttributeValues("namingContexts")).Should(gomega.Equal([]string{"dc=home,dc=lab"}))
+
+	_, err = client.Search(ldap.NewSearchRequest("dc=home,dc=lab", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	Ω(err).ShouldNot(gomega.BeNil())
+}
+
+func TestAbsoluteFilters(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	// clients trusting the advertised feature may send (&) and (|) over the wire.
+	client := serve(t, newTestServer(t, testConfig, DefaultSettings()))
+
+	result, err := client.Search(ldap.NewSearchRequest("dc=home,dc=lab", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(&)", nil, nil))
+	Ω(err).Should(gomega.BeNil())
+	Ω(result.Entries).Should(gomega.HaveLen(4))
+
+	result, err = client.Search(ldap.NewSearchRequest("dc=home,dc=lab", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(|)", nil, nil))
+	Ω(err).Should(gomega.BeNil())
+	Ω(result.Entries).Should(gomega.BeEmpty())
+}
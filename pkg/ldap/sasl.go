@@ -0,0 +1,260 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	ber "github.com/nmcclain/asn1-ber"
+	"github.com/nmcclain/ldap"
+	"github.com/rs/zerolog"
+	"github.com/shauncampbell/dapper/pkg/dn"
+)
+
+// saslExternal is the SASL mechanism which authenticates clients by their TLS client
+// certificate (RFC 4422 appendix A).
+const saslExternal = "EXTERNAL"
+
+// CertificateMapping maps verified client certificates onto the DN of a directory entry for
+// SASL EXTERNAL binds. Either the subject or the subject alternative names of the certificate
+// are matched, and the expression must match the whole value.
+type CertificateMapping struct {
+	Subject string `yaml:"subject"` // An expression matched against the subject, e.g. CN=(.+),OU=devices,O=Example
+	SAN     string `yaml:"san"`     // An expression matched against each SAN, written as dns:, email:, uri: or ip: followed by the name
+	DN      string `yaml:"dn"`      // The DN of the entry, which may use the groups of the expression, e.g. cn=$1,ou=devices,dc=home,dc=lab
+}
+
+// certificateMapping is a certificate mapping which is ready to be used.
+type certificateMapping struct {
+	subject *regexp.Regexp // The expression matching the subject, nil if the SANs are matched instead
+	san     *regexp.Regexp // The expression matching the SANs, nil if the subject is matched instead
+	dn      string         // The template for the DN of the entry
+}
+
+// compileCertificateMappings checks the certificate mappings and compiles their expressions.
+func compileCertificateMappings(mappings []CertificateMapping) ([]certificateMapping, error) {
+	out := make([]certificateMapping, 0, len(mappings))
+	for _, m := range mappings {
+		if (m.Subject == "") == (m.SAN == "") {
+			return nil, fmt.Errorf("certificate mapping for '%s' must match either the subject or a san", m.DN)
+		}
+		if m.DN == "" {
+			return nil, fmt.Errorf("certificate mapping must have a dn")
+		}
+
+		expression := m.Subject
+		if expression == "" {
+			expression = m.SAN
+		}
+		re, err := regexp.Compile("^(?:" + expression + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("certificate mapping '%s' is not a valid expression: %w", expression, err)
+		}
+
+		mapping := certificateMapping{dn: m.DN}
+		if m.Subject != "" {
+			mapping.subject = re
+		} else {
+			mapping.san = re
+		}
+		out = append(out, mapping)
+	}
+	return out, nil
+}
+
+// subjectAltNames returns the subject alternative names of the certificate in the form they are
+// matched by certificate mappings.
+func subjectAltNames(cert *x509.Certificate) []string {
+	names := make([]string, 0)
+	for _, name := range cert.DNSNames {
+		names = append(names, "dns:"+name)
+	}
+	for _, email := range cert.EmailAddresses {
+		names = append(names, "email:"+email)
+	}
+	for _, uri := range cert.URIs {
+		names = append(names, "uri:"+uri.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		names = append(names, "ip:"+ip.String())
+	}
+	return names
+}
+
+// subjectValue turns text taken from the string form of a certificate subject back into the
+// value it stands for, so that characters escaped in the subject aren't escaped twice.
+func subjectValue(text string) string {
+	parsed, err := dn.Parse("cn=" + text)
+	if err != nil || len(parsed) != 1 || len(parsed[0]) != 1 {
+		return text
+	}
+	return parsed[0][0].Value
+}
+
+// expand fills in the DN template with the groups matched in the value. The groups are escaped
+// so that a value can't add RDNs to the DN.
+func (m certificateMapping) expand(re *regexp.Regexp, value string, match []int) string {
+	escaped := &strings.Builder{}
+	indices := make([]int, len(match))
+	for i := 0; i < len(match); i += 2 {
+		if match[i] < 0 {
+			indices[i], indices[i+1] = -1, -1
+			continue
+		}
+		group := value[match[i]:match[i+1]]
+		if m.subject != nil {
+			group = subjectValue(group)
+		}
+		indices[i] = escaped.Len()
+		escaped.WriteString(dn.EscapeValue(group))
+		indices[i+1] = escaped.Len()
+	}
+	return string(re.ExpandString(nil, m.dn, escaped.String(), indices))
+}
+
+// mapCertificate returns the DN of the entry for the certificate, which is given by the first
+// mapping that matches it.
+func mapCertificate(mappings []certificateMapping, cert *x509.Certificate) (string, error) {
+	for _, m := range mappings {
+		values := []string{cert.Subject.String()}
+		re := m.subject
+		if re == nil {
+			values = subjectAltNames(cert)
+			re = m.san
+		}
+
+		for _, value := range values {
+			if match := re.FindStringSubmatchIndex(value); match != nil {
+				name := m.expand(re, value, match)
+				if _, err := dn.Parse(name); err != nil {
+					return "", fmt.Errorf("certificate '%s' maps onto '%s' which is not a valid dn: %w", cert.Subject, name, err)
+				}
+				return name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no certificate mapping matches '%s'", cert.Subject)
+}
+
+// externalBind is the outcome of mapping the client certificate for a SASL EXTERNAL bind. It is
+// worked out before the ldap library sees the request so that the library records the mapped
+// DN as the bound DN.
+type externalBind struct {
+	dn  string // The DN the certificate maps onto
+	err error  // Why the certificate couldn't be used, nil if it maps onto dn
+}
+
+// isSASLBind returns true if the request is a bind request using the SASL mechanism.
+func isSASLBind(request *ber.Packet, mechanism string) bool {
+	if request.Tag != ldap.ApplicationBindRequest || len(request.Children) < 3 {
+		return false
+	}
+	auth := request.Children[2]
+	if auth.ClassType != ber.ClassContext || auth.Tag != ldap.LDAPBindAuthSASL || len(auth.Children) == 0 {
+		return false
+	}
+	return auth.Children[0].Data.String() == mechanism
+}
+
+// peerCertificate returns the verified certificate the client presented during the TLS
+// handshake, or nil if it didn't present one.
+func peerCertificate(c net.Conn) *x509.Certificate {
+	secured, ok := c.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	state := secured.ConnectionState()
+	if len(state.VerifiedChains) == 0 || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return state.PeerCertificates[0]
+}
+
+// mapExternalBind works out the DN that a SASL EXTERNAL bind authenticates as, from the client
+// certificate and the authorization identity in the credentials. Only the identity of the
+// certificate itself may be requested (RFC 4513 section 5.2.3).
+func (c *conn) mapExternalBind(request *ber.Packet) externalBind {
+	cert := peerCertificate(c.Conn)
+	if cert == nil {
+		return externalBind{err: fmt.Errorf("the client did not present a certificate")}
+	}
+	name, err := mapCertificate(c.mappings, cert)
+	if err != nil {
+		return externalBind{err: err}
+	}
+
+	auth := request.Children[2]
+	if len(auth.Children) > 1 && auth.Children[1].Data.Len() > 0 {
+		authzID := auth.Children[1].Data.String()
+		requested, err := dn.Parse(strings.TrimPrefix(authzID, "dn:"))
+		mapped, _ := dn.Parse(name)
+		if !strings.HasPrefix(authzID, "dn:") || err != nil || !requested.Equal(mapped) {
+			return externalBind{dn: name, err: fmt.Errorf("'%s' may not act as '%s'", name, authzID)}
+		}
+	}
+	return externalBind{dn: name}
+}
+
+// externalBindRequest rewrites a SASL EXTERNAL bind request as a simple bind of the mapped DN
+// without a password, which the ldap library passes on to the Bind handler. The DN is left
+// empty if the bind failed so that the library never records it as the bound DN.
+func externalBindRequest(packet *ber.Packet, bind externalBind) *ber.Packet {
+	name := bind.dn
+	if bind.err != nil {
+		name = ""
+	}
+	request := packet.Children[1]
+	request.Children[1] = ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "User Name")
+	request.Children[2] = ber.NewString(ber.ClassContext, ber.TypePrimitive, ldap.LDAPBindAuthSimple, "", "Password")
+	return rebuild(packet)
+}
+
+// takeExternalBind returns the outcome of the SASL EXTERNAL bind being handled, if the bind
+// request being handled is one.
+func takeExternalBind(c net.Conn) (externalBind, bool) {
+	wrapped, ok := c.(*conn)
+	if !ok {
+		return externalBind{}, false
+	}
+	wrapped.lock.Lock()
+	defer wrapped.lock.Unlock()
+	bind := wrapped.external
+	wrapped.external = nil
+	if bind == nil {
+		return externalBind{}, false
+	}
+	return *bind, true
+}
+
+// saslMechanisms returns the SASL mechanisms which clients may bind with.
+func (s *Server) saslMechanisms() []string {
+	if s.tlsConfig == nil || len(s.mappings) == 0 || s.tlsConfig.ClientCAs == nil {
+		return nil
+	}
+	return []string{saslExternal}
+}
+
+// bindExternal finishes a SASL EXTERNAL bind, which succeeds if the client certificate maps
+// onto an entry which may be used to authenticate.
+func (s *Server) bindExternal(bind externalBind, logger zerolog.Logger) (ldap.LDAPResultCode, error) {
+	logger = logger.With().Str("mechanism", saslExternal).Str("bindDN", bind.dn).Logger()
+	if bind.err != nil {
+		logger.Error().Err(bind.err).Msgf("bind request was rejected because the client certificate could not be used")
+		return ldap.LDAPResultInvalidCredentials, nil
+	}
+
+	entry := s.snapshot().find(bind.dn)
+	if entry == nil {
+		logger.Error().Msgf("bind request was rejected because the dn does not exist")
+		return ldap.LDAPResultInvalidCredentials, nil
+	}
+	if !s.access.allows("", entry, entryAttribute, accessAuth) {
+		logger.Error().Msgf("bind request was rejected because the entry may not be used to authenticate")
+		return ldap.LDAPResultInvalidCredentials, nil
+	}
+	logger.Debug().Msgf("bind request was accepted")
+	return ldap.LDAPResultSuccess, nil
+}
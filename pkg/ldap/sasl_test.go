@@ -0,0 +1,154 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+
+	ber "github.com/nmcclain/asn1-ber"
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+)
+
+// saslExternalBindRequest builds a SASL EXTERNAL bind request with an optional authorization identity.
+func saslExternalBindRequest(authzID string) *ber.Packet {
+	request := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationBindRequest, nil, "Bind Request")
+	request.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 3, "Version"))
+	request.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "User Name"))
+	auth := ber.Encode(ber.ClassContext, ber.TypeConstructed, ldap.LDAPBindAuthSASL, nil, "SASL")
+	auth.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, saslExternal, "Mechanism"))
+	if authzID != "" {
+		auth.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, authzID, "Credentials"))
+	}
+	request.AppendChild(auth)
+	return request
+}
+
+// externalSettings returns settings which map client certificates issued for the CN of a user
+// onto the user, along with a client certificate for cn=user and a pool trusting the server.
+func externalSettings(t *testing.T) (Settings, tls.Certificate, *x509.CertPool) {
+	settings, pool := tlsSettings(t)
+	certFile, keyFile, _ := testCertificate(t, "user")
+	client, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings.DisableAnonymous = true
+	settings.TLS.ClientCAFile = certFile
+	settings.TLS.CertificateMappings = []CertificateMapping{{Subject: "CN=([^,]+)", DN: "cn=$1,ou=users,dc=home,dc=lab"}}
+	return settings, client, pool
+}
+
+func TestCompileCertificateMappings(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	mappings, err := compileCertificateMappings([]CertificateMapping{
+		{Subject: "CN=(.+),OU=devices", DN: "cn=$1,ou=devices,dc=home,dc=lab"},
+		{SAN: "dns:(.+)\\.home\\.lab", DN: "cn=$1,ou=hosts,dc=home,dc=lab"},
+	})
+	Ω(err).Should(gomega.BeNil())
+	Ω(mappings).Should(gomega.HaveLen(2))
+
+	_, err = compileCertificateMappings([]CertificateMapping{{DN: "cn=a"}})
+	Ω(err).ShouldNot(gomega.BeNil())
+	_, err = compileCertificateMappings([]CertificateMapping{{Subject: "CN=a", SAN: "dns:a", DN: "cn=a"}})
+	Ω(err).ShouldNot(gomega.BeNil())
+	_, err = compileCertificateMappings([]CertificateMapping{{Subject: "CN=a"}})
+	Ω(err).ShouldNot(gomega.BeNil())
+	_, err = compileCertificateMappings([]CertificateMapping{{Subject: "CN=(", DN: "cn=a"}})
+	Ω(err).ShouldNot(gomega.BeNil())
+}
+
+func TestMapCertificate(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	mappings, err := compileCertificateMappings([]CertificateMapping{
+		{Subject: "CN=(.+),OU=devices,O=Example", DN: "cn=$1,ou=devices,dc=home,dc=lab"},
+		{SAN: "dns:(.+)\\.home\\.lab", DN: "cn=$1,ou=hosts,dc=home,dc=lab"},
+	})
+	Ω(err).Should(gomega.BeNil())
+
+	device := &x509.Certificate{Subject: pkix.Name{CommonName: "sensor1", OrganizationalUnit: []string{"devices"}, Organization: []string{"Example"}}}
+	Ω(mapCertificate(mappings, device)).Should(gomega.Equal("cn=sensor1,ou=devices,dc=home,dc=lab"))
+
+	// the expression must match the whole subject.
+	device.Subject.Organization = []string{"Other"}
+	_, err = mapCertificate(mappings, device)
+	Ω(err).ShouldNot(gomega.BeNil())
+
+	// values can't add RDNs to the DN, whether they are escaped in the subject or not.
+	device.Subject.Organization = []string{"Example"}
+	device.Subject.CommonName = "x,ou=admins"
+	Ω(mapCertificate(mappings, device)).Should(gomega.Equal(`cn=x\,ou\=admins,ou=devices,dc=home,dc=lab`))
+
+	host := &x509.Certificate{Subject: pkix.Name{CommonName: "ignored"}, DNSNames: []string{"example.com", "nas.home.lab"}}
+	Ω(mapCertificate(mappings, host)).Should(gomega.Equal("cn=nas,ou=hosts,dc=home,dc=lab"))
+	host.DNSNames = []string{"a+b.home.lab"}
+	Ω(mapCertificate(mappings, host)).Should(gomega.Equal(`cn=a\+b,ou=hosts,dc=home,dc=lab`))
+}
+
+func TestSASLExternal(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	settings, client, pool := externalSettings(t)
+	s := newTestServer(t, testConfig, settings)
+	Ω(s.rootDSE().GetAttributeValues("supportedSASLMechanisms")).Should(gomega.Equal([]string{saslExternal}))
+	addr := listenTLS(t, s)
+
+	c, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{client}})
+	Ω(err).Should(gomega.BeNil())
+	defer c.Close()
+
+	// asking to act as another entry is refused and leaves the client anonymous.
+	code, _ := sendRequest(t, c, 1, saslExternalBindRequest("dn:cn=root,dc=home,dc=lab"))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultInvalidCredentials)))
+	_, code = searchConn(t, c, 2, equalityFilter("uid", "user"))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultInsufficientAccessRights)))
+
+	// the certificate authenticates the client as the entry it maps onto.
+	code, _ = sendRequest(t, c, 3, saslExternalBindRequest("dn:cn=user,ou=users,dc=home,dc=lab"))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	code, _ = sendRequest(t, c, 4, saslExternalBindRequest(""))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	found, code := searchConn(t, c, 5, equalityFilter("uid", "user"))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(found).Should(gomega.Equal([]string{"cn=user,ou=users,dc=home,dc=lab"}))
+
+	// a simple bind without a password still isn't accepted.
+	code, _ = sendRequest(t, c, 6, simpleBindRequest("cn=user,ou=users,dc=home,dc=lab", ""))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultUnwillingToPerform)))
+}
+
+func TestSASLExternalWithoutCertificate(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	settings, _, pool := externalSettings(t)
+	s := newTestServer(t, testConfig, settings)
+
+	// clients may still connect without a certificate but can't use it to bind.
+	c, err := tls.Dial("tcp", listenTLS(t, s), &tls.Config{RootCAs: pool})
+	Ω(err).Should(gomega.BeNil())
+	defer c.Close()
+	code, _ := sendRequest(t, c, 1, saslExternalBindRequest(""))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultInvalidCredentials)))
+	code, _ = sendRequest(t, c, 2, simpleBindRequest("cn=user,ou=users,dc=home,dc=lab", "test"))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+
+	// nor can clients which haven't started TLS.
+	plain, err := net.Dial("tcp", listen(t, s))
+	Ω(err).Should(gomega.BeNil())
+	defer plain.Close()
+	code, _ = sendRequest(t, plain, 1, saslExternalBindRequest(""))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultInvalidCredentials)))
+}
@@ -0,0 +1,91 @@
+package ldap
+
+import (
+	"fmt"
+
+	"github.com/nmcclain/ldap"
+	"github.com/shauncampbell/dapper/pkg/schema"
+)
+
+// The ways in which entries which don't conform to the schema can be handled.
+const (
+	schemaWarn   = "warn"   // Log a warning for each entry which doesn't conform
+	schemaReject = "reject" // Refuse to load a configuration containing an entry which doesn't conform
+	schemaOff    = "off"    // Don't check entries against the schema
+)
+
+// SchemaSettings controls how the entries in the configuration file are checked against the
+// schema. The built-in core, cosine, inetOrgPerson and nis schema can be extended with extra
+// definitions written in the format of RFC 4512, e.g.
+//
+//	( 1.3.6.1.4.1.99999.2.1 NAME 'jellyfinUser' SUP top AUXILIARY MAY description )
+type SchemaSettings struct {
+	Validation     string   `yaml:"validation"`     // Either warn (the default), reject or off
+	AttributeTypes []string `yaml:"attributeTypes"` // Extra attribute type definitions
+	ObjectClasses  []string `yaml:"objectClasses"`  // Extra object class definitions
+}
+
+// buildSchema creates the schema from the built-in definitions and the extra definitions in
+// the settings.
+func buildSchema(settings SchemaSettings) (*schema.Schema, error) {
+	switch settings.Validation {
+	case "", schemaWarn, schemaReject, schemaOff:
+	default:
+		return nil, fmt.Errorf("schema validation must be '%s', '%s' or '%s' not '%s'", schemaWarn, schemaReject, schemaOff, settings.Validation)
+	}
+
+	s := schema.Default()
+	for _, definition := range settings.AttributeTypes {
+		if err := s.AddAttributeType(definition); err != nil {
+			return nil, err
+		}
+	}
+	for _, definition := range settings.ObjectClasses {
+		if err := s.AddObjectClass(definition); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// newSchema creates the schema used by the server and makes its matching rules available to
+// search filters. Problems with the settings are logged and the built-in schema used instead.
+func (s *Server) newSchema(settings SchemaSettings) *schema.Schema {
+	sch, err := buildSchema(settings)
+	if err != nil {
+		s.Logger.Error().Err(err).Msg("failed to load the schema, only the built-in schema will be used")
+		sch = schema.Default()
+	}
+	if err := sch.Register(); err != nil {
+		s.Logger.Error().Err(err).Msg("failed to register the matching rules of the schema")
+	}
+	return sch
+}
+
+// validateEntries checks the entries against the schema. Entries which don't conform are
+// logged, and if the validation setting is reject an error is returned.
+func (s *Server) validateEntries(entries []*ldap.Entry) error {
+	mode := s.settings.Schema.Validation
+	if mode == schemaOff {
+		return nil
+	}
+
+	invalid := 0
+	for _, entry := range entries {
+		err := s.schema.Validate(entry)
+		if err == nil {
+			continue
+		}
+		invalid++
+		if mode == schemaReject {
+			s.Logger.Error().Str("dn", entry.DN).Err(err).Msg("entry does not conform to the schema")
+		} else {
+			s.Logger.Warn().Str("dn", entry.DN).Err(err).Msg("entry does not conform to the schema")
+		}
+	}
+
+	if mode == schemaReject && invalid > 0 {
+		return fmt.Errorf("%d entries do not conform to the schema", invalid)
+	}
+	return nil
+}
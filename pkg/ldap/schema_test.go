@@ -0,0 +1,84 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+)
+
+const schemaConfig = `
+dn: dc=home,dc=lab
+dc: home
+objectClass: [top, domain]
+---
+dn: uid=user,dc=home,dc=lab
+objectClass: [inetOrgPerson, posixAccount, jellyfinUser]
+cn: User
+sn: User
+uid: user
+uidNumber: "1000"
+gidNumber: "1000"
+homeDirectory: /home/user
+jellyfinId: abc123
+`
+
+// rejectSettings returns settings which reject invalid entries and know about jellyfin users.
+func rejectSettings() Settings {
+	settings := DefaultSettings()
+	settings.Schema = SchemaSettings{
+		Validation:     schemaReject,
+		AttributeTypes: []string{"( 1.3.6.1.4.1.99999.1.1 NAME 'jellyfinId' EQUALITY caseExactMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.15 SINGLE-VALUE )"},
+		ObjectClasses:  []string{"( 1.3.6.1.4.1.99999.2.1 NAME 'jellyfinUser' SUP top AUXILIARY MAY jellyfinId )"},
+	}
+	return settings
+}
+
+func TestSchemaValidationWarns(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	// cn=root has no structural object class but is still loaded.
+	s := newTestServer(t, testConfig, DefaultSettings())
+	result, _ := s.Search("", ldap.SearchRequest{BaseDN: "cn=root,dc=home,dc=lab", Scope: ldap.ScopeBaseObject, Filter: "(objectClass=*)"}, testConn(t))
+	Ω(dns(result)).Should(gomega.Equal([]string{"cn=root,dc=home,dc=lab"}))
+
+	// the dn key isn't an attribute of the entry.
+	Ω(result.Entries[0].GetAttributeValues("dn")).Should(gomega.BeEmpty())
+}
+
+func TestSchemaValidationRejects(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, schemaConfig, rejectSettings())
+	result, _ := s.Search("", ldap.SearchRequest{BaseDN: "dc=home,dc=lab", Scope: ldap.ScopeWholeSubtree, Filter: "(jellyfinId=abc123)"}, testConn(t))
+	Ω(dns(result)).Should(gomega.Equal([]string{"uid=user,dc=home,dc=lab"}))
+
+	// a configuration with an invalid entry is not loaded, so the previous one is kept.
+	s.contexts[0].configFile = writeConfig(t, testConfig)
+	s.ReloadConfiguration(s.contexts[0].configFile)
+	result, _ = s.Search("", ldap.SearchRequest{BaseDN: "dc=home,dc=lab", Scope: ldap.ScopeWholeSubtree, Filter: "(objectClass=*)"}, testConn(t))
+	Ω(dns(result)).Should(gomega.ConsistOf("dc=home,dc=lab", "uid=user,dc=home,dc=lab"))
+
+	// the extra definitions are used when evaluating filters.
+	result, _ = s.Search("", ldap.SearchRequest{BaseDN: "dc=home,dc=lab", Scope: ldap.ScopeWholeSubtree, Filter: "(jellyfinId=ABC123)"}, testConn(t))
+	Ω(result.Entries).Should(gomega.BeEmpty())
+}
+
+func TestBuildSchema(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	_, err := buildSchema(rejectSettings().Schema)
+	Ω(err).Should(gomega.BeNil())
+
+	_, err = buildSchema(SchemaSettings{Validation: "sometimes"})
+	Ω(err).ShouldNot(gomega.BeNil())
+
+	_, err = buildSchema(SchemaSettings{ObjectClasses: []string{"( 1.3.6.1.4.1.99999.2.1 NAME 'jellyfinUser' SUP top AUXILIARY MAY jellyfinId )"}})
+	Ω(err).ShouldNot(gomega.BeNil())
+}
@@ -0,0 +1,79 @@
+package ldap
+
+import (
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Settings holds the server wide settings. Unlike the configuration file, which holds the
+// directory entries, the settings are only read when the server starts.
+type Settings struct {
+	AdminDNs            []string        `yaml:"adminDNs"`            // DNs which are allowed to see and do everything
+	SensitiveAttributes []string        `yaml:"sensitiveAttributes"` // Attributes which, like userPassword, are only visible to the entry itself and admins
+	DisableAnonymous    bool            `yaml:"disableAnonymous"`    // Refuse anonymous binds and searches
+	ACL                 []ACLRule       `yaml:"acl"`                 // Access control rules, if empty everyone may read everything
+	Limits              Limits          `yaml:"limits"`              // The maximum size and time limits for searches
+	Schema              SchemaSettings  `yaml:"schema"`              // How entries are checked against the schema
+	NamingContexts      []NamingContext `yaml:"namingContexts"`      // Extra suffixes to serve, each from its own configuration file
+	TLS                 TLSSettings     `yaml:"tls"`                 // The certificate for LDAPS and StartTLS
+	Indexes             []IndexSettings `yaml:"indexes"`             // Attributes which are indexed to speed up searches
+	PasswordScheme      string          `yaml:"passwordScheme"`      // The scheme passwords are rehashed with when they are used to bind and new passwords are hashed with, if empty passwords are left as they are and new ones use {SSHA}
+	RehashCommented     bool            `yaml:"rehashCommented"`     // Rehash passwords in configuration files with comments, which are lost when the file is rewritten
+}
+
+// DefaultSettings returns the settings used when no settings file is provided.
+func DefaultSettings() Settings {
+	return Settings{
+		AdminDNs:            []string{},
+		SensitiveAttributes: []string{},
+		ACL:                 []ACLRule{},
+	}
+}
+
+// LoadSettings reads the settings from a yaml file. If the filename is empty then the
+// default settings are returned.
+func LoadSettings(filename string) (Settings, error) {
+	settings := DefaultSettings()
+	if filename == "" {
+		return settings, nil
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return settings, err
+	}
+
+	if err := yaml.UnmarshalStrict(data, &settings); err != nil {
+		return settings, err
+	}
+
+	if _, err := compileACL(settings.ACL); err != nil {
+		return settings, err
+	}
+
+	if _, err := buildSchema(settings.Schema); err != nil {
+		return settings, err
+	}
+
+	if err := checkNamingContexts(settings.NamingContexts); err != nil {
+		return settings, err
+	}
+
+	if _, _, err := newTLSConfig(settings.TLS); err != nil {
+		return settings, err
+	}
+
+	if _, err := compileCertificateMappings(settings.TLS.CertificateMappings); err != nil {
+		return settings, err
+	}
+
+	if err := checkPasswordScheme(settings.PasswordScheme); err != nil {
+		return settings, err
+	}
+
+	if _, err := compileIndexes(settings.Indexes); err != nil {
+		return settings, err
+	}
+	return settings, nil
+}
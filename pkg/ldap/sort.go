@@ -0,0 +1,211 @@
+package ldap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	ber "github.com/nmcclain/asn1-ber"
+	"github.com/nmcclain/ldap"
+	"github.com/shauncampbell/dapper/pkg/query"
+)
+
+const (
+	controlTypeSortRequest  = "1.2.840.113556.1.4.473" // The server side sort request control (RFC 2891)
+	controlTypeSortResponse = "1.2.840.113556.1.4.474" // The server side sort response control (RFC 2891)
+)
+
+// sortKey is a single key of a server side sort request.
+type sortKey struct {
+	attribute string              // The attribute to sort on
+	rule      *query.MatchingRule // The ordering rule used to compare values
+	reverse   bool                // Sort in descending rather than ascending order
+}
+
+// sortRequest is a decoded server side sort request control.
+type sortRequest struct {
+	keys     []sortKey           // The sort keys, most significant first
+	critical bool                // The client requires the results to be sorted
+	result   ldap.LDAPResultCode // The result of the sort, success unless the request can't be honoured
+	failed   string              // The attribute which caused the sort to fail, if any
+}
+
+// sortControl decodes the server side sort request control from the request, or returns nil
+// if there isn't one. Problems with the control are reported through the result of the sort.
+func sortControl(controls []ldap.Control) *sortRequest {
+	c, ok := ldap.FindControl(controls, controlTypeSortRequest).(*ldap.ControlString)
+	if !ok {
+		return nil
+	}
+
+	request := &sortRequest{critical: c.Criticality, result: ldap.LDAPResultSuccess}
+	keys, err := decodeSortKeys(c.ControlValue)
+	if err != nil {
+		request.result = ldap.LDAPResultProtocolError
+		return request
+	}
+
+	for _, key := range keys {
+		if key.rule == nil {
+			request.result = ldap.LDAPResultInappropriateMatching
+			request.failed = key.attribute
+			return request
+		}
+	}
+	request.keys = keys
+	return request
+}
+
+// decodeSortKeys decodes the value of a sort request control:
+//
+//	SortKeyList ::= SEQUENCE OF SEQUENCE {
+//	    attributeType   AttributeDescription,
+//	    orderingRule    [0] MatchingRuleId OPTIONAL,
+//	    reverseOrder    [1] BOOLEAN DEFAULT FALSE }
+//
+// Keys which name an unsupported ordering rule are returned with a nil rule.
+func decodeSortKeys(value string) (keys []sortKey, err error) {
+	// the ber library panics on truncated packets.
+	defer func() {
+		if r := recover(); r != nil {
+			keys, err = nil, fmt.Errorf("malformed sort request control")
+		}
+	}()
+
+	if value == "" {
+		return nil, fmt.Errorf("empty sort request control")
+	}
+
+	packet := ber.DecodePacket([]byte(value))
+	if packet.TagType != ber.TypeConstructed || len(packet.Children) == 0 {
+		return nil, fmt.Errorf("sort request control must contain at least one key")
+	}
+
+	for _, child := range packet.Children {
+		if child.TagType != ber.TypeConstructed || len(child.Children) == 0 {
+			return nil, fmt.Errorf("malformed sort key")
+		}
+		attribute, ok := child.Children[0].Value.(string)
+		if !ok || attribute == "" {
+			return nil, fmt.Errorf("sort key has no attribute type")
+		}
+
+		key := sortKey{attribute: attribute, rule: query.OrderingMatch(attribute)}
+
+		for _, option := range child.Children[1:] {
+			if option.ClassType != ber.ClassContext {
+				return nil, fmt.Errorf("malformed sort key")
+			}
+			switch option.Tag {
+			case 0:
+				key.rule = query.LookupMatchingRule(option.Data.String())
+				if key.rule != nil && key.rule.Kind != query.OrderingRule {
+					key.rule = nil
+				}
+			case 1:
+				key.reverse = len(option.Data.Bytes()) > 0 && option.Data.Bytes()[0] != 0
+			default:
+				return nil, fmt.Errorf("malformed sort key")
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// response returns the sort response control to send back with the search result.
+func (r *sortRequest) response() ldap.Control {
+	return &controlSortResponse{result: r.result, attribute: r.failed}
+}
+
+// controlSortResponse is the server side sort response control.
+type controlSortResponse struct {
+	result    ldap.LDAPResultCode // The result of the sort
+	attribute string              // The attribute which caused the sort to fail, if any
+}
+
+// GetControlType returns the OID of the sort response control.
+func (c *controlSortResponse) GetControlType() string {
+	return controlTypeSortResponse
+}
+
+// Encode encodes the control as a BER packet.
+func (c *controlSortResponse) Encode() *ber.Packet {
+	value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SortResult")
+	value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, uint64(c.result), "sortResult"))
+	if c.attribute != "" {
+		value.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, c.attribute, "attributeType"))
+	}
+
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, controlTypeSortResponse, "Control Type (Sort Response)"))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(value.Bytes()), "Control Value"))
+	return packet
+}
+
+// String returns a human readable description of the control.
+func (c *controlSortResponse) String() string {
+	return fmt.Sprintf("Control Type: Sort Response (%q)  Result: %d  Attribute: %s", controlTypeSortResponse, c.result, c.attribute)
+}
+
+// sortEntries sorts the entries using the keys. For each key an entry is ordered by the lowest
+// of its values, and entries without a usable value are treated as larger than all other values,
+// so they come last unless the key reverses the order (RFC 2891 section 1.1).
+func sortEntries(entries []*ldap.Entry, keys []sortKey) {
+	values := make(map[*ldap.Entry][]*string, len(entries))
+	for _, entry := range entries {
+		least := make([]*string, len(keys))
+		for i, key := range keys {
+			least[i] = leastValue(entry, key)
+		}
+		values[entry] = least
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := values[entries[i]], values[entries[j]]
+		for k, key := range keys {
+			var c int
+			switch {
+			case a[k] == nil && b[k] == nil:
+				continue
+			case a[k] == nil:
+				c = 1
+			case b[k] == nil:
+				c = -1
+			default:
+				c, _ = key.rule.Compare(*a[k], *b[k])
+			}
+			if c == 0 {
+				continue
+			}
+			if key.reverse {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+// leastValue returns the lowest value of the sort key's attribute in the entry, or nil if the
+// entry has no values which are valid for the key's ordering rule.
+func leastValue(entry *ldap.Entry, key sortKey) *string {
+	var least *string
+	for _, a := range entry.Attributes {
+		if !strings.EqualFold(a.Name, key.attribute) {
+			continue
+		}
+		for i := range a.Values {
+			value := a.Values[i]
+			if _, ok := key.rule.Compare(value, value); !ok {
+				continue
+			}
+			if least == nil {
+				least = &value
+			} else if c, _ := key.rule.Compare(value, *least); c < 0 {
+				least = &value
+			}
+		}
+	}
+	return least
+}
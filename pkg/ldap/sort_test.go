@@ -0,0 +1,213 @@
+package ldap
+
+import (
+	"testing"
+
+	ber "github.com/nmcclain/asn1-ber"
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+)
+
+const sortConfig = `
+dn: dc=home,dc=lab
+dc: home
+objectClass: domain
+---
+dn: cn=carol,dc=home,dc=lab
+cn: carol
+sn: Brown
+uidNumber: "1000"
+objectClass: inetOrgPerson
+---
+dn: cn=alice,dc=home,dc=lab
+cn: alice
+sn: smith
+uidNumber: "20"
+objectClass: inetOrgPerson
+---
+dn: cn=bob,dc=home,dc=lab
+cn: bob
+sn: Adams
+uidNumber: "300"
+objectClass: inetOrgPerson
+---
+dn: cn=dave,dc=home,dc=lab
+cn: dave
+sn: Brown
+objectClass: inetOrgPerson
+`
+
+// testSortKey is a sort key as sent by a client.
+type testSortKey struct {
+	attribute string
+	rule      string
+	reverse   bool
+}
+
+// newSortControl builds a sort request control the way a client would encode it.
+func newSortControl(critical bool, keys ...testSortKey) *ldap.ControlString {
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SortKeyList")
+	for _, key := range keys {
+		k := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SortKey")
+		k.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, key.attribute, "attributeType"))
+		if key.rule != "" {
+			k.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, key.rule, "orderingRule"))
+		}
+		if key.reverse {
+			k.AppendChild(ber.NewBoolean(ber.ClassContext, ber.TypePrimitive, 1, true, "reverseOrder"))
+		}
+		list.AppendChild(k)
+	}
+	return ldap.NewControlString(controlTypeSortRequest, critical, string(list.Bytes()))
+}
+
+// sortedSearch searches for the people in the sort configuration using the sort keys.
+func sortedSearch(t *testing.T, s *Server, control ldap.Control) ldap.ServerSearchResult {
+	request := ldap.SearchRequest{BaseDN: "dc=home,dc=lab", Scope: ldap.ScopeSingleLevel, Filter: "(objectClass=inetOrgPerson)", Attributes: []string{"cn"}, Controls: []ldap.Control{control}}
+	result, _ := s.Search("", request, testConn(t))
+	return result
+}
+
+func TestSortedSearch(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, sortConfig, DefaultSettings())
+
+	// sn ignores case by default, ties are broken by the next key and missing values sort last.
+	result := sortedSearch(t, s, newSortControl(false, testSortKey{attribute: "sn"}, testSortKey{attribute: "cn", reverse: true}))
+	Ω(result.ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(dns(result)).Should(gomega.Equal([]string{"cn=bob,dc=home,dc=lab", "cn=dave,dc=home,dc=lab", "cn=carol,dc=home,dc=lab", "cn=alice,dc=home,dc=lab"}))
+
+	// the sort attribute doesn't have to be one of the requested attributes.
+	Ω(result.Entries[0].Attributes).Should(gomega.HaveLen(1))
+
+	// uidNumber is compared as an integer.
+	result = sortedSearch(t, s, newSortControl(false, testSortKey{attribute: "uidNumber"}))
+	Ω(dns(result)).Should(gomega.Equal([]string{"cn=alice,dc=home,dc=lab", "cn=bob,dc=home,dc=lab", "cn=carol,dc=home,dc=lab", "cn=dave,dc=home,dc=lab"}))
+
+	// unless the client asks for another ordering rule.
+	result = sortedSearch(t, s, newSortControl(false, testSortKey{attribute: "uidNumber", rule: "caseExactOrderingMatch"}))
+	Ω(dns(result)).Should(gomega.Equal([]string{"cn=carol,dc=home,dc=lab", "cn=alice,dc=home,dc=lab", "cn=bob,dc=home,dc=lab", "cn=dave,dc=home,dc=lab"}))
+
+	// a missing value is larger than any other, so it comes first in reverse order.
+	result = sortedSearch(t, s, newSortControl(false, testSortKey{attribute: "uidNumber", reverse: true}))
+	Ω(dns(result)).Should(gomega.Equal([]string{"cn=dave,dc=home,dc=lab", "cn=carol,dc=home,dc=lab", "cn=bob,dc=home,dc=lab", "cn=alice,dc=home,dc=lab"}))
+
+	// case exact ordering puts upper case before lower case.
+	result = sortedSearch(t, s, newSortControl(false, testSortKey{attribute: "sn", rule: "2.5.13.6", reverse: true}, testSortKey{attribute: "cn"}))
+	Ω(dns(result)).Should(gomega.Equal([]string{"cn=alice,dc=home,dc=lab", "cn=carol,dc=home,dc=lab", "cn=dave,dc=home,dc=lab", "cn=bob,dc=home,dc=lab"}))
+
+	// the response control reports success.
+	response, ok := ldap.FindControl(result.Controls, controlTypeSortResponse).(*controlSortResponse)
+	Ω(ok).Should(gomega.BeTrue())
+	Ω(response.result).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+}
+
+func TestSortedSearchUnsupportedRule(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, sortConfig, DefaultSettings())
+
+	// a non-critical control is ignored but the failure is reported.
+	result := sortedSearch(t, s, newSortControl(false, testSortKey{attribute: "sn", rule: "madeUpOrderingMatch"}))
+	Ω(result.ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(result.Entries).Should(gomega.HaveLen(4))
+	response := ldap.FindControl(result.Controls, controlTypeSortResponse).(*controlSortResponse)
+	Ω(response.result).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultInappropriateMatching)))
+	Ω(response.attribute).Should(gomega.Equal("sn"))
+
+	// a critical control fails the search.
+	result = sortedSearch(t, s, newSortControl(true, testSortKey{attribute: "sn", rule: "madeUpOrderingMatch"}))
+	Ω(result.ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultUnavailableCriticalExtension)))
+	Ω(result.Entries).Should(gomega.BeEmpty())
+
+	// as does a malformed one.
+	result = sortedSearch(t, s, ldap.NewControlString(controlTypeSortRequest, true, "\x30\x05\x30"))
+	Ω(result.ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultUnavailableCriticalExtension)))
+}
+
+func TestSortedSearchLimits(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, sortConfig, DefaultSettings())
+	conn := testConn(t)
+
+	// the size limit applies after sorting, so the first entries of the sorted result are returned.
+	request := ldap.SearchRequest{BaseDN: "dc=home,dc=lab", Scope: ldap.ScopeSingleLevel, Filter: "(objectClass=inetOrgPerson)", SizeLimit: 2, Controls: []ldap.Control{newSortControl(false, testSortKey{attribute: "cn"})}}
+	result, _ := s.Search("", request, conn)
+	Ω(result.ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSizeLimitExceeded)))
+	Ω(dns(result)).Should(gomega.Equal([]string{"cn=alice,dc=home,dc=lab", "cn=bob,dc=home,dc=lab"}))
+
+	// pages follow the sorted order.
+	paging := ldap.NewControlPaging(3)
+	request = ldap.SearchRequest{BaseDN: "dc=home,dc=lab", Scope: ldap.ScopeSingleLevel, Filter: "(objectClass=inetOrgPerson)", Controls: []ldap.Control{paging, newSortControl(false, testSortKey{attribute: "cn", reverse: true})}}
+	result, _ = s.Search("", request, conn)
+	Ω(dns(result)).Should(gomega.Equal([]string{"cn=dave,dc=home,dc=lab", "cn=carol,dc=home,dc=lab", "cn=bob,dc=home,dc=lab"}))
+	Ω(ldap.FindControl(result.Controls, controlTypeSortResponse)).ShouldNot(gomega.BeNil())
+
+	paging.SetCookie(pagingControl(result.Controls).Cookie)
+	result, _ = s.Search("", request, conn)
+	Ω(dns(result)).Should(gomega.Equal([]string{"cn=alice,dc=home,dc=lab"}))
+}
+
+func TestSortedSearchHidesUnreadableValues(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	settings := DefaultSettings()
+	settings.SensitiveAttributes = []string{"sn"}
+	s := newTestServer(t, sortConfig, settings)
+
+	// sorting on an attribute the client can't read must not reveal its values.
+	result := sortedSearch(t, s, newSortControl(false, testSortKey{attribute: "sn"}))
+	Ω(dns(result)).Should(gomega.Equal([]string{"cn=carol,dc=home,dc=lab", "cn=alice,dc=home,dc=lab", "cn=bob,dc=home,dc=lab", "cn=dave,dc=home,dc=lab"}))
+}
+
+func TestSortedPagedSearch(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, sortConfig, DefaultSettings())
+	conn := testConn(t)
+
+	paging := ldap.NewControlPaging(2)
+	request := ldap.SearchRequest{BaseDN: "dc=home,dc=lab", Scope: ldap.ScopeSingleLevel, Filter: "(objectClass=inetOrgPerson)", Attributes: []string{"cn"}, Controls: []ldap.Control{paging, newSortControl(false, testSortKey{attribute: "cn"})}}
+
+	// every page carries the sort response, not only the first one.
+	result, _ := s.Search("", request, conn)
+	Ω(dns(result)).Should(gomega.Equal([]string{"cn=alice,dc=home,dc=lab", "cn=bob,dc=home,dc=lab"}))
+	Ω(ldap.FindControl(result.Controls, controlTypeSortResponse)).ShouldNot(gomega.BeNil())
+
+	paging.SetCookie(pagingControl(result.Controls).Cookie)
+	result, _ = s.Search("", request, conn)
+	Ω(dns(result)).Should(gomega.Equal([]string{"cn=carol,dc=home,dc=lab", "cn=dave,dc=home,dc=lab"}))
+	response, ok := ldap.FindControl(result.Controls, controlTypeSortResponse).(*controlSortResponse)
+	Ω(ok).Should(gomega.BeTrue())
+	Ω(response.result).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+}
+
+func TestSortResponseReachesClient(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	client := serve(t, newTestServer(t, sortConfig, DefaultSettings()))
+
+	request := ldap.NewSearchRequest("dc=home,dc=lab", ldap.ScopeSingleLevel, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=inetOrgPerson)", []string{"cn"}, []ldap.Control{newSortControl(true, testSortKey{attribute: "cn"})})
+	result, err := client.Search(request)
+	Ω(err).Should(gomega.BeNil())
+	Ω(result.Entries).Should(gomega.HaveLen(4))
+	Ω(result.Entries[0].DN).Should(gomega.Equal("cn=alice,dc=home,dc=lab"))
+
+	response, ok := ldap.FindControl(result.Controls, controlTypeSortResponse).(*ldap.ControlString)
+	Ω(ok).Should(gomega.BeTrue())
+	Ω(ber.DecodePacket([]byte(response.ControlValue)).Children[0].Value).Should(gomega.Equal(uint64(ldap.LDAPResultSuccess)))
+}
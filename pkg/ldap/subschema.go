@@ -0,0 +1,24 @@
+package ldap
+
+import (
+	"strings"
+
+	"github.com/nmcclain/ldap"
+)
+
+// subschemaDN is the name of the subentry which publishes the schema.
+const subschemaDN = "cn=Subschema"
+
+// withSubschemaSubentry adds the subschemaSubentry operational attribute to each entry, so
+// clients can find the schema which governs it (RFC 4512 section 4.2).
+func withSubschemaSubentry(entries []*ldap.Entry) {
+	for _, entry := range entries {
+		present := false
+		for _, a := range entry.Attributes {
+			present = present || strings.EqualFold(a.Name, "subschemaSubentry")
+		}
+		if !present {
+			entry.Attributes = append(entry.Attributes, &ldap.EntryAttribute{Name: "subschemaSubentry", Values: []string{subschemaDN}})
+		}
+	}
+}
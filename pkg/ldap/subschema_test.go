@@ -0,0 +1,55 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+)
+
+func TestSubschemaSubentry(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, testConfig, DefaultSettings())
+	conn := testConn(t)
+
+	// entries name the subentry which governs them.
+	result, _ := s.Search("", ldap.SearchRequest{BaseDN: "cn=user,ou=users,dc=home,dc=lab", Scope: ldap.ScopeBaseObject, Filter: "(objectClass=*)", Attributes: []string{"subschemaSubentry"}}, conn)
+	Ω(result.Entries[0].GetAttributeValue("subschemaSubentry")).Should(gomega.Equal(subschemaDN))
+
+	// the schema is only returned when asked for.
+	request := ldap.SearchRequest{BaseDN: "CN=subschema", Scope: ldap.ScopeBaseObject, Filter: "(objectClass=subschema)"}
+	result, _ = s.Search("", request, conn)
+	Ω(dns(result)).Should(gomega.Equal([]string{subschemaDN}))
+	Ω(result.Entries[0].GetAttributeValues("attributeTypes")).Should(gomega.BeEmpty())
+
+	request.Attributes = []string{"attributeTypes", "objectClasses", "ldapSyntaxes", "matchingRules"}
+	result, _ = s.Search("", request, conn)
+	entry := result.Entries[0]
+	Ω(entry.GetAttributeValues("attributeTypes")).Should(gomega.ContainElement(gomega.HavePrefix("( 2.5.4.3 NAME ( 'cn' 'commonName' )")))
+	Ω(entry.GetAttributeValues("objectClasses")).Should(gomega.ContainElement(gomega.HavePrefix("( 2.16.840.1.113730.3.2.2 NAME 'inetOrgPerson'")))
+	Ω(entry.GetAttributeValues("ldapSyntaxes")).ShouldNot(gomega.BeEmpty())
+	Ω(entry.GetAttributeValues("matchingRules")).ShouldNot(gomega.BeEmpty())
+
+	// the subentry isn't part of the directory data.
+	result, _ = s.Search("", ldap.SearchRequest{BaseDN: "dc=home,dc=lab", Scope: ldap.ScopeWholeSubtree, Filter: "(objectClass=*)"}, conn)
+	Ω(dns(result)).ShouldNot(gomega.ContainElement(subschemaDN))
+	Ω(dns(result)).Should(gomega.HaveLen(4))
+}
+
+func TestSubschemaSubentryReachesClient(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	settings := DefaultSettings()
+	settings.Schema.AttributeTypes = []string{"( 1.3.6.1.4.1.99999.1.1 NAME 'jellyfinId' EQUALITY caseExactMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.15 SINGLE-VALUE )"}
+	client := serve(t, newTestServer(t, testConfig, settings))
+
+	result, err := client.Search(ldap.NewSearchRequest("cn=Subschema", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"attributeTypes"}, nil))
+	Ω(err).Should(gomega.BeNil())
+	Ω(result.Entries).Should(gomega.HaveLen(1))
+	Ω(result.Entries[0].GetAttributeValues("attributeTypes")).Should(gomega.ContainElement(settings.Schema.AttributeTypes[0]))
+}
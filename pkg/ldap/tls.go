@@ -0,0 +1,187 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	ber "github.com/nmcclain/asn1-ber"
+	"github.com/nmcclain/ldap"
+)
+
+// extensionStartTLS is the OID of the StartTLS extended operation (RFC 4511 section 4.14).
+const extensionStartTLS = "1.3.6.1.4.1.1466.20037"
+
+// tlsHandshakeTimeout is how long a client has to complete the TLS handshake after StartTLS.
+var tlsHandshakeTimeout = 10 * time.Second
+
+// TLSSettings configures LDAPS and the StartTLS extended operation. TLS is only available
+// when a certificate and key are configured.
+type TLSSettings struct {
+	CertFile       string `yaml:"certFile"`       // The PEM encoded certificate chain of the server
+	KeyFile        string `yaml:"keyFile"`        // The PEM encoded private key of the server
+	MinVersion     string `yaml:"minVersion"`     // The oldest TLS version allowed, 1.2 if not set
+	Port           int    `yaml:"port"`           // The port to serve LDAPS on, if any
+	RequireForBind bool   `yaml:"requireForBind"` // Refuse simple binds with a password on unencrypted connections
+
+	ClientCAFile        string               `yaml:"clientCAFile"`        // The PEM encoded CAs which client certificates must be issued by
+	CertificateMappings []CertificateMapping `yaml:"certificateMappings"` // How client certificates map onto entries for SASL EXTERNAL binds
+}
+
+// tlsVersions maps the versions which can be configured onto their crypto/tls constants.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// enabled returns true if a certificate has been configured.
+func (t TLSSettings) enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+// certificateStore holds the certificate presented to TLS clients, which is replaced when the
+// certificate and key files change.
+type certificateStore struct {
+	certFile string           // The PEM encoded certificate chain
+	keyFile  string           // The PEM encoded private key
+	lock     sync.RWMutex     // A lock protecting the certificate
+	current  *tls.Certificate // The certificate last loaded from the files
+}
+
+// load reads the certificate and key files and, if they form a valid pair, makes them the
+// certificate presented to new clients. The previous certificate is kept if they don't.
+func (c *certificateStore) load() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load the tls certificate: %w", err)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.current = &cert
+	return nil
+}
+
+// get returns the current certificate. It is used as the GetCertificate function of the TLS
+// configuration so every handshake sees the latest certificate.
+func (c *certificateStore) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.current, nil
+}
+
+// newTLSConfig loads the certificate and key and creates the TLS configuration used for LDAPS
+// and StartTLS, along with the store holding the certificate. It returns nil if TLS isn't
+// configured.
+func newTLSConfig(settings TLSSettings) (*tls.Config, *certificateStore, error) {
+	if !settings.enabled() {
+		if settings.Port != 0 || settings.RequireForBind || settings.ClientCAFile != "" {
+			return nil, nil, fmt.Errorf("tls needs a certFile and keyFile")
+		}
+		return nil, nil, nil
+	}
+	if settings.CertFile == "" || settings.KeyFile == "" {
+		return nil, nil, fmt.Errorf("tls needs both a certFile and a keyFile")
+	}
+
+	version := uint16(tls.VersionTLS12)
+	if settings.MinVersion != "" {
+		v, ok := tlsVersions[settings.MinVersion]
+		if !ok {
+			return nil, nil, fmt.Errorf("tls minVersion must be 1.0, 1.1, 1.2 or 1.3 not '%s'", settings.MinVersion)
+		}
+		version = v
+	}
+
+	store := &certificateStore{certFile: settings.CertFile, keyFile: settings.KeyFile}
+	if err := store.load(); err != nil {
+		return nil, nil, err
+	}
+	config := &tls.Config{GetCertificate: store.get, MinVersion: version}
+
+	// clients may present a certificate to use SASL EXTERNAL but don't have to.
+	if settings.ClientCAFile != "" {
+		data, err := ioutil.ReadFile(settings.ClientCAFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read the tls client cas: %w", err)
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(data) {
+			return nil, nil, fmt.Errorf("tls clientCAFile '%s' does not hold any certificates", settings.ClientCAFile)
+		}
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return config, store, nil
+}
+
+// isSecure returns true if the connection is protected by TLS, either because the client
+// connected to the LDAPS port or because it used StartTLS.
+func isSecure(c net.Conn) bool {
+	switch wrapped := c.(type) {
+	case *conn:
+		return wrapped.secure
+	case *tls.Conn:
+		return true
+	}
+	return false
+}
+
+// isStartTLS returns true if the request is a StartTLS extended request.
+func isStartTLS(request *ber.Packet) bool {
+	if request.Tag != ldap.ApplicationExtendedRequest || len(request.Children) == 0 {
+		return false
+	}
+	name := request.Children[0]
+	return name.ClassType == ber.ClassContext && name.Tag == 0 && name.Data.String() == extensionStartTLS
+}
+
+// startTLS answers a StartTLS request and, if it was accepted, performs the TLS handshake and
+// carries on the connection over TLS. The ldap library never sees the request, as it can't
+// change the connection it is reading from.
+func (c *conn) startTLS(messageID uint64) error {
+	// TLS can't be started twice (RFC 4511 section 4.14.1).
+	code := ldap.LDAPResultCode(ldap.LDAPResultSuccess)
+	if c.secure {
+		code = ldap.LDAPResultOperationsError
+	}
+	if _, err := c.Conn.Write(encodeExtendedResponse(messageID, code, extensionStartTLS).Bytes()); err != nil {
+		return err
+	}
+	if code != ldap.LDAPResultSuccess {
+		return nil
+	}
+
+	// a client which never starts the handshake mustn't hold on to the connection.
+	if err := c.Conn.SetDeadline(time.Now().Add(tlsHandshakeTimeout)); err != nil {
+		return err
+	}
+	secured := tls.Server(c.Conn, c.tlsConfig)
+	if err := secured.Handshake(); err != nil {
+		return err
+	}
+	if err := c.Conn.SetDeadline(time.Time{}); err != nil {
+		return err
+	}
+	c.Conn = secured
+	c.secure = true
+	return nil
+}
+
+// encodeExtendedResponse builds an ExtendedResponse message naming the extended operation.
+func encodeExtendedResponse(messageID uint64, code ldap.LDAPResultCode, name string) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationExtendedResponse, nil, "Extended response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, uint64(code), "resultCode: "))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN: "))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ldap.LDAPResultCodeMap[code], "errorMessage: "))
+	response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 10, name, "responseName: "))
+	packet.AppendChild(response)
+	return packet
+}
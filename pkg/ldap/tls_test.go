@@ -0,0 +1,265 @@
+package ldap
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	ber "github.com/nmcclain/asn1-ber"
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+)
+
+// testCertificate writes a self signed certificate for 127.0.0.1 and its key to temporary
+// files, and returns their names along with a pool trusting the certificate.
+func testCertificate(t *testing.T, name string) (certFile, keyFile string, pool *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = writeConfig(t, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	keyFile = writeConfig(t, string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})))
+	cert, _ := x509.ParseCertificate(der)
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
+
+// tlsSettings returns settings which enable TLS with a new certificate, and a pool trusting it.
+func tlsSettings(t *testing.T) (Settings, *x509.CertPool) {
+	certFile, keyFile, pool := testCertificate(t, "dapper")
+	settings := DefaultSettings()
+	settings.TLS = TLSSettings{CertFile: certFile, KeyFile: keyFile}
+	return settings, pool
+}
+
+// listenTLS starts serving LDAPS for the server on a random port and returns its address.
+func listenTLS(t *testing.T, s *Server) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go s.s.Serve(s.listener(tls.NewListener(ln, s.tlsConfig), true))
+	return ln.Addr().String()
+}
+
+// sendRequest sends a request over a raw connection and returns the result code of the response.
+func sendRequest(t *testing.T, c net.Conn, messageID uint64, request *ber.Packet) (ldap.LDAPResultCode, *ber.Packet) {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	packet.AppendChild(request)
+	if _, err := c.Write(packet.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := ber.ReadPacket(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := response.Children[1]
+	return ldap.LDAPResultCode(op.Children[0].Value.(uint64)), op
+}
+
+// startTLSRequest builds a StartTLS extended request.
+func startTLSRequest() *ber.Packet {
+	request := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationExtendedRequest, nil, "Start TLS")
+	request.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, extensionStartTLS, "TLS Extended Command"))
+	return request
+}
+
+// simpleBindRequest builds a simple bind request.
+func simpleBindRequest(name, password string) *ber.Packet {
+	request := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationBindRequest, nil, "Bind Request")
+	request.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 3, "Version"))
+	request.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "User Name"))
+	request.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, password, "Password"))
+	return request
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	config, store, err := newTLSConfig(TLSSettings{})
+	Ω(err).Should(gomega.BeNil())
+	Ω(config).Should(gomega.BeNil())
+	Ω(store).Should(gomega.BeNil())
+
+	certFile, keyFile, _ := testCertificate(t, "dapper")
+	config, store, err = newTLSConfig(TLSSettings{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3"})
+	Ω(err).Should(gomega.BeNil())
+	Ω(config.MinVersion).Should(gomega.Equal(uint16(tls.VersionTLS13)))
+	cert, err := config.GetCertificate(nil)
+	Ω(err).Should(gomega.BeNil())
+	Ω(cert).Should(gomega.Equal(store.current))
+
+	_, _, err = newTLSConfig(TLSSettings{CertFile: certFile})
+	Ω(err).ShouldNot(gomega.BeNil())
+	_, _, err = newTLSConfig(TLSSettings{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.4"})
+	Ω(err).ShouldNot(gomega.BeNil())
+	_, _, err = newTLSConfig(TLSSettings{CertFile: keyFile, KeyFile: certFile})
+	Ω(err).ShouldNot(gomega.BeNil())
+	_, _, err = newTLSConfig(TLSSettings{Port: 636})
+	Ω(err).ShouldNot(gomega.BeNil())
+	_, _, err = newTLSConfig(TLSSettings{RequireForBind: true})
+	Ω(err).ShouldNot(gomega.BeNil())
+}
+
+func TestCertificateReload(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	settings, pool := tlsSettings(t)
+	s := newTestServer(t, testConfig, settings)
+	addr := listenTLS(t, s)
+
+	// dial connects and returns the common name of the certificate presented by the server.
+	dial := func(pool *x509.CertPool) (*tls.Conn, string) {
+		c, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: pool})
+		Ω(err).Should(gomega.BeNil())
+		return c, c.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+	before, name := dial(pool)
+	defer before.Close()
+	Ω(name).Should(gomega.Equal("dapper"))
+
+	// rotate the certificate by replacing the contents of the files.
+	certFile, keyFile, rotated := testCertificate(t, "rotated")
+	writeFile(t, settings.TLS.CertFile, readFile(t, certFile))
+	writeFile(t, settings.TLS.KeyFile, readFile(t, keyFile))
+	Ω(s.certificate.load()).Should(gomega.Succeed())
+
+	after, name := dial(rotated)
+	defer after.Close()
+	Ω(name).Should(gomega.Equal("rotated"))
+
+	// the connection made before the rotation is still usable.
+	code, _ := sendRequest(t, before, 1, simpleBindRequest("cn=user,ou=users,dc=home,dc=lab", "test"))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+
+	// a certificate which doesn't match its key is refused and the previous one is kept.
+	writeFile(t, settings.TLS.KeyFile, readFile(t, settings.TLS.CertFile))
+	Ω(s.certificate.load()).ShouldNot(gomega.Succeed())
+	again, name := dial(rotated)
+	defer again.Close()
+	Ω(name).Should(gomega.Equal("rotated"))
+}
+
+func TestLDAPS(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	settings, pool := tlsSettings(t)
+	settings.TLS.RequireForBind = true
+	s := newTestServer(t, testConfig, settings)
+
+	client, err := ldap.DialTLS("tcp", listenTLS(t, s), &tls.Config{RootCAs: pool})
+	Ω(err).Should(gomega.BeNil())
+	defer client.Close()
+
+	Ω(client.Bind("cn=user,ou=users,dc=home,dc=lab", "test")).Should(gomega.Succeed())
+	result, err := client.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"supportedExtension"}, nil))
+	Ω(err).Should(gomega.BeNil())
+	Ω(result.Entries[0].GetAttributeValues("supportedExtension")).Should(gomega.ContainElement(extensionStartTLS))
+}
+
+func TestStartTLS(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	settings, pool := tlsSettings(t)
+	settings.TLS.RequireForBind = true
+	addr := listen(t, newTestServer(t, testConfig, settings))
+
+	c, err := net.Dial("tcp", addr)
+	Ω(err).Should(gomega.BeNil())
+	defer c.Close()
+
+	// passwords aren't accepted until the connection is encrypted.
+	code, _ := sendRequest(t, c, 1, simpleBindRequest("cn=user,ou=users,dc=home,dc=lab", "test"))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultConfidentialityRequired)))
+
+	code, response := sendRequest(t, c, 2, startTLSRequest())
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(response.Children[len(response.Children)-1].Data.String()).Should(gomega.Equal(extensionStartTLS))
+
+	secured := tls.Client(c, &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"})
+	Ω(secured.Handshake()).Should(gomega.Succeed())
+	code, _ = sendRequest(t, secured, 3, simpleBindRequest("cn=user,ou=users,dc=home,dc=lab", "test"))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+
+	// TLS can't be started twice.
+	code, _ = sendRequest(t, secured, 4, startTLSRequest())
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultOperationsError)))
+}
+
+func TestStartTLSHandshakeTimeout(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	timeout := tlsHandshakeTimeout
+	tlsHandshakeTimeout = 100 * time.Millisecond
+	defer func() { tlsHandshakeTimeout = timeout }()
+
+	settings, _ := tlsSettings(t)
+	c, err := net.Dial("tcp", listen(t, newTestServer(t, testConfig, settings)))
+	Ω(err).Should(gomega.BeNil())
+	defer c.Close()
+
+	code, _ := sendRequest(t, c, 1, startTLSRequest())
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+
+	// a client which doesn't start the handshake is disconnected.
+	Ω(c.SetReadDeadline(time.Now().Add(5 * time.Second))).Should(gomega.Succeed())
+	_, err = c.Read(make([]byte, 1))
+	Ω(err).Should(gomega.Equal(io.EOF))
+}
+
+func TestStartTLSWithoutCertificate(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	c, err := net.Dial("tcp", listen(t, newTestServer(t, testConfig, DefaultSettings())))
+	Ω(err).Should(gomega.BeNil())
+	defer c.Close()
+
+	// without a certificate StartTLS is an unknown extended operation and binds work as before.
+	code, _ := sendRequest(t, c, 1, startTLSRequest())
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultProtocolError)))
+	code, _ = sendRequest(t, c, 2, simpleBindRequest("cn=user,ou=users,dc=home,dc=lab", "test"))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+}
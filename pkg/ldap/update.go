@@ -0,0 +1,569 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	ber "github.com/nmcclain/asn1-ber"
+	"github.com/nmcclain/ldap"
+	"github.com/rs/zerolog"
+	"github.com/shauncampbell/dapper/pkg/dn"
+	"github.com/shauncampbell/dapper/pkg/password"
+	"github.com/shauncampbell/dapper/pkg/query"
+	"gopkg.in/yaml.v2"
+)
+
+// updateRequest is an add or modify request as the client sent it.
+type updateRequest struct {
+	dn            string                 // The DN of the entry being added or modified
+	attributes    []*ldap.EntryAttribute // The attributes of the entry being added
+	modifications []modification         // The changes to the entry being modified, in the order they are made
+}
+
+// modification is one of the changes in a modify request.
+type modification struct {
+	operation uint64   // One of ldap.AddAttribute, ldap.DeleteAttribute or ldap.ReplaceAttribute
+	attribute string   // The name of the attribute being changed
+	values    []string // The values being added, deleted or replaced
+}
+
+// decodeUpdate decodes an add or modify request. It returns nil if the request is malformed,
+// which is reported to the client as a protocol error.
+func decodeUpdate(request *ber.Packet) *updateRequest {
+	if len(request.Children) != 2 {
+		return nil
+	}
+	name, ok := request.Children[0].Value.(string)
+	if !ok {
+		return nil
+	}
+
+	update := &updateRequest{dn: name}
+	for _, item := range request.Children[1].Children {
+		if request.Tag == ldap.ApplicationAddRequest {
+			attribute, ok := decodeAttribute(item)
+			if !ok {
+				return nil
+			}
+			update.attributes = append(update.attributes, attribute)
+			continue
+		}
+
+		if len(item.Children) != 2 {
+			return nil
+		}
+		operation, ok := item.Children[0].Value.(uint64)
+		if !ok {
+			return nil
+		}
+		attribute, ok := decodeAttribute(item.Children[1])
+		if !ok {
+			return nil
+		}
+		update.modifications = append(update.modifications, modification{operation: operation, attribute: attribute.Name, values: attribute.Values})
+	}
+	return update
+}
+
+// decodeAttribute decodes an attribute type along with its set of values.
+func decodeAttribute(packet *ber.Packet) (*ldap.EntryAttribute, bool) {
+	if len(packet.Children) != 2 {
+		return nil, false
+	}
+	name, ok := packet.Children[0].Value.(string)
+	if !ok {
+		return nil, false
+	}
+
+	attribute := &ldap.EntryAttribute{Name: name, Values: make([]string, 0, len(packet.Children[1].Children))}
+	for _, value := range packet.Children[1].Children {
+		v, ok := value.Value.(string)
+		if !ok {
+			return nil, false
+		}
+		attribute.Values = append(attribute.Values, v)
+	}
+	return attribute, true
+}
+
+// takeUpdate returns the add or modify request being handled, if it arrived over a wrapped
+// connection and was well formed.
+func takeUpdate(c net.Conn) (*updateRequest, bool) {
+	wrapped, ok := c.(*conn)
+	if !ok {
+		return nil, false
+	}
+	wrapped.lock.Lock()
+	defer wrapped.lock.Unlock()
+	update := wrapped.update
+	wrapped.update = nil
+	return update, update != nil
+}
+
+// modifications returns the changes of a modify request handed over by the ldap library. The
+// library groups the changes by operation, so they are made in that order.
+func modifications(req ldap.ModifyRequest) []modification {
+	out := make([]modification, 0, len(req.AddAttributes)+len(req.DeleteAttributes)+len(req.ReplaceAttributes))
+	for _, a := range req.AddAttributes {
+		out = append(out, modification{operation: ldap.AddAttribute, attribute: a.AttrType, values: a.AttrVals})
+	}
+	for _, a := range req.DeleteAttributes {
+		out = append(out, modification{operation: ldap.DeleteAttribute, attribute: a.AttrType, values: a.AttrVals})
+	}
+	for _, a := range req.ReplaceAttributes {
+		out = append(out, modification{operation: ldap.ReplaceAttribute, attribute: a.AttrType, values: a.AttrVals})
+	}
+	return out
+}
+
+// Add is a handler for an incoming add request.
+func (s *Server) Add(boundDN string, req ldap.AddRequest, conn net.Conn) (ldap.LDAPResultCode, error) {
+	logger := s.Logger.With().Str("operation", "add").Str("request_ip", conn.RemoteAddr().String()).Str("bindDN", boundDN).Logger()
+
+	// the library doesn't pass on the attributes of the entry, only the connection has them, and
+	// the connection only leaves them out if the request is malformed.
+	update, ok := takeUpdate(conn)
+	if !ok {
+		logger.Error().Msgf("add request was rejected because it is malformed")
+		return ldap.LDAPResultProtocolError, nil
+	}
+	logger.Debug().Str("dn", update.dn).Msgf("request received")
+	return s.add(boundDN, update.dn, update.attributes, logger), nil
+}
+
+// Modify is a handler for an incoming modify request.
+func (s *Server) Modify(boundDN string, req ldap.ModifyRequest, conn net.Conn) (ldap.LDAPResultCode, error) {
+	logger := s.Logger.With().Str("operation", "modify").Str("request_ip", conn.RemoteAddr().String()).Str("bindDN", boundDN).Logger()
+	logger.Debug().Str("dn", req.Dn).Msgf("request received")
+
+	changes := modifications(req)
+	if update, ok := takeUpdate(conn); ok {
+		changes = update.modifications
+	}
+	return s.modify(boundDN, req.Dn, changes, logger), nil
+}
+
+// Delete is a handler for an incoming delete request.
+func (s *Server) Delete(boundDN, deleteDN string, conn net.Conn) (ldap.LDAPResultCode, error) {
+	logger := s.Logger.With().Str("operation", "delete").Str("request_ip", conn.RemoteAddr().String()).Str("bindDN", boundDN).Logger()
+	logger.Debug().Str("dn", deleteDN).Msgf("request received")
+	return s.delete(boundDN, deleteDN, logger), nil
+}
+
+// add creates a new entry in the configuration file of the naming context it belongs to.
+func (s *Server) add(boundDN, name string, attributes []*ldap.EntryAttribute, logger zerolog.Logger) ldap.LDAPResultCode {
+	parsed, nc, code := s.updateTarget(name, logger)
+	if code != ldap.LDAPResultSuccess {
+		return code
+	}
+
+	document := yaml.MapSlice{{Key: "dn", Value: name}}
+	for _, a := range attributes {
+		if !isWritable(a.Name) {
+			logger.Error().Msgf("add request was rejected because the attribute '%s' can't be written", a.Name)
+			return ldap.LDAPResultConstraintViolation
+		}
+		values := documentValues(document, a.Name)
+		for _, v := range a.Values {
+			if containsValue(a.Name, values, v) {
+				logger.Error().Msgf("add request was rejected because the attribute '%s' repeats a value", a.Name)
+				return ldap.LDAPResultAttributeOrValueExists
+			}
+			stored, err := s.storedValue(a.Name, v)
+			if err != nil {
+				logger.Error().Err(err).Msgf("add request was rejected because of an invalid value of the attribute '%s'", a.Name)
+				return valueResult(err)
+			}
+			values = append(values, stored)
+		}
+		document = setDocumentValues(document, a.Name, values)
+	}
+
+	// the values in the rdn are part of the entry whether the client lists them or not (RFC 4511 section 4.7).
+	for _, ava := range parsed[0] {
+		if values := documentValues(document, ava.Type); !containsValue(ava.Type, values, ava.Value) {
+			document = setDocumentValues(document, ava.Type, append(values, ava.Value))
+		}
+	}
+
+	code, err := s.commit(nc, func(documents []yaml.MapSlice) ([]yaml.MapSlice, ldap.LDAPResultCode) {
+		d := s.snapshot()
+		if d.find(name) != nil || findDocument(documents, parsed) >= 0 {
+			logger.Error().Msgf("add request was rejected because the entry already exists")
+			return nil, ldap.LDAPResultEntryAlreadyExists
+		}
+		if !parsed.Equal(nc.suffix) && d.nodes[parsed.Parent().Normalize()] == nil {
+			logger.Error().Msgf("add request was rejected because the parent entry does not exist")
+			return nil, ldap.LDAPResultNoSuchObject
+		}
+
+		entry, code := s.checkUpdate(boundDN, document, logger)
+		if code != ldap.LDAPResultSuccess {
+			return nil, code
+		}
+		names := []string{entryAttribute}
+		for _, a := range entry.Attributes {
+			names = append(names, a.Name)
+		}
+		if !s.mayWrite(boundDN, entry, names...) {
+			logger.Error().Msgf("add request was rejected because the client may not write the entry")
+			return nil, ldap.LDAPResultInsufficientAccessRights
+		}
+		return append(documents, document), ldap.LDAPResultSuccess
+	})
+	if err != nil {
+		logger.Error().Err(err).Msgf("failed to add the entry to '%s'", nc.configFile)
+	} else if code == ldap.LDAPResultSuccess {
+		logger.Info().Msgf("entry '%s' was added", name)
+	}
+	return code
+}
+
+// modify makes the changes to an entry in order. Either all of the changes are made or none
+// of them are.
+func (s *Server) modify(boundDN, name string, changes []modification, logger zerolog.Logger) ldap.LDAPResultCode {
+	parsed, nc, code := s.updateTarget(name, logger)
+	if code != ldap.LDAPResultSuccess {
+		return code
+	}
+
+	code, err := s.commit(nc, func(documents []yaml.MapSlice) ([]yaml.MapSlice, ldap.LDAPResultCode) {
+		i := findDocument(documents, parsed)
+		current := s.snapshot().find(name)
+		if i < 0 || current == nil {
+			logger.Error().Msgf("modify request was rejected because the entry does not exist")
+			return nil, ldap.LDAPResultNoSuchObject
+		}
+
+		document := documents[i]
+		for _, m := range changes {
+			if !isWritable(m.attribute) {
+				logger.Error().Msgf("modify request was rejected because the attribute '%s' can't be written", m.attribute)
+				return nil, ldap.LDAPResultConstraintViolation
+			}
+			if !s.mayWrite(boundDN, current, m.attribute) {
+				logger.Error().Msgf("modify request was rejected because the client may not write the attribute '%s'", m.attribute)
+				return nil, ldap.LDAPResultInsufficientAccessRights
+			}
+			values, code := s.modifyValues(documentValues(document, m.attribute), m, logger)
+			if code != ldap.LDAPResultSuccess {
+				return nil, code
+			}
+			document = setDocumentValues(document, m.attribute, values)
+		}
+
+		// the values in the rdn can only be changed by renaming the entry.
+		for _, ava := range parsed[0] {
+			if !containsValue(ava.Type, documentValues(document, ava.Type), ava.Value) {
+				logger.Error().Msgf("modify request was rejected because it removes the rdn value of '%s'", ava.Type)
+				return nil, ldap.LDAPResultNotAllowedOnRDN
+			}
+		}
+
+		if _, code := s.checkUpdate(boundDN, document, logger); code != ldap.LDAPResultSuccess {
+			return nil, code
+		}
+		documents[i] = document
+		return documents, ldap.LDAPResultSuccess
+	})
+	if err != nil {
+		logger.Error().Err(err).Msgf("failed to write the modified entry to '%s'", nc.configFile)
+	} else if code == ldap.LDAPResultSuccess {
+		logger.Info().Msgf("entry '%s' was modified", name)
+	}
+	return code
+}
+
+// modifyValues makes a single change to the values of an attribute.
+func (s *Server) modifyValues(values []string, m modification, logger zerolog.Logger) ([]string, ldap.LDAPResultCode) {
+	switch m.operation {
+	case ldap.AddAttribute:
+	case ldap.DeleteAttribute:
+		if len(values) == 0 {
+			logger.Error().Msgf("modify request was rejected because the attribute '%s' does not exist", m.attribute)
+			return nil, ldap.LDAPResultNoSuchAttribute
+		}
+		// deleting an attribute without listing values deletes all of them.
+		if len(m.values) == 0 {
+			return nil, ldap.LDAPResultSuccess
+		}
+		for _, v := range m.values {
+			kept := make([]string, 0, len(values))
+			for _, stored := range values {
+				if !sameValue(m.attribute, stored, v) {
+					kept = append(kept, stored)
+				}
+			}
+			if len(kept) == len(values) {
+				logger.Error().Msgf("modify request was rejected because the attribute '%s' does not have the value to delete", m.attribute)
+				return nil, ldap.LDAPResultNoSuchAttribute
+			}
+			values = kept
+		}
+		return values, ldap.LDAPResultSuccess
+	case ldap.ReplaceAttribute:
+		values = nil
+	default:
+		logger.Error().Msgf("modify request was rejected because of the unknown operation %d", m.operation)
+		return nil, ldap.LDAPResultProtocolError
+	}
+
+	for _, v := range m.values {
+		if containsValue(m.attribute, values, v) {
+			logger.Error().Msgf("modify request was rejected because the attribute '%s' already has the value", m.attribute)
+			return nil, ldap.LDAPResultAttributeOrValueExists
+		}
+		stored, err := s.storedValue(m.attribute, v)
+		if err != nil {
+			logger.Error().Err(err).Msgf("modify request was rejected because of an invalid value of the attribute '%s'", m.attribute)
+			return nil, valueResult(err)
+		}
+		values = append(values, stored)
+	}
+	return values, ldap.LDAPResultSuccess
+}
+
+// delete removes a leaf entry from the configuration file of the naming context it belongs to.
+func (s *Server) delete(boundDN, name string, logger zerolog.Logger) ldap.LDAPResultCode {
+	parsed, nc, code := s.updateTarget(name, logger)
+	if code != ldap.LDAPResultSuccess {
+		return code
+	}
+
+	code, err := s.commit(nc, func(documents []yaml.MapSlice) ([]yaml.MapSlice, ldap.LDAPResultCode) {
+		n := s.snapshot().nodes[parsed.Normalize()]
+		if n == nil || n.entry == nil || findDocument(documents, parsed) < 0 {
+			logger.Error().Msgf("delete request was rejected because the entry does not exist")
+			return nil, ldap.LDAPResultNoSuchObject
+		}
+		if len(n.children) > 0 {
+			logger.Error().Msgf("delete request was rejected because the entry has subordinates")
+			return nil, ldap.LDAPResultNotAllowedOnNonLeaf
+		}
+		if !s.mayWrite(boundDN, n.entry, entryAttribute) {
+			logger.Error().Msgf("delete request was rejected because the client may not write the entry")
+			return nil, ldap.LDAPResultInsufficientAccessRights
+		}
+
+		// an entry repeated in the file would otherwise come back.
+		kept := make([]yaml.MapSlice, 0, len(documents))
+		for _, document := range documents {
+			if other, ok := documentDN(document); !ok || !dnEqual(other, parsed) {
+				kept = append(kept, document)
+			}
+		}
+		return kept, ldap.LDAPResultSuccess
+	})
+	if err != nil {
+		logger.Error().Err(err).Msgf("failed to remove the entry from '%s'", nc.configFile)
+	} else if code == ldap.LDAPResultSuccess {
+		logger.Info().Msgf("entry '%s' was deleted", name)
+	}
+	return code
+}
+
+// updateTarget parses the DN of the entry being changed and finds the naming context which
+// holds it.
+func (s *Server) updateTarget(name string, logger zerolog.Logger) (dn.DN, *namingContext, ldap.LDAPResultCode) {
+	parsed, err := dn.Parse(name)
+	if err != nil {
+		logger.Error().Err(err).Msgf("request was rejected because the dn is invalid")
+		return nil, nil, ldap.LDAPResultInvalidDNSyntax
+	}
+	nc := s.contextFor(parsed)
+	if nc == nil {
+		logger.Error().Msgf("request was rejected because the dn is not within a naming context")
+		return nil, nil, ldap.LDAPResultUnwillingToPerform
+	}
+	return parsed, nc, ldap.LDAPResultSuccess
+}
+
+// checkUpdate returns the entry an added or modified document holds, as long as the entry
+// conforms to the schema.
+func (s *Server) checkUpdate(boundDN string, document yaml.MapSlice, logger zerolog.Logger) (*ldap.Entry, ldap.LDAPResultCode) {
+	name, _ := documentDN(document)
+	entry, err := parseUser(name, documentUser(document), logger)
+	if err != nil {
+		logger.Error().Err(err).Msgf("request was rejected because the entry is invalid")
+		return nil, ldap.LDAPResultInvalidAttributeSyntax
+	}
+	if err := s.validateEntries([]*ldap.Entry{entry}); err != nil {
+		logger.Error().Err(err).Msgf("request was rejected because the entry does not conform to the schema")
+		return nil, ldap.LDAPResultObjectClassViolation
+	}
+	return entry, ldap.LDAPResultSuccess
+}
+
+// commit changes the entries in the configuration file of the naming context. The change is
+// given the entries as they are written in the file, and either returns them changed or a
+// result code other than success to leave the file alone. Changes are serialised, the changed
+// entries must load before the file is replaced and the directory is only published once the
+// file has been replaced.
+func (s *Server) commit(nc *namingContext, change func(documents []yaml.MapSlice) ([]yaml.MapSlice, ldap.LDAPResultCode)) (ldap.LDAPResultCode, error) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	documents, err := readDocuments(nc.configFile)
+	if err != nil {
+		return ldap.LDAPResultOperationsError, err
+	}
+	documents, code := change(documents)
+	if code != ldap.LDAPResultSuccess {
+		return code, nil
+	}
+
+	users := make([]interface{}, 0, len(documents))
+	for _, document := range documents {
+		users = append(users, documentUser(document))
+	}
+	err = s.loadConfiguration(nc.configFile, users, func() error {
+		return writeDocuments(nc.configFile, documents)
+	})
+	if err != nil {
+		return ldap.LDAPResultOperationsError, err
+	}
+	return ldap.LDAPResultSuccess, nil
+}
+
+// mayWrite returns true if the bound DN may write all of the attributes of the entry.
+func (s *Server) mayWrite(boundDN string, entry *ldap.Entry, attributes ...string) bool {
+	d := s.access.decide(boundDN, entry)
+	for _, attribute := range attributes {
+		if d.level(attribute) < accessWrite {
+			return false
+		}
+	}
+	return true
+}
+
+// valueResult returns the result code for a value which storedValue refused. A password hash
+// which would take too much work to check is well formed, so it breaks a constraint rather
+// than the syntax.
+func valueResult(err error) ldap.LDAPResultCode {
+	if errors.Is(err, password.ErrTooCostly) {
+		return ldap.LDAPResultConstraintViolation
+	}
+	return ldap.LDAPResultInvalidAttributeSyntax
+}
+
+// storedValue returns the value as it is written to the configuration file. Passwords are
+// hashed unless the client already hashed them.
+func (s *Server) storedValue(attribute, value string) (string, error) {
+	if !strings.EqualFold(attribute, passwordAttribute) {
+		return value, nil
+	}
+	if _, ok := password.Scheme([]byte(value)); ok {
+		return parsePassword(value)
+	}
+
+	scheme := s.settings.PasswordScheme
+	if scheme == "" {
+		scheme = "SSHA"
+	}
+	encoder, ok := password.Lookup(scheme)
+	if !ok {
+		return "", fmt.Errorf("password scheme '%s' is not supported", scheme)
+	}
+	encoded, err := encoder.Encode([]byte(value))
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// isWritable returns true if clients may change the attribute. Operational attributes are
+// maintained by the server and the dn is the name of the entry rather than an attribute.
+func isWritable(attribute string) bool {
+	return !isOperational(attribute) && !strings.EqualFold(attribute, "dn")
+}
+
+// sameValue returns true if a value held by an entry and a value sent by a client are the same
+// according to the equality rule of the attribute. Passwords are the same if the stored
+// password was hashed from the one the client sent.
+func sameValue(attribute, stored, value string) bool {
+	if strings.EqualFold(attribute, passwordAttribute) {
+		return stored == value || password.Matches([]byte(stored), []byte(value))
+	}
+	return query.EqualityMatch(attribute).Equal(stored, value)
+}
+
+// containsValue returns true if any of the values held by an entry is the same as the value.
+func containsValue(attribute string, values []string, value string) bool {
+	for _, stored := range values {
+		if sameValue(attribute, stored, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// findDocument returns the position of the last entry with the DN in the configuration file,
+// which is the one the directory holds, or -1 if there is no such entry.
+func findDocument(documents []yaml.MapSlice, name dn.DN) int {
+	found := -1
+	for i, document := range documents {
+		if other, ok := documentDN(document); ok && dnEqual(other, name) {
+			found = i
+		}
+	}
+	return found
+}
+
+// documentUser converts an entry read by readDocuments into the form ReloadConfiguration reads.
+func documentUser(document yaml.MapSlice) map[string]interface{} {
+	user := make(map[string]interface{}, len(document))
+	for _, item := range document {
+		if key, ok := item.Key.(string); ok {
+			user[key] = item.Value
+		}
+	}
+	return user
+}
+
+// documentValues returns the values of the attribute in an entry read by readDocuments. The
+// name of the attribute is matched without regard to case.
+func documentValues(document yaml.MapSlice, attribute string) []string {
+	for _, item := range document {
+		if key, ok := item.Key.(string); ok && strings.EqualFold(key, attribute) {
+			values, _ := parseAttributeValues(item.Value)
+			return values
+		}
+	}
+	return nil
+}
+
+// setDocumentValues replaces the values of the attribute in an entry read by readDocuments,
+// keeping its place in the entry. An attribute without values is removed.
+func setDocumentValues(document yaml.MapSlice, attribute string, values []string) yaml.MapSlice {
+	var value interface{}
+	switch len(values) {
+	case 0:
+	case 1:
+		value = values[0]
+	default:
+		list := make([]interface{}, 0, len(values))
+		for _, v := range values {
+			list = append(list, v)
+		}
+		value = list
+	}
+
+	out := make(yaml.MapSlice, 0, len(document)+1)
+	found := false
+	for _, item := range document {
+		if key, ok := item.Key.(string); ok && strings.EqualFold(key, attribute) {
+			found = true
+			if value != nil {
+				out = append(out, yaml.MapItem{Key: key, Value: value})
+			}
+			continue
+		}
+		out = append(out, item)
+	}
+	if !found && value != nil {
+		out = append(out, yaml.MapItem{Key: attribute, Value: value})
+	}
+	return out
+}
@@ -0,0 +1,349 @@
+package ldap
+
+import (
+	"net"
+	"testing"
+
+	ber "github.com/nmcclain/asn1-ber"
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+	"github.com/rs/zerolog"
+)
+
+// adminSettings makes cn=root,dc=home,dc=lab an admin.
+func adminSettings() Settings {
+	settings := DefaultSettings()
+	settings.AdminDNs = []string{"cn=root,dc=home,dc=lab"}
+	return settings
+}
+
+// attributes builds the attributes of an add request.
+func attributes(pairs ...interface{}) []*ldap.EntryAttribute {
+	out := make([]*ldap.EntryAttribute, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		out = append(out, &ldap.EntryAttribute{Name: pairs[i].(string), Values: pairs[i+1].([]string)})
+	}
+	return out
+}
+
+func TestAdd(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, testConfig, adminSettings())
+	filename := s.contexts[0].configFile
+	admin, logger := "cn=root,dc=home,dc=lab", zerolog.Nop()
+
+	// the rdn value is added to the entry and the password is hashed before it is stored.
+	code := s.add(admin, "uid=new,ou=users,dc=home,dc=lab", attributes("objectClass", []string{"inetOrgPerson"}, "cn", []string{"New"}, "sn", []string{"User"}, "userPassword", []string{"secret"}), logger)
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	entry := s.snapshot().find("uid=new,ou=users,dc=home,dc=lab")
+	Ω(entry).ShouldNot(gomega.BeNil())
+	Ω(entry.GetAttributeValues("uid")).Should(gomega.Equal([]string{"new"}))
+	Ω(readFile(t, filename)).Should(gomega.ContainSubstring("dn: cn=user,ou=users,dc=home,dc=lab\n"))
+	Ω(readFile(t, filename)).Should(gomega.ContainSubstring("dn: uid=new,ou=users,dc=home,dc=lab\nobjectClass: inetOrgPerson\ncn: New\n"))
+	Ω(readFile(t, filename)).ShouldNot(gomega.ContainSubstring("secret"))
+	code, _ = s.Bind("uid=new,ou=users,dc=home,dc=lab", "secret", testConn(t))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+
+	// the file is reloaded as it was written.
+	s.ReloadConfiguration(filename)
+	Ω(s.snapshot().find("uid=new,ou=users,dc=home,dc=lab")).ShouldNot(gomega.BeNil())
+
+	code = s.add(admin, "UID=New,ou=users,dc=home,dc=lab", attributes("objectClass", []string{"inetOrgPerson"}), logger)
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultEntryAlreadyExists)))
+
+	code = s.add(admin, "cn=orphan,ou=missing,dc=home,dc=lab", attributes("objectClass", []string{"person"}), logger)
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultNoSuchObject)))
+
+	code = s.add(admin, "cn=elsewhere,dc=example,dc=com", attributes("objectClass", []string{"person"}), logger)
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultUnwillingToPerform)))
+
+	code = s.add(admin, "cn=bad,dc=home,dc=lab", attributes("createTimestamp", []string{"20200101000000Z"}), logger)
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultConstraintViolation)))
+
+	// a password hash which would take too much work to check is refused.
+	code = s.add(admin, "cn=bad,dc=home,dc=lab", attributes("objectClass", []string{"person"}, "sn", []string{"Bad"}, "userPassword", []string{"{ARGON2}$argon2id$v=19$m=4294967295,t=1,p=1$c2FsdA$a2V5a2V5"}), logger)
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultConstraintViolation)))
+
+	code = s.add(admin, "cn=bad,dc=home,dc=lab", attributes("mail", []string{"a@home.lab", "A@HOME.LAB"}), logger)
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultAttributeOrValueExists)))
+	Ω(s.snapshot().find("cn=bad,dc=home,dc=lab")).Should(gomega.BeNil())
+}
+
+func TestAddAccessControl(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	// without rules nobody but the admins may write.
+	s := newTestServer(t, testConfig, adminSettings())
+	for _, boundDN := range []string{"", "cn=user,ou=users,dc=home,dc=lab"} {
+		code := s.add(boundDN, "cn=new,dc=home,dc=lab", attributes("objectClass", []string{"person"}, "sn", []string{"New"}), zerolog.Nop())
+		Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultInsufficientAccessRights)))
+	}
+	Ω(readFile(t, s.contexts[0].configFile)).Should(gomega.Equal(testConfig))
+
+	// a rule can let users manage a subtree.
+	settings := adminSettings()
+	settings.ACL = []ACLRule{
+		{To: ACLTarget{DN: "ou=users,dc=home,dc=lab", Scope: "children"}, By: []ACLGrant{{Who: "cn=user,ou=users,dc=home,dc=lab", Access: "write"}}},
+		{By: []ACLGrant{{Who: "*", Access: "read"}}},
+	}
+	s = newTestServer(t, testConfig, settings)
+	code := s.add("cn=user,ou=users,dc=home,dc=lab", "cn=new,ou=users,dc=home,dc=lab", attributes("objectClass", []string{"person"}, "sn", []string{"New"}), zerolog.Nop())
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	code = s.add("cn=user,ou=users,dc=home,dc=lab", "cn=new,dc=home,dc=lab", attributes("objectClass", []string{"person"}, "sn", []string{"New"}), zerolog.Nop())
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultInsufficientAccessRights)))
+}
+
+func TestAddSchemaViolation(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	settings := adminSettings()
+	settings.Schema.Validation = schemaReject
+	s := newTestServer(t, `
+dn: dc=home,dc=lab
+dc: home
+objectClass: domain
+`, settings)
+
+	// a person must have a surname.
+	code := s.add("cn=root,dc=home,dc=lab", "cn=new,dc=home,dc=lab", attributes("objectClass", []string{"person"}), zerolog.Nop())
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultObjectClassViolation)))
+	code = s.add("cn=root,dc=home,dc=lab", "cn=new,dc=home,dc=lab", attributes("objectClass", []string{"person"}, "sn", []string{"New"}), zerolog.Nop())
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+}
+
+func TestModify(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, testConfig, adminSettings())
+	filename := s.contexts[0].configFile
+	admin, name, logger := "cn=root,dc=home,dc=lab", "cn=user,ou=users,dc=home,dc=lab", zerolog.Nop()
+
+	// changes are made in order, so deleting an attribute and adding it back replaces it.
+	code := s.modify(admin, name, []modification{
+		{operation: ldap.AddAttribute, attribute: "mail", values: []string{"user@home.lab", "old@home.lab"}},
+		{operation: ldap.DeleteAttribute, attribute: "mail"},
+		{operation: ldap.AddAttribute, attribute: "mail", values: []string{"new@home.lab"}},
+		{operation: ldap.DeleteAttribute, attribute: "objectClass", values: []string{"PosixAccount"}},
+		{operation: ldap.ReplaceAttribute, attribute: "SN", values: []string{"Changed"}},
+	}, logger)
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	entry := s.snapshot().find(name)
+	Ω(entry.GetAttributeValues("mail")).Should(gomega.Equal([]string{"new@home.lab"}))
+	Ω(entry.GetAttributeValues("objectClass")).Should(gomega.Equal([]string{"inetOrgPerson"}))
+	Ω(entry.GetAttributeValues("sn")).Should(gomega.Equal([]string{"Changed"}))
+	Ω(readFile(t, filename)).Should(gomega.ContainSubstring("objectClass: inetOrgPerson\nsn: Changed\nuserPassword: '{SSHA}I8wq1+4gyJVJUtQW96JGcmCL46ADyPnW'\nmail: new@home.lab\n"))
+
+	// passwords are hashed and can be deleted by their value.
+	code = s.modify(name, name, []modification{
+		{operation: ldap.DeleteAttribute, attribute: "userPassword", values: []string{"test"}},
+		{operation: ldap.AddAttribute, attribute: "userPassword", values: []string{"changed"}},
+	}, logger)
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultInsufficientAccessRights)))
+	code = s.modify(admin, name, []modification{
+		{operation: ldap.DeleteAttribute, attribute: "userPassword", values: []string{"test"}},
+		{operation: ldap.AddAttribute, attribute: "userPassword", values: []string{"changed"}},
+	}, logger)
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(readFile(t, filename)).ShouldNot(gomega.ContainSubstring("changed"))
+	code, _ = s.Bind(name, "test", testConn(t))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultInvalidCredentials)))
+	code, _ = s.Bind(name, "changed", testConn(t))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+
+	// a request which fails part way through changes nothing.
+	before := readFile(t, filename)
+	for _, test := range []struct {
+		change modification
+		code   int
+	}{
+		{modification{operation: ldap.AddAttribute, attribute: "mail", values: []string{"NEW@home.lab"}}, ldap.LDAPResultAttributeOrValueExists},
+		{modification{operation: ldap.DeleteAttribute, attribute: "mail", values: []string{"missing@home.lab"}}, ldap.LDAPResultNoSuchAttribute},
+		{modification{operation: ldap.DeleteAttribute, attribute: "telephoneNumber"}, ldap.LDAPResultNoSuchAttribute},
+		{modification{operation: ldap.ReplaceAttribute, attribute: "cn", values: []string{"other"}}, ldap.LDAPResultNotAllowedOnRDN},
+		{modification{operation: ldap.ReplaceAttribute, attribute: "modifyTimestamp", values: []string{"20200101000000Z"}}, ldap.LDAPResultConstraintViolation},
+		{modification{operation: ldap.ReplaceAttribute, attribute: "dn", values: []string{"cn=other,dc=home,dc=lab"}}, ldap.LDAPResultConstraintViolation},
+		{modification{operation: 7, attribute: "mail"}, ldap.LDAPResultProtocolError},
+		{modification{operation: ldap.ReplaceAttribute, attribute: "userPassword", values: []string{"{CRYPT}$6$rounds=999999999$saltstring$svn8UoSVapNtMuq1ukKS4tPQ"}}, ldap.LDAPResultConstraintViolation},
+		{modification{operation: ldap.ReplaceAttribute, attribute: "userPassword", values: []string{"{UNKNOWN}secret"}}, ldap.LDAPResultInvalidAttributeSyntax},
+	} {
+		code = s.modify(admin, name, []modification{{operation: ldap.AddAttribute, attribute: "description", values: []string{"changed"}}, test.change}, logger)
+		Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(test.code)), test.change.attribute)
+	}
+	Ω(readFile(t, filename)).Should(gomega.Equal(before))
+	Ω(s.snapshot().find(name).GetAttributeValues("description")).Should(gomega.BeEmpty())
+
+	code = s.modify(admin, "cn=missing,dc=home,dc=lab", []modification{{operation: ldap.ReplaceAttribute, attribute: "sn", values: []string{"Missing"}}}, logger)
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultNoSuchObject)))
+}
+
+func TestModifySelf(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	settings := DefaultSettings()
+	settings.ACL = []ACLRule{
+		{To: ACLTarget{Attrs: []string{"userPassword", "mail"}}, By: []ACLGrant{{Who: "self", Access: "write"}, {Who: "anonymous", Access: "auth"}}},
+		{By: []ACLGrant{{Who: "*", Access: "read"}}},
+	}
+	settings.PasswordScheme = "pbkdf2-sha256"
+	s := newTestServer(t, testConfig, settings)
+	name := "cn=user,ou=users,dc=home,dc=lab"
+
+	// users may change their own password and mail but nothing else.
+	code := s.modify(name, name, []modification{{operation: ldap.ReplaceAttribute, attribute: "userPassword", values: []string{"changed"}}, {operation: ldap.ReplaceAttribute, attribute: "mail", values: []string{"user@home.lab"}}}, zerolog.Nop())
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(s.snapshot().find(name).GetAttributeValue("userPassword")).Should(gomega.HavePrefix("{PBKDF2-SHA256}"))
+
+	code = s.modify(name, name, []modification{{operation: ldap.ReplaceAttribute, attribute: "sn", values: []string{"Changed"}}}, zerolog.Nop())
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultInsufficientAccessRights)))
+	code = s.modify("cn=root,dc=home,dc=lab", name, []modification{{operation: ldap.ReplaceAttribute, attribute: "mail", values: []string{"root@home.lab"}}}, zerolog.Nop())
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultInsufficientAccessRights)))
+}
+
+func TestDelete(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, testConfig, adminSettings())
+	filename := s.contexts[0].configFile
+	admin, logger := "cn=root,dc=home,dc=lab", zerolog.Nop()
+
+	Ω(s.delete(admin, "ou=users,dc=home,dc=lab", logger)).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultNotAllowedOnNonLeaf)))
+	Ω(s.delete("cn=user,ou=users,dc=home,dc=lab", "cn=user,ou=users,dc=home,dc=lab", logger)).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultInsufficientAccessRights)))
+	Ω(s.delete(admin, "cn=missing,dc=home,dc=lab", logger)).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultNoSuchObject)))
+	Ω(s.delete(admin, "cn=Subschema", logger)).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultUnwillingToPerform)))
+	Ω(readFile(t, filename)).Should(gomega.Equal(testConfig))
+
+	Ω(s.delete(admin, "CN=User,OU=Users,DC=Home,DC=Lab", logger)).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(s.delete(admin, "ou=users,dc=home,dc=lab", logger)).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(s.snapshot().find("cn=user,ou=users,dc=home,dc=lab")).Should(gomega.BeNil())
+	Ω(readFile(t, filename)).ShouldNot(gomega.ContainSubstring("users"))
+	Ω(readFile(t, filename)).Should(gomega.ContainSubstring("dn: cn=root,dc=home,dc=lab"))
+}
+
+// attributePacket builds an attribute type along with its set of values.
+func attributePacket(name string, values ...string) *ber.Packet {
+	attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+	attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+	set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+	for _, v := range values {
+		set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "Value"))
+	}
+	attribute.AppendChild(set)
+	return attribute
+}
+
+// addRequest builds an add request.
+func addRequest(name string, attributes ...*ber.Packet) *ber.Packet {
+	request := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationAddRequest, nil, "Add Request")
+	request.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Entry"))
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for _, a := range attributes {
+		list.AppendChild(a)
+	}
+	request.AppendChild(list)
+	return request
+}
+
+// modifyRequest builds a modify request with a single change.
+func modifyRequest(name string, operation uint64, attribute *ber.Packet) *ber.Packet {
+	request := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationModifyRequest, nil, "Modify Request")
+	request.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Object"))
+	changes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Changes")
+	change := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Change")
+	change.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, operation, "Operation"))
+	change.AppendChild(attribute)
+	changes.AppendChild(change)
+	request.AppendChild(changes)
+	return request
+}
+
+func TestUpdatesOverConnection(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, testConfig, adminSettings())
+	addr := listen(t, s)
+
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// anonymous clients may not write.
+	code, _ := sendRequest(t, c, 1, addRequest("cn=new,dc=home,dc=lab", attributePacket("objectClass", "person"), attributePacket("sn", "New")))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultInsufficientAccessRights)))
+
+	code, _ = sendRequest(t, c, 2, simpleBindRequest("cn=root,dc=home,dc=lab", "test"))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	code, _ = sendRequest(t, c, 3, addRequest("cn=new,dc=home,dc=lab", attributePacket("objectClass", "person"), attributePacket("sn", "New")))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(s.snapshot().find("cn=new,dc=home,dc=lab").GetAttributeValues("sn")).Should(gomega.Equal([]string{"New"}))
+
+	code, _ = sendRequest(t, c, 4, modifyRequest("cn=new,dc=home,dc=lab", ldap.ReplaceAttribute, attributePacket("sn", "Changed")))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(s.snapshot().find("cn=new,dc=home,dc=lab").GetAttributeValues("sn")).Should(gomega.Equal([]string{"Changed"}))
+
+	// a value which isn't a string is a protocol error, not a fault of the server.
+	malformed := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+	malformed.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "sn", "Type"))
+	values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+	values.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 1, "Value"))
+	malformed.AppendChild(values)
+	code, _ = sendRequest(t, c, 5, addRequest("cn=other,dc=home,dc=lab", attributePacket("objectClass", "person"), malformed))
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultProtocolError)))
+	Ω(s.snapshot().find("cn=other,dc=home,dc=lab")).Should(gomega.BeNil())
+
+	delete := ber.NewString(ber.ClassApplication, ber.TypePrimitive, ldap.ApplicationDelRequest, "cn=new,dc=home,dc=lab", "Del Request")
+	code, _ = sendRequest(t, c, 6, delete)
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultSuccess)))
+	Ω(s.snapshot().find("cn=new,dc=home,dc=lab")).Should(gomega.BeNil())
+	Ω(readFile(t, s.contexts[0].configFile)).ShouldNot(gomega.ContainSubstring("cn=new"))
+}
+
+func TestAddWithoutAttributes(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	// the attributes are only missing if the connection couldn't decode the request.
+	s := newTestServer(t, testConfig, adminSettings())
+	code, err := s.Add("cn=root,dc=home,dc=lab", ldap.AddRequest{}, testConn(t))
+	Ω(err).Should(gomega.BeNil())
+	Ω(code).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultProtocolError)))
+}
+
+func TestModifyWithClient(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := newTestServer(t, testConfig, adminSettings())
+	client := serve(t, s)
+	Ω(client.Bind("cn=root,dc=home,dc=lab", "test")).Should(gomega.Succeed())
+
+	request := ldap.NewModifyRequest("cn=user,ou=users,dc=home,dc=lab")
+	request.Add("mail", []string{"user@home.lab"})
+	request.Replace("sn", []string{"Changed"})
+	Ω(client.Modify(request)).Should(gomega.Succeed())
+	entry := s.snapshot().find("cn=user,ou=users,dc=home,dc=lab")
+	Ω(entry.GetAttributeValues("mail")).Should(gomega.Equal([]string{"user@home.lab"}))
+	Ω(entry.GetAttributeValues("sn")).Should(gomega.Equal([]string{"Changed"}))
+
+	err := client.Modify(ldap.NewModifyRequest("cn=missing,dc=home,dc=lab"))
+	Ω(err).ShouldNot(gomega.BeNil())
+	Ω(err.(*ldap.Error).ResultCode).Should(gomega.Equal(ldap.LDAPResultCode(ldap.LDAPResultNoSuchObject)))
+}
@@ -0,0 +1,114 @@
+package password
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	argon2DefaultTime    = 2              // The passes new passwords are encoded with
+	argon2DefaultMemory  = 19456          // The KiB of memory new passwords are encoded with
+	argon2DefaultThreads = 1              // The lanes new passwords are encoded with
+	argon2MaxTime        = 10             // The most passes a password may take to check
+	argon2MaxMemory      = 262144         // The most KiB of memory a password may take to check
+	argon2MaxThreads     = 16             // The most lanes a password may take to check
+	argon2SaltSize       = 16             // The number of bytes of salt
+	argon2KeySize        = 32             // The length of the hash
+	argon2Version        = argon2.Version // The version of Argon2, 1.3
+)
+
+// argon2Encoding is the base64 encoding used in Argon2 passwords, which leaves out the padding.
+var argon2Encoding = base64.StdEncoding.WithPadding(base64.NoPadding)
+
+// argon2Encoder stores passwords with Argon2id (RFC 9106) in the form used by the reference
+// implementation and the OpenLDAP argon2 module:
+// {ARGON2}$argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<hash>.
+type argon2Encoder struct {
+	time    uint32 // The number of passes over the memory
+	memory  uint32 // The amount of memory in KiB
+	threads uint8  // The number of lanes
+}
+
+// Encode hashes the password with a new salt.
+func (e argon2Encoder) Encode(password []byte) ([]byte, error) {
+	salt, err := makeSalt(argon2SaltSize)
+	if err != nil {
+		return nil, err
+	}
+	hash := argon2.IDKey(password, salt, e.time, e.memory, e.threads, argon2KeySize)
+	return []byte(fmt.Sprintf("{ARGON2}$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2Version, e.memory, e.time, e.threads,
+		argon2Encoding.EncodeToString(salt), argon2Encoding.EncodeToString(hash))), nil
+}
+
+// Matches hashes the password with the parameters and salt of the encoded password and
+// compares the hashes.
+func (e argon2Encoder) Matches(encoded, password []byte) bool {
+	h, ok := parseArgon2(encoded)
+	if !ok || h.tooCostly() {
+		return false
+	}
+	computed := argon2.IDKey(password, h.salt, h.time, h.memory, h.threads, uint32(len(h.hash)))
+	return subtle.ConstantTimeCompare(h.hash, computed) == 1
+}
+
+// checkCost returns ErrTooCostly if the parameters of the encoded password are over the limits.
+func (e argon2Encoder) checkCost(encoded []byte) error {
+	if h, ok := parseArgon2(encoded); ok && h.tooCostly() {
+		return ErrTooCostly
+	}
+	return nil
+}
+
+// argon2Hash is an encoded Argon2id password taken apart.
+type argon2Hash struct {
+	time    uint32 // The number of passes over the memory
+	memory  uint32 // The amount of memory in KiB
+	threads uint8  // The number of lanes
+	salt    []byte // The salt the password was hashed with
+	hash    []byte // The hash of the password
+}
+
+// parseArgon2 takes an encoded Argon2id password apart, returning false if it is malformed.
+func parseArgon2(encoded []byte) (argon2Hash, bool) {
+	rest, ok := hasScheme(encoded, "ARGON2")
+	if !ok {
+		return argon2Hash{}, false
+	}
+
+	// the parts are: "", argon2id, v=19, m=...,t=...,p=..., salt, hash
+	parts := strings.Split(string(rest), "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" || parts[2] != fmt.Sprintf("v=%d", argon2Version) {
+		return argon2Hash{}, false
+	}
+	var h argon2Hash
+	if n, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.memory, &h.time, &h.threads); err != nil || n != 3 {
+		return argon2Hash{}, false
+	}
+	if h.time < 1 || h.threads < 1 || h.memory < 8*uint32(h.threads) {
+		return argon2Hash{}, false
+	}
+	var err error
+	if h.salt, err = argon2Encoding.DecodeString(parts[4]); err != nil {
+		return argon2Hash{}, false
+	}
+	if h.hash, err = argon2Encoding.DecodeString(parts[5]); err != nil || len(h.hash) < 4 {
+		return argon2Hash{}, false
+	}
+	return h, true
+}
+
+// tooCostly returns true if checking a password against the hash would take more passes,
+// memory or lanes than allowed.
+func (h argon2Hash) tooCostly() bool {
+	return h.time > argon2MaxTime || h.memory > argon2MaxMemory || h.threads > argon2MaxThreads
+}
+
+// Identify returns true if the encoded password is an {ARGON2} Argon2id password.
+func (e argon2Encoder) Identify(encoded []byte) bool {
+	rest, ok := hasScheme(encoded, "ARGON2")
+	return ok && strings.HasPrefix(string(rest), "$argon2id$")
+}
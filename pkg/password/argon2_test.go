@@ -0,0 +1,30 @@
+package password
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+// TestArgon2Matches checks that the parameters are read from the encoded password.
+func TestArgon2Matches(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	encoded, err := argon2Encoder{time: 2, memory: 128, threads: 2}.Encode([]byte("secret"))
+	Ω(err).ShouldNot(gomega.HaveOccurred())
+	Ω(string(encoded)).Should(gomega.HavePrefix("{ARGON2}$argon2id$v=19$m=128,t=2,p=2$"))
+
+	// the default parameters are not used to check the password.
+	Ω(Matches(encoded, []byte("secret"))).Should(gomega.BeTrue())
+	Ω(Matches(encoded, []byte("Secret"))).Should(gomega.BeFalse())
+
+	changed := bytes.Replace(encoded, []byte("t=2"), []byte("t=1"), 1)
+	Ω(Matches(changed, []byte("secret"))).Should(gomega.BeFalse())
+	changed = bytes.Replace(encoded, []byte("v=19"), []byte("v=16"), 1)
+	Ω(Matches(changed, []byte("secret"))).Should(gomega.BeFalse())
+	changed = bytes.Replace(encoded, []byte("p=2"), []byte("p=0"), 1)
+	Ω(Matches(changed, []byte("secret"))).Should(gomega.BeFalse())
+}
@@ -0,0 +1,78 @@
+package password
+
+import (
+	"bytes"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	bcryptDefaultCost = bcrypt.DefaultCost // The cost new passwords are encoded with
+	bcryptMinCost     = bcrypt.MinCost     // The lowest cost bcrypt allows
+	bcryptMaxCost     = 14                 // The highest cost a password may take to check
+)
+
+// bcryptVersions are the versions of bcrypt which can be checked. They only differ in the
+// handling of passwords longer than 255 bytes, which are cut short first anyway.
+var bcryptVersions = []string{"$2a$", "$2b$", "$2y$"}
+
+// bcryptEncoder stores passwords with bcrypt. Passwords are stored as {BCRYPT}, but bcrypt
+// passwords stored as {CRYPT} are recognised too.
+type bcryptEncoder struct {
+	cost int // The cost new passwords are encoded with, the logarithm of the number of rounds
+}
+
+// Encode hashes the password with a new salt.
+func (e bcryptEncoder) Encode(password []byte) ([]byte, error) {
+	hash, err := bcrypt.GenerateFromPassword(password, e.cost)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte("{BCRYPT}"), hash...), nil
+}
+
+// Matches hashes the password with the cost and salt of the encoded password and compares
+// the hashes.
+func (e bcryptEncoder) Matches(encoded, password []byte) bool {
+	rest, ok := bcryptValue(encoded)
+	if !ok {
+		return false
+	}
+	if cost, err := bcrypt.Cost(rest); err != nil || cost > bcryptMaxCost {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword(rest, password) == nil
+}
+
+// checkCost returns ErrTooCostly if the cost of the encoded password is over the limit.
+func (e bcryptEncoder) checkCost(encoded []byte) error {
+	if rest, ok := bcryptValue(encoded); ok {
+		if cost, err := bcrypt.Cost(rest); err == nil && cost > bcryptMaxCost {
+			return ErrTooCostly
+		}
+	}
+	return nil
+}
+
+// Identify returns true if the encoded password is a {BCRYPT} or {CRYPT} bcrypt password.
+func (e bcryptEncoder) Identify(encoded []byte) bool {
+	_, ok := bcryptValue(encoded)
+	return ok
+}
+
+// bcryptValue returns the bcrypt password which follows the scheme.
+func bcryptValue(encoded []byte) ([]byte, bool) {
+	rest, ok := hasScheme(encoded, "BCRYPT")
+	if !ok {
+		rest, ok = hasScheme(encoded, "CRYPT")
+	}
+	if !ok {
+		return nil, false
+	}
+	for _, version := range bcryptVersions {
+		if bytes.HasPrefix(rest, []byte(version)) {
+			return rest, true
+		}
+	}
+	return nil, false
+}
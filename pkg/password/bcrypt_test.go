@@ -0,0 +1,44 @@
+package password
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+// TestBcryptMatches checks the test vectors of OpenBSD's bcrypt.
+func TestBcryptMatches(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	tests := map[string]string{
+		"{BCRYPT}$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW": "U*U",
+		"{BCRYPT}$2a$05$CCCCCCCCCCCCCCCCCCCCC.VGOzA784oUp/Z0DY336zx7pLYAy0lwK": "U*U*",
+		"{CRYPT}$2a$05$XXXXXXXXXXXXXXXXXXXXXOAcXxm9kjPGEMsLznoKqmqw7tc8WCx4a":  "U*U*U",
+		"{BCRYPT}$2b$05$CCCCCCCCCCCCCCCCCCCCC.7uG0VCzI2bS7j6ymqJi9CdcdxiRTWNy": "",
+	}
+	for encoded, password := range tests {
+		Ω(Matches([]byte(encoded), []byte(password))).Should(gomega.BeTrue(), encoded)
+		Ω(Matches([]byte(encoded), []byte(password+"x"))).Should(gomega.BeFalse(), encoded)
+	}
+
+	// the cost must be in range and the hash complete.
+	Ω(Matches([]byte("{BCRYPT}$2a$03$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW"), []byte("U*U"))).Should(gomega.BeFalse())
+	Ω(Matches([]byte("{BCRYPT}$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOe"), []byte("U*U"))).Should(gomega.BeFalse())
+}
+
+// TestBcryptLongPassword checks that only the first 72 bytes of the password are used.
+func TestBcryptLongPassword(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	long := bytes.Repeat([]byte("a"), 72)
+	encoded, err := bcryptEncoder{cost: bcryptMinCost}.Encode(append(long, 'b'))
+	Ω(err).ShouldNot(gomega.HaveOccurred())
+	Ω(string(encoded)).Should(gomega.HavePrefix("{BCRYPT}$2a$04$"))
+	Ω(Matches(encoded, append(long, 'c'))).Should(gomega.BeTrue())
+	Ω(Matches(encoded, long[1:])).Should(gomega.BeFalse())
+}
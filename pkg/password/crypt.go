@@ -0,0 +1,296 @@
+package password
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+const (
+	cryptAlphabet         = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz" // The base64 alphabet of crypt(3)
+	md5CryptSaltSize      = 8                                                                  // The longest salt MD5-crypt uses
+	shaCryptSaltSize      = 16                                                                 // The longest salt SHA-crypt uses
+	shaCryptDefaultRounds = 5000                                                               // The rounds used when none are given
+	shaCryptMinRounds     = 1000                                                               // The fewest rounds SHA-crypt allows
+	shaCryptMaxRounds     = 999999999                                                          // The most rounds SHA-crypt allows
+	shaCryptRoundLimit    = 1000000                                                            // The most rounds a password may take to check
+	shaCryptRoundsName    = "rounds="                                                          // Introduces the rounds in the salt
+)
+
+// cryptEncoder stores passwords in the form produced by crypt(3), as used by {CRYPT} passwords
+// migrated from /etc/shadow or OpenLDAP. MD5-crypt ($1$), SHA-256-crypt ($5$) and
+// SHA-512-crypt ($6$) are supported, and new passwords are encoded with SHA-512-crypt.
+type cryptEncoder struct{}
+
+// md5CryptOrder, shaCrypt256Order and shaCrypt512Order are the orders in which the bytes of
+// the digests are written out, most significant first in groups of three.
+var (
+	md5CryptOrder    = []int{0, 6, 12, 1, 7, 13, 2, 8, 14, 3, 9, 15, 4, 10, 5, 11}
+	shaCrypt256Order = []int{0, 10, 20, 21, 1, 11, 12, 22, 2, 3, 13, 23, 24, 4, 14, 15, 25, 5, 6, 16, 26, 27, 7, 17, 18, 28, 8, 9, 19, 29, 31, 30}
+	shaCrypt512Order = []int{
+		0, 21, 42, 22, 43, 1, 44, 2, 23, 3, 24, 45, 25, 46, 4, 47, 5, 26, 6, 27, 48, 28, 49, 7, 50, 8, 29, 9, 30, 51, 31, 52,
+		10, 53, 11, 32, 12, 33, 54, 34, 55, 13, 56, 14, 35, 15, 36, 57, 37, 58, 16, 59, 17, 38, 18, 39, 60, 40, 61, 19, 62, 20, 41, 63,
+	}
+)
+
+// Encode hashes the password with SHA-512-crypt and a new salt.
+func (e cryptEncoder) Encode(password []byte) ([]byte, error) {
+	salt, err := makeSalt(shaCryptSaltSize)
+	if err != nil {
+		return nil, err
+	}
+	for i := range salt {
+		salt[i] = cryptAlphabet[salt[i]&0x3f]
+	}
+	return []byte("{CRYPT}" + shaCrypt("$6$", sha512.New, shaCrypt512Order, password, string(salt), "")), nil
+}
+
+// Matches hashes the password with the algorithm and salt of the encoded password and compares
+// the results.
+func (e cryptEncoder) Matches(encoded, password []byte) bool {
+	rest, ok := hasScheme(encoded, "CRYPT")
+	if !ok || cryptTooCostly(string(rest)) {
+		return false
+	}
+	computed, err := crypt(password, string(rest))
+	return err == nil && subtle.ConstantTimeCompare([]byte(computed), rest) == 1
+}
+
+// checkCost returns ErrTooCostly if the encoded password has more rounds than allowed.
+func (e cryptEncoder) checkCost(encoded []byte) error {
+	if rest, ok := hasScheme(encoded, "CRYPT"); ok && cryptTooCostly(string(rest)) {
+		return ErrTooCostly
+	}
+	return nil
+}
+
+// Identify returns true if the encoded password is a {CRYPT} password using one of the
+// supported algorithms.
+func (e cryptEncoder) Identify(encoded []byte) bool {
+	rest, ok := hasScheme(encoded, "CRYPT")
+	return ok && (bytes.HasPrefix(rest, []byte("$1$")) || bytes.HasPrefix(rest, []byte("$5$")) || bytes.HasPrefix(rest, []byte("$6$")))
+}
+
+// crypt hashes the password using the algorithm and salt named in the setting, which is an
+// encoded password or just its salt, e.g. $6$rounds=10000$saltsalt.
+func crypt(password []byte, setting string) (string, error) {
+	id, rounds, salt, err := parseCryptSetting(setting)
+	if err != nil {
+		return "", err
+	}
+
+	switch id {
+	case "$1$":
+		return md5Crypt(password, salt), nil
+	case "$5$":
+		return shaCrypt(id, sha256.New, shaCrypt256Order, password, salt, rounds), nil
+	case "$6$":
+		return shaCrypt(id, sha512.New, shaCrypt512Order, password, salt, rounds), nil
+	}
+	return "", fmt.Errorf("unsupported crypt algorithm '%s'", id)
+}
+
+// parseCryptSetting splits a setting into the algorithm, the rounds if they were given and the salt.
+func parseCryptSetting(setting string) (id, rounds, salt string, err error) {
+	if len(setting) < 3 || setting[0] != '$' || setting[2] != '$' {
+		return "", "", "", fmt.Errorf("unsupported crypt algorithm")
+	}
+	id, rest := setting[:3], setting[3:]
+	if id != "$1$" && strings.HasPrefix(rest, shaCryptRoundsName) {
+		end := strings.IndexByte(rest, '$')
+		if end < 0 {
+			return "", "", "", fmt.Errorf("crypt rounds are not followed by a salt")
+		}
+		rounds, rest = rest[len(shaCryptRoundsName):end], rest[end+1:]
+	}
+	salt = rest
+	if end := strings.IndexByte(rest, '$'); end >= 0 {
+		salt = rest[:end]
+	}
+	return id, rounds, salt, nil
+}
+
+// cryptTooCostly returns true if checking a password against the setting would take more
+// rounds than allowed. MD5-crypt always takes the same number of rounds.
+func cryptTooCostly(setting string) bool {
+	id, rounds, _, err := parseCryptSetting(setting)
+	return err == nil && id != "$1$" && shaCryptRounds(rounds) > shaCryptRoundLimit
+}
+
+// cryptEncode writes out the digest in the base64 alphabet of crypt(3), taking the bytes in
+// the given order. Each group of three bytes becomes four characters, least significant bits
+// first, and any bytes left over at the end become two or three characters.
+func cryptEncode(digest []byte, order []int) string {
+	b := &strings.Builder{}
+	for i := 0; i < len(order); i += 3 {
+		w, n := uint(0), 1
+		for j := i; j < i+3 && j < len(order); j++ {
+			w = w<<8 | uint(digest[order[j]])
+			n++
+		}
+		for ; n > 0; n-- {
+			b.WriteByte(cryptAlphabet[w&0x3f])
+			w >>= 6
+		}
+	}
+	return b.String()
+}
+
+// md5Crypt is the MD5 based crypt(3) from FreeBSD.
+func md5Crypt(password []byte, salt string) string {
+	if len(salt) > md5CryptSaltSize {
+		salt = salt[:md5CryptSaltSize]
+	}
+
+	a := md5.New()
+	a.Write(password)
+	a.Write([]byte(salt))
+	a.Write(password)
+	alternate := a.Sum(nil)
+
+	h := md5.New()
+	h.Write(password)
+	h.Write([]byte("$1$" + salt))
+	for i := len(password); i > 0; i -= md5.Size {
+		h.Write(alternate[:minInt(i, md5.Size)])
+	}
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			h.Write([]byte{0})
+		} else {
+			h.Write(password[:1])
+		}
+	}
+	digest := h.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+		h := md5.New()
+		if i&1 != 0 {
+			h.Write(password)
+		} else {
+			h.Write(digest)
+		}
+		if i%3 != 0 {
+			h.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			h.Write(password)
+		}
+		if i&1 != 0 {
+			h.Write(digest)
+		} else {
+			h.Write(password)
+		}
+		digest = h.Sum(nil)
+	}
+	return "$1$" + salt + "$" + cryptEncode(digest, md5CryptOrder)
+}
+
+// shaCrypt is the SHA based crypt(3) described by Ulrich Drepper in "Unix crypt using SHA-256
+// and SHA-512". The rounds are only written out if they were given.
+func shaCrypt(id string, newHash func() hash.Hash, order []int, password []byte, salt, rounds string) string {
+	if len(salt) > shaCryptSaltSize {
+		salt = salt[:shaCryptSaltSize]
+	}
+	n := shaCryptRounds(rounds)
+
+	sum := func(parts ...[]byte) []byte {
+		h := newHash()
+		for _, p := range parts {
+			h.Write(p)
+		}
+		return h.Sum(nil)
+	}
+	repeat := func(digest []byte, length int) []byte {
+		out := make([]byte, 0, length)
+		for len(out) < length {
+			out = append(out, digest[:minInt(length-len(out), len(digest))]...)
+		}
+		return out
+	}
+
+	alternate := sum(password, []byte(salt), password)
+	h := newHash()
+	h.Write(password)
+	h.Write([]byte(salt))
+	h.Write(repeat(alternate, len(password)))
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			h.Write(alternate)
+		} else {
+			h.Write(password)
+		}
+	}
+	digest := h.Sum(nil)
+
+	dp := newHash()
+	for range password {
+		dp.Write(password)
+	}
+	p := repeat(dp.Sum(nil), len(password))
+
+	ds := newHash()
+	for i := 0; i < 16+int(digest[0]); i++ {
+		ds.Write([]byte(salt))
+	}
+	s := repeat(ds.Sum(nil), len(salt))
+
+	for i := 0; i < n; i++ {
+		h := newHash()
+		if i&1 != 0 {
+			h.Write(p)
+		} else {
+			h.Write(digest)
+		}
+		if i%3 != 0 {
+			h.Write(s)
+		}
+		if i%7 != 0 {
+			h.Write(p)
+		}
+		if i&1 != 0 {
+			h.Write(digest)
+		} else {
+			h.Write(p)
+		}
+		digest = h.Sum(nil)
+	}
+
+	out := id
+	if rounds != "" {
+		out += shaCryptRoundsName + strconv.Itoa(n) + "$"
+	}
+	return out + salt + "$" + cryptEncode(digest, order)
+}
+
+// shaCryptRounds returns the number of rounds SHA-crypt takes for the rounds in a setting,
+// which are kept within the range it allows.
+func shaCryptRounds(rounds string) int {
+	if rounds == "" {
+		return shaCryptDefaultRounds
+	}
+	parsed, err := strconv.ParseUint(rounds, 10, 64)
+	switch {
+	case err != nil:
+		return shaCryptDefaultRounds
+	case parsed < shaCryptMinRounds:
+		return shaCryptMinRounds
+	case parsed > shaCryptMaxRounds:
+		return shaCryptMaxRounds
+	}
+	return int(parsed)
+}
+
+// minInt returns the smaller of two numbers.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
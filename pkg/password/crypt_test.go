@@ -0,0 +1,49 @@
+package password
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+// TestCrypt checks the crypt(3) algorithms against the outputs of glibc.
+func TestCrypt(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	tests := map[string]string{
+		"$1$saltstri":                            "$1$saltstri$YMyguxXMBpd2TEZ.vS/3q1",
+		"$5$saltstring":                          "$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5",
+		"$6$saltstring":                          "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1",
+		"$5$rounds=10000$saltstringsaltstring":   "$5$rounds=10000$saltstringsaltst$3xv.VbSHBb41AL9AvLeujZkZRBAwqFMz2.opqey6IcA",
+		"$6$rounds=1000$abc$Z1qGqKjJ955Q3hxAKRE": "$6$rounds=1000$abc$Z1qGqKjJ955Q3hxAKRENp11lM160CDktIAQXrnkWjszWG6/BYyr9DR5eFLvBn4Tv/XyP46lXwBA6X4flRX5/B0",
+	}
+	for setting, expected := range tests {
+		encoded, err := crypt([]byte("Hello world!"), setting)
+		Ω(err).ShouldNot(gomega.HaveOccurred(), setting)
+		Ω(encoded).Should(gomega.Equal(expected), setting)
+	}
+
+	_, err := crypt([]byte("Hello world!"), "$3$salt")
+	Ω(err).Should(gomega.MatchError("unsupported crypt algorithm '$3$'"))
+	_, err = crypt([]byte("Hello world!"), "abJnggxhB/yWI")
+	Ω(err).Should(gomega.HaveOccurred())
+}
+
+// TestCryptMatches checks {CRYPT} passwords migrated from OpenLDAP.
+func TestCryptMatches(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	encoded := []byte("{CRYPT}$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1")
+	Ω(Matches(encoded, []byte("Hello world!"))).Should(gomega.BeTrue())
+	Ω(Matches(encoded, []byte("Hello world"))).Should(gomega.BeFalse())
+
+	encoded = []byte("{crypt}$1$saltstri$YMyguxXMBpd2TEZ.vS/3q1")
+	Ω(Matches(encoded, []byte("Hello world!"))).Should(gomega.BeTrue())
+
+	// a setting on its own matches nothing.
+	Ω(Matches([]byte("{CRYPT}$6$saltstring"), []byte("Hello world!"))).Should(gomega.BeFalse())
+}
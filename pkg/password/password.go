@@ -0,0 +1,179 @@
+// Package password stores and checks passwords in the forms used by LDAP servers, where the
+// scheme is named in braces in front of the encoded password, e.g. {SSHA}.
+package password
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Encoder is a scheme for storing passwords.
+type Encoder interface {
+	// Encode hashes the password and returns it as it should be stored, including the scheme.
+	Encode(password []byte) ([]byte, error)
+	// Matches returns true if the password is the one which was encoded.
+	Matches(encoded, password []byte) bool
+	// Identify returns true if the encoded password was produced by this scheme.
+	Identify(encoded []byte) bool
+}
+
+// ErrTooCostly is returned for encoded passwords which would take more work to check than their
+// scheme allows. Such passwords never match, as checking them could stall the server.
+var ErrTooCostly = errors.New("the password would take too much work to check")
+
+// costChecker is implemented by schemes whose encoded passwords say how much work it takes to
+// check them, such as the rounds of SHA-crypt or the memory of Argon2.
+type costChecker interface {
+	// checkCost returns ErrTooCostly if the encoded password takes more work to check than allowed.
+	checkCost(encoded []byte) error
+}
+
+// registry holds the schemes which passwords can be stored with, keyed by their upper-cased
+// names. Encoded passwords are identified by asking each scheme in the order they were
+// registered.
+type registry struct {
+	lock     sync.RWMutex
+	encoders map[string]Encoder
+	order    []Encoder
+}
+
+// schemes is the registry used to encode and check passwords.
+var schemes = newRegistry()
+
+// newRegistry creates a registry holding the built-in schemes.
+func newRegistry() *registry {
+	r := &registry{encoders: make(map[string]Encoder)}
+	for _, s := range builtinSchemes {
+		if err := r.register(s.name, s.encoder); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// builtinSchemes are the schemes which are always available.
+var builtinSchemes = []struct {
+	name    string
+	encoder Encoder
+}{
+	{"SHA", shaSHA1},
+	{"SSHA", sshaSHA1},
+	{"SSHA256", sshaSHA256},
+	{"SSHA512", sshaSHA512},
+	{"CRYPT", cryptEncoder{}},
+	{"BCRYPT", bcryptEncoder{cost: bcryptDefaultCost}},
+	{"PBKDF2", pbkdf2SHA1Alias},
+	{"PBKDF2-SHA1", pbkdf2SHA1},
+	{"PBKDF2-SHA256", pbkdf2SHA256},
+	{"PBKDF2-SHA512", pbkdf2SHA512},
+	{"ARGON2", argon2Encoder{time: argon2DefaultTime, memory: argon2DefaultMemory, threads: argon2DefaultThreads}},
+}
+
+// register adds a scheme to the registry.
+func (r *registry) register(name string, encoder Encoder) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	key := strings.ToUpper(name)
+	if _, ok := r.encoders[key]; ok {
+		return fmt.Errorf("password scheme '%s' is already registered", name)
+	}
+	r.encoders[key] = encoder
+	r.order = append(r.order, encoder)
+	return nil
+}
+
+// Register makes a scheme available for encoding and checking passwords. Passwords encoded
+// with a registered scheme are recognised by its Identify method.
+func Register(name string, encoder Encoder) error {
+	return schemes.register(name, encoder)
+}
+
+// Lookup returns the scheme with the name, which is not case sensitive.
+func Lookup(name string) (Encoder, bool) {
+	schemes.lock.RLock()
+	defer schemes.lock.RUnlock()
+	encoder, ok := schemes.encoders[strings.ToUpper(name)]
+	return encoder, ok
+}
+
+// Schemes returns the names of the registered schemes in alphabetical order.
+func Schemes() []string {
+	schemes.lock.RLock()
+	defer schemes.lock.RUnlock()
+	names := make([]string, 0, len(schemes.encoders))
+	for name := range schemes.encoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Identify returns the scheme which produced the encoded password.
+func Identify(encoded []byte) (Encoder, bool) {
+	schemes.lock.RLock()
+	defer schemes.lock.RUnlock()
+	for _, encoder := range schemes.order {
+		if encoder.Identify(encoded) {
+			return encoder, true
+		}
+	}
+	return nil, false
+}
+
+// Matches returns true if the encoded password was produced by a registered scheme from the
+// password.
+func Matches(encoded, password []byte) bool {
+	encoder, ok := Identify(encoded)
+	return ok && encoder.Matches(encoded, password)
+}
+
+// CheckCost returns ErrTooCostly if the encoded password would take more work to check than its
+// scheme allows, so it would never match.
+func CheckCost(encoded []byte) error {
+	encoder, ok := Identify(encoded)
+	if !ok {
+		return nil
+	}
+	if c, ok := encoder.(costChecker); ok {
+		return c.checkCost(encoded)
+	}
+	return nil
+}
+
+// Scheme returns the name of the scheme in braces at the start of the encoded password, or
+// false if it doesn't start with one.
+func Scheme(encoded []byte) (string, bool) {
+	if len(encoded) == 0 || encoded[0] != '{' {
+		return "", false
+	}
+	end := bytes.IndexByte(encoded, '}')
+	if end < 2 {
+		return "", false
+	}
+	return string(encoded[1:end]), true
+}
+
+// hasScheme returns true if the encoded password starts with the scheme in braces, ignoring
+// case, and returns the rest of it.
+func hasScheme(encoded []byte, name string) ([]byte, bool) {
+	scheme, ok := Scheme(encoded)
+	if !ok || !strings.EqualFold(scheme, name) {
+		return nil, false
+	}
+	return encoded[len(scheme)+2:], true
+}
+
+// makeSalt returns size random bytes.
+func makeSalt(size int) ([]byte, error) {
+	salt := make([]byte, size)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
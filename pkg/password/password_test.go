@@ -0,0 +1,167 @@
+package password
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+// TestBuiltinSchemes encodes a password with each of the built-in schemes and checks that the
+// result is identified by the right scheme and matches only the same password.
+func TestBuiltinSchemes(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	for _, s := range builtinSchemes {
+		encoder := s.encoder
+		if s.name == "ARGON2" {
+			// keep the test quick, the defaults use 19 MiB of memory.
+			encoder = argon2Encoder{time: 1, memory: 64, threads: 2}
+		}
+		if s.name == "BCRYPT" {
+			encoder = bcryptEncoder{cost: bcryptMinCost}
+		}
+
+		encoded, err := encoder.Encode([]byte("secret"))
+		Ω(err).ShouldNot(gomega.HaveOccurred(), s.name)
+		scheme, ok := Scheme(encoded)
+		Ω(ok).Should(gomega.BeTrue(), s.name)
+		if s.name == "PBKDF2" {
+			Ω(scheme).Should(gomega.Equal("PBKDF2"))
+		}
+		Ω(encoder.Identify(encoded)).Should(gomega.BeTrue(), s.name)
+		Ω(encoder.Matches(encoded, []byte("secret"))).Should(gomega.BeTrue(), s.name)
+		Ω(encoder.Matches(encoded, []byte("wrong"))).Should(gomega.BeFalse(), s.name)
+		Ω(Matches(encoded, []byte("secret"))).Should(gomega.BeTrue(), s.name)
+		Ω(Matches(encoded, []byte("wrong"))).Should(gomega.BeFalse(), s.name)
+	}
+}
+
+// TestIdentify checks that encoded passwords are checked by the scheme named in their prefix.
+func TestIdentify(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	tests := map[string]string{
+		"{SSHA}I8wq1+4gyJVJUtQW96JGcmCL46ADyPnW":          "SSHA",
+		"{ssha512}aCu7JRc+kLsuEmFs1zTY":                   "SSHA512",
+		"{CRYPT}$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQ":   "CRYPT",
+		"{CRYPT}$2b$10$CCCCCCCCCCCCCCCCCCCCC.":            "BCRYPT",
+		"{PBKDF2-SHA256}10000$c2FsdA$a2V5":                "PBKDF2-SHA256",
+		"{ARGON2}$argon2id$v=19$m=64,t=1,p=1$c2FsdA$a2V5": "ARGON2",
+	}
+	for encoded, name := range tests {
+		encoder, ok := Identify([]byte(encoded))
+		Ω(ok).Should(gomega.BeTrue(), encoded)
+		Ω(schemeName(encoder)).Should(gomega.Equal(name), encoded)
+	}
+
+	for _, encoded := range []string{"secret", "{MD5}Xr4ilOzQ4PCOq3aQ0qbuaQ==", "{CRYPT}abJnggxhB/yWI", "{ARGON2}$argon2i$v=19$"} {
+		_, ok := Identify([]byte(encoded))
+		Ω(ok).Should(gomega.BeFalse(), encoded)
+		Ω(Matches([]byte(encoded), []byte("secret"))).Should(gomega.BeFalse(), encoded)
+	}
+}
+
+// schemeName returns the name the built-in encoder is registered with.
+func schemeName(encoder Encoder) string {
+	for _, s := range builtinSchemes {
+		if reflect.ValueOf(s.encoder).Type() == reflect.ValueOf(encoder).Type() && fmt.Sprint(s.encoder) == fmt.Sprint(encoder) {
+			return s.name
+		}
+	}
+	return ""
+}
+
+// testEncoder is a scheme which stores passwords as they are, to test registering schemes.
+type testEncoder struct{}
+
+func (testEncoder) Encode(password []byte) ([]byte, error) {
+	return append([]byte("{TEST}"), password...), nil
+}
+
+func (testEncoder) Matches(encoded, password []byte) bool {
+	return string(encoded) == "{TEST}"+string(password)
+}
+
+func (testEncoder) Identify(encoded []byte) bool {
+	_, ok := hasScheme(encoded, "TEST")
+	return ok
+}
+
+// TestRegister checks that schemes can be added and looked up, but not registered twice.
+func TestRegister(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	Ω(Register("ssha", testEncoder{})).Should(gomega.MatchError("password scheme 'ssha' is already registered"))
+
+	_, ok := Lookup("test")
+	Ω(ok).Should(gomega.BeFalse())
+	Ω(Register("Test", testEncoder{})).Should(gomega.Succeed())
+	encoder, ok := Lookup("test")
+	Ω(ok).Should(gomega.BeTrue())
+	Ω(encoder).Should(gomega.Equal(testEncoder{}))
+	Ω(Schemes()).Should(gomega.ContainElement("TEST"))
+	Ω(Matches([]byte("{TEST}secret"), []byte("secret"))).Should(gomega.BeTrue())
+}
+
+// TestScheme checks that the scheme is read from the braces at the start of an encoded password.
+func TestScheme(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	scheme, ok := Scheme([]byte("{SSHA512}abc"))
+	Ω(ok).Should(gomega.BeTrue())
+	Ω(scheme).Should(gomega.Equal("SSHA512"))
+
+	for _, encoded := range []string{"", "secret", "{}abc", "{SSHA"} {
+		_, ok := Scheme([]byte(encoded))
+		Ω(ok).Should(gomega.BeFalse(), encoded)
+	}
+}
+
+// TestCheckCost checks that passwords which would take too much work to check are refused
+// before any work is done.
+func TestCheckCost(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	bcrypt, err := bcryptEncoder{cost: bcryptMinCost}.Encode([]byte("secret"))
+	Ω(err).ShouldNot(gomega.HaveOccurred())
+	key := pbkdf2Encoding.EncodeToString(make([]byte, 32))
+
+	for _, encoded := range []string{
+		"{ARGON2}$argon2id$v=19$m=4294967295,t=1,p=1$c2FsdA$a2V5a2V5",
+		"{ARGON2}$argon2id$v=19$m=64,t=4294967295,p=1$c2FsdA$a2V5a2V5",
+		"{ARGON2}$argon2id$v=19$m=8192,t=1,p=255$c2FsdA$a2V5a2V5",
+		"{CRYPT}$6$rounds=999999999$saltstring$svn8UoSVapNtMuq1ukKS4tPQ",
+		"{CRYPT}$5$rounds=4294967296$saltstring$svn8UoSVapNtMuq1ukKS4tPQ",
+		"{PBKDF2-SHA256}999999999$c2FsdA$" + key,
+		"{PBKDF2-SHA256}10000$c2FsdA$" + pbkdf2Encoding.EncodeToString(make([]byte, 1<<20)),
+		strings.Replace(string(bcrypt), "$04$", "$31$", 1),
+	} {
+		Ω(CheckCost([]byte(encoded))).Should(gomega.MatchError(ErrTooCostly), encoded)
+		Ω(Matches([]byte(encoded), []byte("secret"))).Should(gomega.BeFalse(), encoded)
+	}
+
+	for _, encoded := range []string{
+		"{ARGON2}$argon2id$v=19$m=19456,t=2,p=1$c2FsdA$a2V5a2V5",
+		"{CRYPT}$6$rounds=10000$saltstring$svn8UoSVapNtMuq1ukKS4tPQ",
+		"{CRYPT}$1$saltstri$YMyguxXMBpd2TEZ.vS/3q1",
+		"{PBKDF2-SHA256}10000$c2FsdA$" + key,
+		"{SSHA}I8wq1+4gyJVJUtQW96JGcmCL46ADyPnW",
+		string(bcrypt),
+		"secret",
+	} {
+		Ω(CheckCost([]byte(encoded))).Should(gomega.Succeed(), encoded)
+	}
+}
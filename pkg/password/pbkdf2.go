@@ -0,0 +1,107 @@
+package password
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strconv"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	pbkdf2Iterations    = 10000   // The iterations new passwords are encoded with, as OpenLDAP does
+	pbkdf2MaxIterations = 1000000 // The most iterations a password may take to check
+	pbkdf2SaltSize      = 16      // The number of bytes of salt
+)
+
+// pbkdf2Encoding is the base64 encoding used by the OpenLDAP pbkdf2 module, which uses '.'
+// instead of '+' and leaves out the padding.
+var pbkdf2Encoding = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789./").WithPadding(base64.NoPadding)
+
+// pbkdf2Encoder stores passwords with PBKDF2 (RFC 8018) in the form used by the OpenLDAP
+// pbkdf2 module: {PBKDF2-SHA256}<iterations>$<salt>$<derived key>.
+type pbkdf2Encoder struct {
+	name       string           // The name of the scheme, e.g. PBKDF2-SHA256
+	hash       func() hash.Hash // The hash used by HMAC
+	iterations int              // The iterations new passwords are encoded with
+}
+
+var (
+	pbkdf2SHA1Alias = pbkdf2Encoder{name: "PBKDF2", hash: sha1.New, iterations: pbkdf2Iterations}
+	pbkdf2SHA1      = pbkdf2Encoder{name: "PBKDF2-SHA1", hash: sha1.New, iterations: pbkdf2Iterations}
+	pbkdf2SHA256    = pbkdf2Encoder{name: "PBKDF2-SHA256", hash: sha256.New, iterations: pbkdf2Iterations}
+	pbkdf2SHA512    = pbkdf2Encoder{name: "PBKDF2-SHA512", hash: sha512.New, iterations: pbkdf2Iterations}
+)
+
+// Encode derives a key from the password with a new salt.
+func (e pbkdf2Encoder) Encode(password []byte) ([]byte, error) {
+	salt, err := makeSalt(pbkdf2SaltSize)
+	if err != nil {
+		return nil, err
+	}
+	key := pbkdf2.Key(password, salt, e.iterations, e.hash().Size(), e.hash)
+	return []byte(fmt.Sprintf("{%s}%d$%s$%s", e.name, e.iterations, pbkdf2Encoding.EncodeToString(salt), pbkdf2Encoding.EncodeToString(key))), nil
+}
+
+// Matches derives a key from the password with the iterations and salt of the encoded password
+// and compares the keys.
+func (e pbkdf2Encoder) Matches(encoded, password []byte) bool {
+	iterations, salt, key, ok := e.parse(encoded)
+	if !ok || e.tooCostly(iterations, key) {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, pbkdf2.Key(password, salt, iterations, len(key), e.hash)) == 1
+}
+
+// checkCost returns ErrTooCostly if the encoded password has too many iterations or too long a key.
+func (e pbkdf2Encoder) checkCost(encoded []byte) error {
+	if iterations, _, key, ok := e.parse(encoded); ok && e.tooCostly(iterations, key) {
+		return ErrTooCostly
+	}
+	return nil
+}
+
+// parse takes an encoded password apart into its iterations, salt and derived key, returning
+// false if it is malformed.
+func (e pbkdf2Encoder) parse(encoded []byte) (int, []byte, []byte, bool) {
+	rest, ok := hasScheme(encoded, e.name)
+	if !ok {
+		return 0, nil, nil, false
+	}
+	parts := bytes.Split(rest, []byte("$"))
+	if len(parts) != 3 {
+		return 0, nil, nil, false
+	}
+	iterations, err := strconv.Atoi(string(parts[0]))
+	if err != nil || iterations < 1 {
+		return 0, nil, nil, false
+	}
+	salt, err := pbkdf2Encoding.DecodeString(string(parts[1]))
+	if err != nil {
+		return 0, nil, nil, false
+	}
+	key, err := pbkdf2Encoding.DecodeString(string(parts[2]))
+	if err != nil || len(key) == 0 {
+		return 0, nil, nil, false
+	}
+	return iterations, salt, key, true
+}
+
+// tooCostly returns true if deriving the key would take more iterations than allowed. Each
+// block of the key beyond the size of the hash takes the iterations again, so longer keys
+// than the hash are refused too.
+func (e pbkdf2Encoder) tooCostly(iterations int, key []byte) bool {
+	return iterations > pbkdf2MaxIterations || len(key) > e.hash().Size()
+}
+
+// Identify returns true if the encoded password starts with the name of the scheme.
+func (e pbkdf2Encoder) Identify(encoded []byte) bool {
+	_, ok := hasScheme(encoded, e.name)
+	return ok
+}
@@ -0,0 +1,21 @@
+package password
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+// TestPBKDF2Matches checks a password in the form written by the OpenLDAP pbkdf2 module.
+func TestPBKDF2Matches(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	encoded := []byte("{PBKDF2-SHA512}1000$MDEyMzQ1Njc4OWFiY2RlZg$vgFvU7zWIDgDAUi7d8ayt.cfRiPWVVWfv8iQRsGZaZviWzsSNgWYXEE5PmvI/VELMsOmEbqLz0PKuePNjOk41A")
+	Ω(Matches(encoded, []byte("secret"))).Should(gomega.BeTrue())
+	Ω(Matches(encoded, []byte("Secret"))).Should(gomega.BeFalse())
+
+	Ω(Matches([]byte("{PBKDF2-SHA512}0$MDEyMzQ1Njc4OWFiY2RlZg$vgFv"), []byte("secret"))).Should(gomega.BeFalse())
+	Ω(Matches([]byte("{PBKDF2-SHA512}1000$MDEyMzQ1Njc4OWFiY2RlZg"), []byte("secret"))).Should(gomega.BeFalse())
+}
@@ -0,0 +1,70 @@
+package password
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"hash"
+)
+
+// shaSaltSize is the number of random bytes added to salted SHA passwords.
+const shaSaltSize = 8
+
+// shaEncoder stores passwords as the base64 encoded digest of the password, followed by the
+// salt for salted schemes (RFC 2307 and the OpenLDAP {SSHA} family).
+type shaEncoder struct {
+	name   string           // The name of the scheme, e.g. SSHA256
+	hash   func() hash.Hash // The digest to use
+	salted bool             // Whether a salt is added to the password before it is hashed
+}
+
+var (
+	shaSHA1    = shaEncoder{name: "SHA", hash: sha1.New}
+	sshaSHA1   = shaEncoder{name: "SSHA", hash: sha1.New, salted: true}
+	sshaSHA256 = shaEncoder{name: "SSHA256", hash: sha256.New, salted: true}
+	sshaSHA512 = shaEncoder{name: "SSHA512", hash: sha512.New, salted: true}
+)
+
+// digest hashes the password followed by the salt.
+func (e shaEncoder) digest(password, salt []byte) []byte {
+	d := e.hash()
+	d.Write(password)
+	d.Write(salt)
+	return d.Sum(nil)
+}
+
+// Encode hashes the password with a new salt if the scheme is salted.
+func (e shaEncoder) Encode(password []byte) ([]byte, error) {
+	var salt []byte
+	if e.salted {
+		var err error
+		if salt, err = makeSalt(shaSaltSize); err != nil {
+			return nil, err
+		}
+	}
+	value := append(e.digest(password, salt), salt...)
+	return []byte("{" + e.name + "}" + base64.StdEncoding.EncodeToString(value)), nil
+}
+
+// Matches hashes the password with the salt stored after the digest and compares the digests.
+func (e shaEncoder) Matches(encoded, password []byte) bool {
+	rest, ok := hasScheme(encoded, e.name)
+	if !ok {
+		return false
+	}
+	value, err := base64.StdEncoding.DecodeString(string(rest))
+	size := e.hash().Size()
+	if err != nil || len(value) < size || (!e.salted && len(value) != size) {
+		return false
+	}
+	digest, salt := value[:size], value[size:]
+	return subtle.ConstantTimeCompare(digest, e.digest(password, salt)) == 1
+}
+
+// Identify returns true if the encoded password starts with the name of the scheme.
+func (e shaEncoder) Identify(encoded []byte) bool {
+	_, ok := hasScheme(encoded, e.name)
+	return ok
+}
@@ -0,0 +1,30 @@
+package password
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+// TestSHAMatches checks passwords encoded by OpenLDAP's slappasswd.
+func TestSHAMatches(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	tests := map[string]string{
+		"{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=":                                                                         "secret",
+		"{SSHA}I8wq1+4gyJVJUtQW96JGcmCL46ADyPnW":                                                                    "test",
+		"{SSHA256}oBmrdHcA6OZEkkCLeXh71YAerbvhXz1qqwjrPsXmEtNzYWx0c2FsdA==":                                         "secret",
+		"{SSHA512}aCu7JRc+kLsuEmFs1zTY+AiP7DSGnjjG+dH28Dp+E5usqoAixeTPihKqZmkWal4mUfp63tqvCAkFV1LKTDFH6XNhbHRzYWx0": "secret",
+	}
+	for encoded, password := range tests {
+		Ω(Matches([]byte(encoded), []byte(password))).Should(gomega.BeTrue(), encoded)
+		Ω(Matches([]byte(encoded), []byte(password+"x"))).Should(gomega.BeFalse(), encoded)
+	}
+
+	// the digest must be complete and, for unsalted passwords, not followed by anything.
+	Ω(shaSHA1.Matches([]byte("{SHA}5en6G6MezRroT3XKqkdPOmY/BfQAAAA="), []byte("secret"))).Should(gomega.BeFalse())
+	Ω(sshaSHA256.Matches([]byte("{SSHA256}c2FsdA=="), []byte("secret"))).Should(gomega.BeFalse())
+	Ω(sshaSHA1.Matches([]byte("{SSHA}not base64"), []byte("secret"))).Should(gomega.BeFalse())
+}
@@ -15,12 +15,22 @@ type And struct {
 
 // Evaluate evaluates the query against the specified ldap entry.
 func (a *And) Evaluate(entry *ldap.Entry) bool {
+	return a.Test(entry) == True
+}
+
+// Test evaluates the query against the entry. It is false if any condition is false, otherwise
+// it is undefined if any condition is undefined.
+func (a *And) Test(entry *ldap.Entry) Result {
+	result := True
 	for _, condition := range a.Conditions {
-		if !condition.Evaluate(entry) {
-			return false
+		switch condition.Test(entry) {
+		case False:
+			return False
+		case Undefined:
+			result = Undefined
 		}
 	}
-	return true
+	return result
 }
 
 // ToString produces a string version of this query condition
@@ -41,28 +51,13 @@ func ParseAnd(expression string, offset int) (*And, int, error) {
 	}
 
 	if !strings.HasPrefix(expression[offset:], "(&") {
-		return nil, -1, errors.InvalidExpression("and")
-	}
-
-	and := &And{Conditions: make([]Evaluator, 0)}
-	var cond Evaluator
-	var err error
-	offset = offset+2
-	for offset < len(expression) -1 {
- 		cond, offset, err = Parse(expression, offset)
-		if err != nil {
-			return nil, -1, err
-		}
-		and.Conditions = append(and.Conditions, cond)
-	}
-
-	if offset > len(expression)-1 || expression[offset] != ')' {
-		return nil, -1, errors.InvalidExpression("and")
+		return nil, -1, errors.InvalidExpressionAt("and", offset, "expected '(&'")
 	}
 
-	if len(and.Conditions) == 0 {
-		return nil, -1, errors.InvalidExpression("and")
+	conditions, offset, err := parseConditions(expression, offset+2, "and")
+	if err != nil {
+		return nil, -1, err
 	}
 
-	return and, offset+1, nil
+	return &And{Conditions: conditions}, offset+1, nil
 }
\ No newline at end of file
@@ -107,12 +107,12 @@ func TestAndParseMissingBracket(t *testing.T) {
 	expression := "(&(uid=person1)(objectClass=inetOrgPerson)"
 	_, _, err := ParseAnd(expression, 0)
 	Ω(err).ShouldNot(gomega.BeNil())
-	Ω(err).Should(gomega.Equal(errors.InvalidExpression("and")))
+	Ω(err).Should(gomega.MatchError(errors.InvalidExpression("and")))
 
 	expression = "&(uid=person1)(objectClass=inetOrgPerson))"
 	_, _, err = ParseAnd(expression, 0)
 	Ω(err).ShouldNot(gomega.BeNil())
-	Ω(err).Should(gomega.Equal(errors.InvalidExpression("and")))
+	Ω(err).Should(gomega.MatchError(errors.InvalidExpression("and")))
 }
 
 func TestAndParseSillyOffsets(t *testing.T) {
@@ -136,9 +136,17 @@ func TestAndParseNoEquals(t *testing.T) {
 	// workaround as our naming clashes with gomega.
 	Ω := gomega.Ω
 
+	// an empty and is the absolute true filter (RFC 4526).
 	expression := "(&)"
-	_, _, err := ParseAnd(expression, 0)
-	Ω(err).ShouldNot(gomega.BeNil())
-	Ω(err).Should(gomega.Equal(errors.InvalidExpression("and")))
+	cond, offset, err := ParseAnd(expression, 0)
+	Ω(err).Should(gomega.BeNil())
+	Ω(offset).Should(gomega.Equal(len(expression)))
+	Ω(cond.ToString()).Should(gomega.Equal(expression))
+	Ω(cond.Evaluate(&person1)).Should(gomega.Equal(true))
+	Ω(cond.Evaluate(&person2)).Should(gomega.Equal(true))
+
+	expression = "(&"
+	_, _, err = ParseAnd(expression, 0)
+	Ω(err).Should(gomega.MatchError(errors.InvalidExpression("and")))
 }
 
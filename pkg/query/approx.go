@@ -0,0 +1,43 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nmcclain/ldap"
+)
+
+// Approx conditions match attribute values which are approximately equal to the value, e.g.
+// (cn~=johnsmith). Values are considered approximately equal if they only differ by case
+// and whitespace.
+type Approx struct {
+	Attribute string // The attribute whose values are compared
+	Value     string // The value to compare against
+}
+
+// Evaluate evaluates the query against the specified ldap entry.
+func (a *Approx) Evaluate(entry *ldap.Entry) bool {
+	return a.Test(entry) == True
+}
+
+// Test evaluates the query against the entry. Any value can be compared approximately, so an
+// approximate condition is never undefined.
+func (a *Approx) Test(entry *ldap.Entry) Result {
+	value := approximate(a.Value)
+	for _, v := range attributeValues(entry, a.Attribute) {
+		if approximate(v) == value {
+			return True
+		}
+	}
+	return False
+}
+
+// ToString produces a string version of this query condition
+func (a *Approx) ToString() string {
+	return fmt.Sprintf("(%s~=%s)", a.Attribute, escapeValue(a.Value))
+}
+
+// approximate reduces a value to the form used for approximate matching.
+func approximate(value string) string {
+	return strings.ToLower(strings.Join(strings.Fields(value), ""))
+}
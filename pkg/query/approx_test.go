@@ -0,0 +1,25 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestApproxEvaluation(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	Ω((&Approx{Attribute: "cn", Value: "person(three)"}).Evaluate(&person3)).Should(gomega.Equal(true))
+	Ω((&Approx{Attribute: "cn", Value: "  PERSON  (THREE) "}).Evaluate(&person3)).Should(gomega.Equal(true))
+	Ω((&Approx{Attribute: "cn", Value: "person"}).Evaluate(&person3)).Should(gomega.Equal(false))
+}
+
+func TestApproxToString(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	Ω((&Approx{Attribute: "cn", Value: "person"}).ToString()).Should(gomega.Equal("(cn~=person)"))
+}
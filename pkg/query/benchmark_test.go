@@ -0,0 +1,146 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/nmcclain/ldap"
+)
+
+// benchmarkEntries creates a directory's worth of device entries.
+func benchmarkEntries(n int) []*ldap.Entry {
+	entries := make([]*ldap.Entry, n)
+	for i := range entries {
+		entries[i] = &ldap.Entry{DN: fmt.Sprintf("cn=device%d,ou=devices,dc=home,dc=lab", i),
+			Attributes: []*ldap.EntryAttribute{
+				{Name: "cn", Values: []string{fmt.Sprintf("device%d", i)}},
+				{Name: "objectClass", Values: []string{"device", "ieee802Device"}},
+				{Name: "macAddress", Values: []string{fmt.Sprintf("00:1a:2b:%02x:%02x:%02x", i>>16&0xff, i>>8&0xff, i&0xff)}},
+				{Name: "description", Values: []string{fmt.Sprintf("IoT sensor in room %d", i%50)}},
+			},
+		}
+	}
+	return entries
+}
+
+// regexpEquals is how Equals used to evaluate wildcards, compiling a regular expression for
+// every attribute of every entry. It is kept here to measure the compiled matcher against.
+func regexpEquals(entry *ldap.Entry, attribute, value string) bool {
+	for _, a := range entry.Attributes {
+		if strings.EqualFold(a.Name, attribute) {
+			q := strings.ReplaceAll(strings.ToLower(value), ".", "\\.")
+			q = strings.ReplaceAll(q, "*", ".*")
+
+			r, err := regexp.CompilePOSIX(q)
+			if err != nil {
+				return false
+			}
+
+			for _, v := range a.Values {
+				if r.MatchString(strings.ToLower(v)) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func BenchmarkSubstringsRegexp(b *testing.B) {
+	entries := benchmarkEntries(5000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, entry := range entries {
+			regexpEquals(entry, "description", "*room 4*")
+		}
+	}
+}
+
+func BenchmarkSubstringsCompiled(b *testing.B) {
+	entries := benchmarkEntries(5000)
+	q, _, err := Parse("(description=*room 4*)", 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, entry := range entries {
+			q.Evaluate(entry)
+		}
+	}
+}
+
+func BenchmarkEqualsRegexp(b *testing.B) {
+	entries := benchmarkEntries(5000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, entry := range entries {
+			regexpEquals(entry, "cn", "device4999")
+		}
+	}
+}
+
+func BenchmarkEqualsCompiled(b *testing.B) {
+	entries := benchmarkEntries(5000)
+	q, _, err := Parse("(cn=device4999)", 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, entry := range entries {
+			q.Evaluate(entry)
+		}
+	}
+}
+
+func BenchmarkComplexFilter(b *testing.B) {
+	entries := benchmarkEntries(5000)
+	q, _, err := Parse("(&(objectClass=device)(|(cn=device1*)(macAddress=00:1a:2b:*:ff))(!(description=*room 0)))", 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, entry := range entries {
+			q.Evaluate(entry)
+		}
+	}
+}
+
+func BenchmarkComplexFilterIndexed(b *testing.B) {
+	entries := benchmarkEntries(5000)
+	index := testIndex(entries)
+	q, _, err := Parse("(&(objectClass=device)(|(cn=device1*)(macAddress=00:1a:2b:00:00:ff))(!(description=*room 0)))", 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ids, ok := index.Plan(q)
+		if !ok {
+			b.Fatal("the filter could not be planned")
+		}
+		for _, id := range ids {
+			q.Evaluate(entries[id])
+		}
+	}
+}
+
+func BenchmarkEqualsIndexed(b *testing.B) {
+	entries := benchmarkEntries(5000)
+	index := testIndex(entries)
+	q, _, err := Parse("(cn=device4999)", 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ids, _ := index.Plan(q)
+		for _, id := range ids {
+			q.Evaluate(entries[id])
+		}
+	}
+}
@@ -8,13 +8,29 @@ import (
 
 type Evaluator interface {
 	Evaluate(entry *ldap.Entry) bool // Evaluate evaluates the query against the specified ldap entry.
+	Test(entry *ldap.Entry) Result   // Test evaluates the query against the entry, which may leave it undefined.
 	ToString() string                // ToString produces a string version of this query condition
 }
 
+// Result is the value of a filter for an entry. Besides true and false a filter can be
+// undefined, for example when the assertion value isn't valid for the attribute's matching
+// rule (RFC 4511 section 4.5.1.7). An undefined filter doesn't match, and nor does its negation.
+type Result int
+
+const (
+	False     Result = iota // The filter doesn't match the entry
+	True                    // The filter matches the entry
+	Undefined               // The server can't tell whether the filter matches the entry
+)
+
 // Parse takes an expression and an offset within that expression and turns it into
 // an Evaluator chain. This can then be used to evaluate a query against a set of
-// ldap entries.
+// ldap entries. The syntax of the expression is described in RFC 4515.
 func Parse(expression string, offset int) (Evaluator, int, error) {
+	if offset > len(expression) || offset < 0 {
+		return nil, -1, errors.InvalidOffset(offset, expression)
+	}
+
 	if strings.HasPrefix(expression[offset:], "(&") {
 		return ParseAnd(expression, offset)
 	} else if strings.HasPrefix(expression[offset:], "(|") {
@@ -22,7 +38,121 @@ func Parse(expression string, offset int) (Evaluator, int, error) {
 	} else if strings.HasPrefix(expression[offset:], "(!") {
 		return ParseNot(expression, offset)
 	} else if strings.HasPrefix(expression[offset:], "(") {
-		return ParseEquals(expression, offset)
+		return ParseItem(expression, offset)
+	}
+	return nil, -1, errors.InvalidExpressionAt("", offset, "expected '('")
+}
+
+// parseConditions parses the list of conditions inside an And or Or condition, starting at
+// the offset of the first condition and ending at the offset of the closing bracket. The list
+// may be empty, making (&) and (|) the absolute true and false filters of RFC 4526.
+func parseConditions(expression string, offset int, conditionType string) ([]Evaluator, int, error) {
+	conditions := make([]Evaluator, 0)
+	for offset < len(expression) && expression[offset] == '(' {
+		cond, next, err := Parse(expression, offset)
+		if err != nil {
+			return nil, -1, err
+		}
+		conditions = append(conditions, cond)
+		offset = next
+	}
+
+	if offset > len(expression)-1 || expression[offset] != ')' {
+		return nil, -1, errors.InvalidExpressionAt(conditionType, offset, "missing ')'")
+	}
+	return conditions, offset, nil
+}
+
+// ParseItem takes an expression and attempts to parse it into a single attribute condition:
+// equality, presence, substrings, greater or equal, less or equal, approximate or extensible match.
+func ParseItem(expression string, offset int) (Evaluator, int, error) {
+	if offset > len(expression) || offset < 0 {
+		return nil, -1, errors.InvalidOffset(offset, expression)
+	}
+
+	if !strings.HasPrefix(expression[offset:], "(") {
+		return nil, -1, errors.InvalidExpressionAt("equals", offset, "expected '('")
+	}
+
+	start := offset + 1
+	pos := start
+	for pos < len(expression) && isAttributeChar(expression[pos]) {
+		pos++
+	}
+	attribute := expression[start:pos]
+
+	if pos >= len(expression) {
+		return nil, -1, errors.InvalidExpressionAt("equals", pos, "missing ')'")
+	}
+
+	// extensible matches have a colon after the (optional) attribute.
+	if expression[pos] == ':' {
+		return parseExtensible(expression, attribute, pos)
+	}
+
+	if attribute == "" {
+		return nil, -1, errors.InvalidExpressionAt("equals", pos, "missing attribute description")
+	}
+
+	var conditionType string
+	switch {
+	case strings.HasPrefix(expression[pos:], "~="):
+		conditionType = "approx"
+		pos += 2
+	case strings.HasPrefix(expression[pos:], ">="):
+		conditionType = "greaterOrEqual"
+		pos += 2
+	case strings.HasPrefix(expression[pos:], "<="):
+		conditionType = "lessOrEqual"
+		pos += 2
+	case expression[pos] == '=':
+		conditionType = "equals"
+		pos++
+	default:
+		return nil, -1, errors.InvalidExpressionAt("equals", pos, "expected '=', '~=', '>=' or '<='")
+	}
+
+	parts, end, err := parseValue(expression, pos, conditionType)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	// only equality assertions may contain wildcards.
+	if conditionType != "equals" && len(parts) > 1 {
+		return nil, -1, errors.InvalidExpressionAt(conditionType, pos+strings.IndexByte(expression[pos:end], '*'), "'*' must be escaped as \\2a")
+	}
+
+	switch conditionType {
+	case "approx":
+		return &Approx{Attribute: attribute, Value: parts[0]}, end + 1, nil
+	case "greaterOrEqual":
+		return &GreaterOrEqual{Attribute: attribute, Value: parts[0]}, end + 1, nil
+	case "lessOrEqual":
+		return &LessOrEqual{Attribute: attribute, Value: parts[0]}, end + 1, nil
+	}
+
+	switch {
+	case len(parts) == 1:
+		return NewEquals(attribute, escapeValue(parts[0])), end + 1, nil
+	case len(parts) == 2 && parts[0] == "" && parts[1] == "":
+		return &Present{Attribute: attribute}, end + 1, nil
+	}
+
+	for _, part := range parts[1 : len(parts)-1] {
+		if part == "" {
+			return nil, -1, errors.InvalidExpressionAt("substrings", pos+strings.Index(expression[pos:end], "**"), "empty substring")
+		}
+	}
+	return NewSubstrings(attribute, parts[0], parts[1:len(parts)-1], parts[len(parts)-1]), end + 1, nil
+}
+
+// attributeValues returns the values of every attribute in the entry with the given name.
+func attributeValues(entry *ldap.Entry, attribute string) []string {
+	values := make([]string, 0)
+	for _, a := range entry.Attributes {
+		if strings.EqualFold(a.Name, attribute) {
+			values = append(values, a.Values...)
+		}
 	}
-	return nil, -1, errors.InvalidExpression("")
+	return values
 }
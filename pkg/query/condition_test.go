@@ -0,0 +1,150 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+	"github.com/shauncampbell/dapper/pkg/query/errors"
+)
+
+var person3 = ldap.Entry{DN: "cn=Person (Three),ou=people,dc=test,dc=lab",
+	Attributes: []*ldap.EntryAttribute{
+		{Name: "cn", Values: []string{"Person (Three)"}},
+		{Name: "uid", Values: []string{"person3"}},
+		{Name: "uidNumber", Values: []string{"1003"}},
+		{Name: "description", Values: []string{"likes *stars* and back\\slashes"}},
+	},
+}
+
+func TestParseNested(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	expression := "(|(&(uid=person1)(objectClass=jellyfinUser))(!(|(uid=person1)(uid=person3))))"
+	cond, offset, err := Parse(expression, 0)
+	Ω(err).Should(gomega.BeNil())
+	Ω(offset).Should(gomega.Equal(len(expression)))
+	Ω(cond.ToString()).Should(gomega.Equal(expression))
+
+	Ω(cond.Evaluate(&person1)).Should(gomega.Equal(true))
+	Ω(cond.Evaluate(&person2)).Should(gomega.Equal(true))
+	Ω(cond.Evaluate(&person3)).Should(gomega.Equal(false))
+}
+
+func TestParseUndefined(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	for _, test := range []struct {
+		expression string
+		result     Result
+	}{
+		// a false condition decides an and, a true one decides an or.
+		{"(&(uidNumber=abc)(uid=person3))", Undefined},
+		{"(&(uidNumber=abc)(uid=person1))", False},
+		{"(|(uidNumber=abc)(uid=person1))", Undefined},
+		{"(|(uidNumber=abc)(uid=person3))", True},
+		{"(!(&(uidNumber=abc)(uid=person1)))", True},
+	} {
+		cond, _, err := Parse(test.expression, 0)
+		Ω(err).Should(gomega.BeNil())
+		Ω(cond.Test(&person3)).Should(gomega.Equal(test.result), test.expression)
+	}
+}
+
+func TestParseItemTypes(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	tests := map[string]Evaluator{
+		"(uid=person1)":        NewEquals("uid", "person1"),
+		"(uid=*)":              &Present{Attribute: "uid"},
+		"(uid=per*)":           NewSubstrings("uid", "per", []string{}, ""),
+		"(uid=*son1)":          NewSubstrings("uid", "", []string{}, "son1"),
+		"(uid=p*r*o*1)":        NewSubstrings("uid", "p", []string{"r", "o"}, "1"),
+		"(uidNumber>=1000)":    &GreaterOrEqual{Attribute: "uidNumber", Value: "1000"},
+		"(uidNumber<=1000)":    &LessOrEqual{Attribute: "uidNumber", Value: "1000"},
+		"(cn~=person three)":   &Approx{Attribute: "cn", Value: "person three"},
+		"(cn:=person1)":        &Extensible{Attribute: "cn", Value: "person1"},
+		"(cn:dn:=person1)":     &Extensible{Attribute: "cn", DNAttributes: true, Value: "person1"},
+		"(:caseExactMatch:=x)": &Extensible{MatchingRule: "caseExactMatch", Value: "x"},
+		"(:dn:2.5.13.5:=x)":    &Extensible{MatchingRule: "2.5.13.5", DNAttributes: true, Value: "x"},
+		"(cn;lang-en=x)":       NewEquals("cn;lang-en", "x"),
+		"(2.5.4.3=x)":          NewEquals("2.5.4.3", "x"),
+		"(cn=)":                NewEquals("cn", ""),
+	}
+
+	for expression, expected := range tests {
+		cond, offset, err := Parse(expression, 0)
+		Ω(err).Should(gomega.BeNil(), expression)
+		Ω(offset).Should(gomega.Equal(len(expression)), expression)
+		Ω(cond).Should(gomega.Equal(expected), expression)
+		Ω(cond.ToString()).Should(gomega.Equal(expression), expression)
+	}
+}
+
+func TestParseEscapes(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	cond, _, err := Parse("(cn=Person \\28Three\\29)", 0)
+	Ω(err).Should(gomega.BeNil())
+	Ω(cond.Evaluate(&person3)).Should(gomega.Equal(true))
+	Ω(cond.ToString()).Should(gomega.Equal("(cn=Person \\28Three\\29)"))
+
+	// escaped stars are literal rather than wildcards.
+	cond, _, err = Parse("(description=*\\2astars\\2a*)", 0)
+	Ω(err).Should(gomega.BeNil())
+	Ω(cond).Should(gomega.Equal(NewSubstrings("description", "", []string{"*stars*"}, "")))
+	Ω(cond.Evaluate(&person3)).Should(gomega.Equal(true))
+
+	cond, _, _ = Parse("(uid=person\\2a)", 0)
+	Ω(cond.Evaluate(&person1)).Should(gomega.Equal(false))
+
+	cond, _, _ = Parse("(description=*back\\5cslashes)", 0)
+	Ω(cond.Evaluate(&person3)).Should(gomega.Equal(true))
+
+	// hex digits may be upper or lower case.
+	cond, _, _ = Parse("(uid=PERSON\\31)", 0)
+	Ω(cond.Evaluate(&person1)).Should(gomega.Equal(true))
+}
+
+func TestParseErrors(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	tests := map[string]error{
+		"":                         errors.InvalidExpressionAt("", 0, "expected '('"),
+		"uid=x":                    errors.InvalidExpressionAt("", 0, "expected '('"),
+		"(uid=a(b)":                errors.InvalidExpressionAt("equals", 6, "'(' must be escaped as \\28"),
+		"(uid=a\\2)":               errors.InvalidExpressionAt("equals", 6, "invalid escape sequence"),
+		"(uid=a\\zz)":              errors.InvalidExpressionAt("equals", 6, "invalid escape sequence"),
+		"(uid=a**b)":               errors.InvalidExpressionAt("substrings", 6, "empty substring"),
+		"(uid>=a*)":                errors.InvalidExpressionAt("greaterOrEqual", 7, "'*' must be escaped as \\2a"),
+		"(uid<a)":                  errors.InvalidExpressionAt("equals", 4, "expected '=', '~=', '>=' or '<='"),
+		"(=a)":                     errors.InvalidExpressionAt("equals", 1, "missing attribute description"),
+		"(u id=a)":                 errors.InvalidExpressionAt("equals", 2, "expected '=', '~=', '>=' or '<='"),
+		"(:=a)":                    errors.InvalidExpressionAt("extensible", 3, "a matching rule is required when there is no attribute"),
+		"(cn::=a)":                 errors.InvalidExpressionAt("extensible", 4, "missing matching rule"),
+		"(:dn:=a)":                 errors.InvalidExpressionAt("extensible", 6, "a matching rule is required when there is no attribute"),
+		"(cn:rule:other:=a)":       errors.InvalidExpressionAt("extensible", 9, "unexpected 'other'"),
+		"(cn:rule=a)":              errors.InvalidExpressionAt("extensible", 8, "expected ':='"),
+		"(&(uid=a)(uid=b)":         errors.InvalidExpressionAt("and", 16, "missing ')'"),
+		"(|(uid=a)uid=b))":         errors.InvalidExpressionAt("or", 9, "missing ')'"),
+		"(&(uid=a)(!(uid=b)(c=d))": errors.InvalidExpressionAt("not", 18, "missing ')'"),
+	}
+
+	for expression, expected := range tests {
+		_, _, err := Parse(expression, 0)
+		Ω(err).Should(gomega.Equal(expected), expression)
+
+		// the position is reported in the message.
+		Ω(err.Error()).Should(gomega.ContainSubstring("at offset"), expression)
+	}
+}
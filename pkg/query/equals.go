@@ -4,37 +4,44 @@ import (
 	"fmt"
 	"github.com/nmcclain/ldap"
 	"github.com/shauncampbell/dapper/pkg/query/errors"
-	regexp "regexp"
-	"strings"
 )
 
+// Equals conditions match attribute values which are equal to the value using the attribute's
+// equality matching rule, e.g. (uid=person1). The value is held in its escaped filter form, so for compatibility an
+// unescaped '*' acts as a wildcard.
 type Equals struct {
 	Attribute string
 	Value     string
 	Evaluator
+	matcher *substringMatcher // The compiled value, nil if the condition wasn't created by NewEquals
 }
 
-func (e *Equals) Evaluate(entry *ldap.Entry) bool {
-	for _, a := range entry.Attributes {
-		if strings.ToLower(a.Name) == e.Attribute {
-
-			q := strings.ReplaceAll(e.Value, ".", "\\.")
-			q = strings.ReplaceAll(q, "*", ".*")
+// NewEquals creates an Equals condition with its value compiled ready for evaluation.
+func NewEquals(attribute, value string) *Equals {
+	return &Equals{Attribute: attribute, Value: value, matcher: compileEquals(attribute, value)}
+}
 
-			r, err := regexp.CompilePOSIX(q)
-			if err != nil {
-				return false
-			}
+// NewAssertion creates an Equals condition from an attribute value assertion, such as the one
+// in a compare request. Unlike the value given to NewEquals the value is exactly the value being
+// asserted, so it has no escapes and a '*' is not a wildcard.
+func NewAssertion(attribute, value string) *Equals {
+	return NewEquals(attribute, escapeValue(value))
+}
 
-			for _, v := range a.Values {
+func (e *Equals) Evaluate(entry *ldap.Entry) bool {
+	return e.Test(entry) == True
+}
 
-				if r.MatchString(strings.ToLower(v)) {
-					return true
-				}
-			}
+// Test evaluates the query against the entry. It is undefined if the value isn't valid for the
+// attribute's matching rule, e.g. (uidNumber=abc).
+func (e *Equals) Test(entry *ldap.Entry) Result {
+	m := e.matcher
+	if m == nil {
+		if m = compileEquals(e.Attribute, e.Value); m == nil {
+			return Undefined
 		}
 	}
-	return false
+	return m.test(entry, e.Attribute)
 }
 
 // ToString produces a string version of this query condition
@@ -42,27 +49,16 @@ func (e *Equals) ToString() string {
 	return fmt.Sprintf("(%s=%s)", e.Attribute, e.Value)
 }
 
+// ParseEquals takes an expression and attempts to parse it into an Equals condition.
 func ParseEquals(expression string, offset int) (*Equals, int, error) {
-	if offset > len(expression) || offset < 0 {
-		return nil, -1, errors.InvalidOffset(offset, expression)
+	cond, pos, err := ParseItem(expression, offset)
+	if err != nil {
+		return nil, -1, err
 	}
 
-	if !strings.HasPrefix(expression[offset:], "(") {
-		return nil, -1, errors.InvalidExpression("equals")
+	equals, ok := cond.(*Equals)
+	if !ok {
+		return nil, -1, errors.InvalidExpressionAt("equals", offset, "not an equality condition")
 	}
-
-	firstBracket := strings.Index(expression[offset:], ")")+offset
-	if firstBracket < offset+2 {
-		return nil, -1, errors.InvalidExpression("equals")
-	}
-
-	equalsExpression := expression[offset+1:firstBracket]
-
-	if eqSymbolIndex := strings.Index(equalsExpression, "="); eqSymbolIndex != -1 {
-		attribute := equalsExpression[:eqSymbolIndex]
-		expression := equalsExpression[eqSymbolIndex+1 : ]
-		return &Equals{Attribute: strings.ToLower(attribute), Value: strings.ToLower(expression)}, firstBracket+1, nil
-	}
-
-	return nil, -1, errors.InvalidExpression("equals")
+	return equals, pos, nil
 }
@@ -101,12 +101,12 @@ func TestEqualsParseMissingBracket(t *testing.T) {
 	expression := "uid=person1)"
 	_, _, err := ParseEquals(expression, 0)
 	Ω(err).ShouldNot(gomega.BeNil())
-	Ω(err).Should(gomega.Equal(errors.InvalidExpression("equals")))
+	Ω(err).Should(gomega.MatchError(errors.InvalidExpression("equals")))
 
 	expression = "(uid=person1"
 	_, _, err = ParseEquals(expression, 0)
 	Ω(err).ShouldNot(gomega.BeNil())
-	Ω(err).Should(gomega.Equal(errors.InvalidExpression("equals")))
+	Ω(err).Should(gomega.MatchError(errors.InvalidExpression("equals")))
 }
 
 func TestEqualsParseSillyOffsets(t *testing.T) {
@@ -133,5 +133,24 @@ func TestEqualsParseNoEquals(t *testing.T) {
 	expression := "(uid)"
 	_, _, err := ParseEquals(expression, 0)
 	Ω(err).ShouldNot(gomega.BeNil())
-	Ω(err).Should(gomega.Equal(errors.InvalidExpression("equals")))
+	Ω(err).Should(gomega.MatchError(errors.InvalidExpression("equals")))
+}
+
+func TestNewAssertion(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	entry := &ldap.Entry{DN: "cn=person,dc=test,dc=lab", Attributes: []*ldap.EntryAttribute{
+		{Name: "description", Values: []string{"a*b", `back\slash`}},
+		{Name: "uidNumber", Values: []string{"1000"}},
+	}}
+
+	// the value is asserted as it is, without escapes or wildcards.
+	Ω(NewAssertion("description", "a*b").Evaluate(entry)).Should(gomega.Equal(true))
+	Ω(NewAssertion("description", "a*").Evaluate(entry)).Should(gomega.Equal(false))
+	Ω(NewAssertion("description", `BACK\SLASH`).Evaluate(entry)).Should(gomega.Equal(true))
+
+	// the matching rule of the attribute is used.
+	Ω(NewAssertion("uidNumber", "01000").Evaluate(entry)).Should(gomega.Equal(true))
 }
@@ -2,15 +2,55 @@ package errors
 
 import "fmt"
 
+// SyntaxError is the error returned when an expression can't be parsed. It records the type
+// of condition being parsed and, when known, the offset within the expression of the problem.
+type SyntaxError struct {
+	Condition string // The type of condition being parsed, empty if it isn't known
+	Offset    int    // The offset within the expression where the problem was found, -1 if it isn't known
+	Reason    string // A description of the problem, empty if there isn't one
+}
+
+// Error returns the error message.
+func (e *SyntaxError) Error() string {
+	msg := "the provided expression is not a valid condition"
+	if e.Condition != "" {
+		msg = fmt.Sprintf("the provided expression is not a valid '%s' condition", e.Condition)
+	}
+	if e.Reason != "" {
+		msg = msg + ": " + e.Reason
+	}
+	if e.Offset >= 0 {
+		msg = fmt.Sprintf("%s at offset %d", msg, e.Offset)
+	}
+	return msg
+}
+
+// Is returns true if the target is a SyntaxError for the same type of condition. An offset
+// of -1 or an empty reason in the target match any offset or reason.
+func (e *SyntaxError) Is(target error) bool {
+	t, ok := target.(*SyntaxError)
+	if !ok {
+		return false
+	}
+	return t.Condition == e.Condition && (t.Offset < 0 || t.Offset == e.Offset) && (t.Reason == "" || t.Reason == e.Reason)
+}
+
 // InvalidExpression is an error message when the syntax of an expression is not valid.
 func InvalidExpression(conditionType string) error {
-	if conditionType == "" {
-		return fmt.Errorf("the provided expression is not a valid condition")
-	}
-	return fmt.Errorf("the provided expression is not a valid '%s' condition", conditionType)
+	return &SyntaxError{Condition: conditionType, Offset: -1}
+}
+
+// InvalidExpressionAt is an error message when the syntax of an expression is not valid at a known offset.
+func InvalidExpressionAt(conditionType string, offset int, reason string) error {
+	return &SyntaxError{Condition: conditionType, Offset: offset, Reason: reason}
+}
+
+// InvalidPacket is an error message when a BER encoded filter is not valid.
+func InvalidPacket(conditionType string, reason string) error {
+	return &SyntaxError{Condition: conditionType, Offset: -1, Reason: reason}
 }
 
 // InvalidOffset is an error message when the offset lies outside of the constraints of the expression.
 func InvalidOffset(offset int, expression string) error {
 	return fmt.Errorf("the provided offset (%d) is not valid for the length of this expression (%d)", offset, len(expression))
-}
\ No newline at end of file
+}
@@ -0,0 +1,134 @@
+package query
+
+import (
+	"strings"
+
+	"github.com/nmcclain/ldap"
+	"github.com/shauncampbell/dapper/pkg/dn"
+	"github.com/shauncampbell/dapper/pkg/query/errors"
+)
+
+// Extensible conditions match attribute values using a named matching rule, e.g.
+// (cn:caseExactMatch:=Fred) or (:dn:2.5.13.5:=users). If no attribute is given every attribute
+// is checked, and if dnAttributes is set the attributes in the entry's DN are checked too.
+type Extensible struct {
+	Attribute    string // The attribute whose values are matched, empty for every attribute
+	MatchingRule string // The matching rule to use, empty for the attribute's equality rule
+	DNAttributes bool   // Also match against the attributes in the entry's DN
+	Value        string // The value to compare against
+}
+
+// Evaluate evaluates the query against the specified ldap entry. Unsupported matching rules never match.
+func (e *Extensible) Evaluate(entry *ldap.Entry) bool {
+	return e.Test(entry) == True
+}
+
+// Test evaluates the query against the entry. It is undefined if the matching rule isn't
+// supported or the value isn't valid for it.
+func (e *Extensible) Test(entry *ldap.Entry) Result {
+	var rule *MatchingRule
+	if e.MatchingRule != "" {
+		if rule = LookupMatchingRule(e.MatchingRule); rule == nil {
+			return Undefined
+		}
+		if _, ok := rule.Normalize(e.Value); !ok {
+			return Undefined
+		}
+	}
+
+	for _, a := range entry.Attributes {
+		if e.Attribute != "" && !strings.EqualFold(a.Name, e.Attribute) {
+			continue
+		}
+		r := e.rule(rule, a.Name)
+		for _, v := range a.Values {
+			if r.Equal(v, e.Value) {
+				return True
+			}
+		}
+	}
+
+	if e.DNAttributes {
+		name, err := dn.Parse(entry.DN)
+		if err != nil {
+			return False
+		}
+		for _, rdn := range name {
+			for _, ava := range rdn {
+				if (e.Attribute == "" || strings.EqualFold(ava.Type, e.Attribute)) && e.rule(rule, ava.Type).Equal(ava.Value, e.Value) {
+					return True
+				}
+			}
+		}
+	}
+	return False
+}
+
+// rule returns the matching rule used for an attribute: the rule named in the condition if
+// there is one, otherwise the attribute's equality rule.
+func (e *Extensible) rule(named *MatchingRule, attribute string) *MatchingRule {
+	if named != nil {
+		return named
+	}
+	return EqualityMatch(attribute)
+}
+
+// ToString produces a string version of this query condition
+func (e *Extensible) ToString() string {
+	out := "(" + e.Attribute
+	if e.DNAttributes {
+		out = out + ":dn"
+	}
+	if e.MatchingRule != "" {
+		out = out + ":" + e.MatchingRule
+	}
+	return out + ":=" + escapeValue(e.Value) + ")"
+}
+
+// parseExtensible parses the rest of an extensible match once the attribute has been read.
+// The offset is the position of the first colon after the attribute.
+func parseExtensible(expression string, attribute string, offset int) (*Extensible, int, error) {
+	ext := &Extensible{Attribute: attribute}
+	for {
+		if offset >= len(expression) || expression[offset] != ':' {
+			return nil, -1, errors.InvalidExpressionAt("extensible", offset, "expected ':='")
+		}
+		offset++
+
+		if offset < len(expression) && expression[offset] == '=' {
+			offset++
+			break
+		}
+
+		start := offset
+		for offset < len(expression) && isRuleChar(expression[offset]) {
+			offset++
+		}
+		token := expression[start:offset]
+
+		switch {
+		case token == "":
+			return nil, -1, errors.InvalidExpressionAt("extensible", start, "missing matching rule")
+		case strings.EqualFold(token, "dn") && !ext.DNAttributes && ext.MatchingRule == "":
+			ext.DNAttributes = true
+		case ext.MatchingRule == "":
+			ext.MatchingRule = token
+		default:
+			return nil, -1, errors.InvalidExpressionAt("extensible", start, "unexpected '"+token+"'")
+		}
+	}
+
+	if ext.Attribute == "" && ext.MatchingRule == "" {
+		return nil, -1, errors.InvalidExpressionAt("extensible", offset, "a matching rule is required when there is no attribute")
+	}
+
+	parts, end, err := parseValue(expression, offset, "extensible")
+	if err != nil {
+		return nil, -1, err
+	}
+	if len(parts) > 1 {
+		return nil, -1, errors.InvalidExpressionAt("extensible", offset+strings.IndexByte(expression[offset:end], '*'), "'*' must be escaped as \\2a")
+	}
+	ext.Value = parts[0]
+	return ext, end + 1, nil
+}
@@ -0,0 +1,44 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestExtensibleEvaluation(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	tests := []struct {
+		extensible Extensible
+		matches    bool
+	}{
+		{Extensible{Attribute: "uid", Value: "PERSON3"}, true},
+		{Extensible{Attribute: "uid", MatchingRule: "caseExactMatch", Value: "PERSON3"}, false},
+		{Extensible{Attribute: "uid", MatchingRule: "2.5.13.5", Value: "person3"}, true},
+		{Extensible{Attribute: "uidNumber", MatchingRule: "integerMatch", Value: "01003"}, true},
+		{Extensible{Attribute: "uidNumber", MatchingRule: "1.2.840.113556.1.4.803", Value: "3"}, true},
+		{Extensible{Attribute: "uidNumber", MatchingRule: "1.2.840.113556.1.4.803", Value: "7"}, false},
+		{Extensible{Attribute: "uidNumber", MatchingRule: "1.2.840.113556.1.4.804", Value: "6"}, true},
+		{Extensible{MatchingRule: "caseIgnoreMatch", Value: "1003"}, true},
+		{Extensible{Attribute: "ou", Value: "people"}, false},
+		{Extensible{Attribute: "ou", DNAttributes: true, Value: "people"}, true},
+		{Extensible{MatchingRule: "caseExactMatch", DNAttributes: true, Value: "lab"}, true},
+		{Extensible{Attribute: "uid", MatchingRule: "madeUpMatch", Value: "person3"}, false},
+	}
+
+	for _, test := range tests {
+		Ω(test.extensible.Evaluate(&person3)).Should(gomega.Equal(test.matches), test.extensible.ToString())
+	}
+}
+
+func TestExtensibleToString(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	Ω((&Extensible{Attribute: "cn", DNAttributes: true, MatchingRule: "caseExactMatch", Value: "a)"}).ToString()).Should(gomega.Equal("(cn:dn:caseExactMatch:=a\\29)"))
+	Ω((&Extensible{MatchingRule: "2.5.13.5", Value: "x"}).ToString()).Should(gomega.Equal("(:2.5.13.5:=x)"))
+}
@@ -0,0 +1,249 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nmcclain/ldap"
+)
+
+// IndexType is a kind of lookup which an attribute index can answer. The names are those
+// used by OpenLDAP.
+type IndexType string
+
+const (
+	IndexEquality   IndexType = "eq"   // Values equal to an assertion value, e.g. (uid=user)
+	IndexPresence   IndexType = "pres" // Entries with the attribute, e.g. (mail=*)
+	IndexSubstrings IndexType = "sub"  // Values starting with an initial substring, e.g. (cn=dev*)
+)
+
+// ParseIndexType returns the index type with the given name.
+func ParseIndexType(name string) (IndexType, error) {
+	switch t := IndexType(strings.ToLower(name)); t {
+	case IndexEquality, IndexPresence, IndexSubstrings:
+		return t, nil
+	}
+	return "", fmt.Errorf("unknown index type '%s', expected one of eq, pres or sub", name)
+}
+
+// Index records which entries hold which values of a set of attributes, so that the entries
+// which could match a filter are found without evaluating the filter against every entry.
+// Entries are identified by their position in the slice the index was built from. An index
+// is never changed once it has been built.
+type Index struct {
+	attributes map[string]*attributeIndex // The indexed attributes keyed by their lower-cased names
+}
+
+// attributeIndex holds the lookups for a single attribute. Lookups which weren't asked for
+// are nil.
+type attributeIndex struct {
+	equality map[string][]int // The entries holding each value, normalised by the equality rule
+	presence []int            // The entries holding any value
+	initial  []indexedValue   // The values normalised by the substrings rule, sorted by value
+}
+
+// indexedValue is a normalised value and an entry which holds it.
+type indexedValue struct {
+	value string
+	entry int
+}
+
+// NewIndex indexes the attributes of the entries. The attributes map the name of each
+// attribute to the lookups which should be possible.
+func NewIndex(entries []*ldap.Entry, attributes map[string][]IndexType) *Index {
+	x := &Index{attributes: make(map[string]*attributeIndex)}
+	for name, types := range attributes {
+		a := &attributeIndex{}
+		for _, t := range types {
+			switch t {
+			case IndexEquality:
+				a.equality = make(map[string][]int)
+			case IndexPresence:
+				a.presence = make([]int, 0)
+			case IndexSubstrings:
+				a.initial = make([]indexedValue, 0)
+			}
+		}
+		a.add(name, entries)
+		x.attributes[strings.ToLower(name)] = a
+	}
+	return x
+}
+
+// add indexes the values of the attribute held by each entry.
+func (a *attributeIndex) add(attribute string, entries []*ldap.Entry) {
+	equality, substrings := EqualityMatch(attribute), SubstringsMatch(attribute)
+	for i, entry := range entries {
+		values := attributeValues(entry, attribute)
+		if len(values) == 0 {
+			continue
+		}
+		if a.presence != nil {
+			a.presence = append(a.presence, i)
+		}
+		for _, v := range values {
+			if normal, ok := equality.Normalize(v); ok && a.equality != nil {
+				if ids := a.equality[normal]; len(ids) == 0 || ids[len(ids)-1] != i {
+					a.equality[normal] = append(ids, i)
+				}
+			}
+			if normal, ok := substrings.Normalize(v); ok && a.initial != nil {
+				a.initial = append(a.initial, indexedValue{value: normal, entry: i})
+			}
+		}
+	}
+	sort.SliceStable(a.initial, func(i, j int) bool { return a.initial[i].value < a.initial[j].value })
+}
+
+// lookup returns the index of the attribute if it answers the kind of lookup.
+func (x *Index) lookup(attribute string, t IndexType) (*attributeIndex, bool) {
+	if x == nil {
+		return nil, false
+	}
+	a, ok := x.attributes[strings.ToLower(attribute)]
+	if !ok {
+		return nil, false
+	}
+	switch t {
+	case IndexEquality:
+		return a, a.equality != nil
+	case IndexPresence:
+		return a, a.presence != nil
+	case IndexSubstrings:
+		return a, a.initial != nil
+	}
+	return nil, false
+}
+
+// Plan works out which entries could match the filter using the index. The entries are
+// returned in the order they were indexed and must not be changed. They must still be
+// evaluated against the filter, as the index only narrows them down. Plan returns false if
+// the index can't answer the filter, in which case every entry has to be evaluated.
+//
+// Equality, presence and substring conditions with an initial part are answered by the
+// index of their attribute. An And is answered by the children which can be answered, while
+// an Or can only be answered if every child can be.
+func (x *Index) Plan(q Evaluator) ([]int, bool) {
+	switch c := q.(type) {
+	case *And:
+		var result []int
+		planned := false
+		for _, condition := range c.Conditions {
+			if ids, ok := x.Plan(condition); ok {
+				if !planned {
+					result, planned = ids, true
+				} else {
+					result = intersect(result, ids)
+				}
+			}
+		}
+		return result, planned
+	case *Or:
+		result := make([]int, 0)
+		for _, condition := range c.Conditions {
+			ids, ok := x.Plan(condition)
+			if !ok {
+				return nil, false
+			}
+			result = union(result, ids)
+		}
+		return result, true
+	case *Equals:
+		m := c.matcher
+		if m == nil {
+			m = compileEquals(c.Attribute, c.Value)
+		}
+		return x.planMatcher(c.Attribute, m)
+	case *Substrings:
+		m := c.matcher
+		if m == nil {
+			m = compileSubstrings(c.Attribute, c.Initial, c.Any, c.Final)
+		}
+		return x.planMatcher(c.Attribute, m)
+	case *Present:
+		a, ok := x.lookup(c.Attribute, IndexPresence)
+		if !ok {
+			return nil, false
+		}
+		return a.presence, true
+	}
+	return nil, false
+}
+
+// planMatcher finds the entries which could match a compiled equality or substrings assertion.
+func (x *Index) planMatcher(attribute string, m *substringMatcher) ([]int, bool) {
+	// assertions which can't be compiled or normalised never match.
+	if m == nil || m.rule == nil {
+		return []int{}, true
+	}
+
+	if m.exact {
+		a, ok := x.lookup(attribute, IndexEquality)
+		if !ok || m.rule.match != nil {
+			return nil, false
+		}
+		return a.equality[m.initial], true
+	}
+
+	a, ok := x.lookup(attribute, IndexSubstrings)
+	if !ok || m.initial == "" {
+		return nil, false
+	}
+	start := sort.Search(len(a.initial), func(i int) bool { return a.initial[i].value >= m.initial })
+	result := make([]int, 0)
+	for i := start; i < len(a.initial) && strings.HasPrefix(a.initial[i].value, m.initial); i++ {
+		result = append(result, a.initial[i].entry)
+	}
+	sort.Ints(result)
+	return unique(result), true
+}
+
+// intersect returns the entries in both sorted lists.
+func intersect(a, b []int) []int {
+	result := make([]int, 0)
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i, j = i+1, j+1
+		}
+	}
+	return result
+}
+
+// union returns the entries in either sorted list.
+func union(a, b []int) []int {
+	result := make([]int, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			result = append(result, a[i])
+			i++
+		case a[i] > b[j]:
+			result = append(result, b[j])
+			j++
+		default:
+			result = append(result, a[i])
+			i, j = i+1, j+1
+		}
+	}
+	result = append(result, a[i:]...)
+	return append(result, b[j:]...)
+}
+
+// unique removes repeated entries from a sorted list.
+func unique(ids []int) []int {
+	result := ids[:0]
+	for _, id := range ids {
+		if len(result) == 0 || id != result[len(result)-1] {
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,139 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+)
+
+// testIndex indexes the device entries used by the benchmarks.
+func testIndex(entries []*ldap.Entry) *Index {
+	return NewIndex(entries, map[string][]IndexType{
+		"cn":          {IndexEquality, IndexSubstrings},
+		"objectClass": {IndexEquality, IndexPresence},
+		"macAddress":  {IndexEquality},
+	})
+}
+
+// scan returns the positions of the entries matching the filter by evaluating it against every entry.
+func scan(entries []*ldap.Entry, q Evaluator) []int {
+	result := make([]int, 0)
+	for i, entry := range entries {
+		if q.Evaluate(entry) {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+func TestIndexPlan(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	entries := benchmarkEntries(300)
+	index := testIndex(entries)
+	tests := map[string][]int{
+		"(cn=device42)":                                 {42},
+		"(CN=DEVICE42)":                                 {42},
+		"(cn=device4*)":                                 {4, 40, 41, 42, 43, 44, 45, 46, 47, 48, 49},
+		"(cn=nothing)":                                  nil,
+		"(objectClass=*)":                               scan(entries, &Present{Attribute: "objectClass"}),
+		"(&(objectClass=device)(cn=device1))":           {1},
+		"(|(cn=device1)(cn=device2))":                   {1, 2},
+		"(|(cn=device1)(macAddress=00:1a:2b:00:00:02))": {1, 2},
+		"(&(cn=device7)(description=*room 7))":          {7},
+		"(&(cn=device7)(!(cn=device8)))":                {7},
+		"(&(cn=device1*)(cn=device2*))":                 {},
+	}
+	for filter, expected := range tests {
+		q, _, err := Parse(filter, 0)
+		Ω(err).Should(gomega.BeNil())
+		ids, ok := index.Plan(q)
+		Ω(ok).Should(gomega.BeTrue(), filter)
+		if len(expected) == 0 {
+			Ω(ids).Should(gomega.BeEmpty(), filter)
+		} else {
+			Ω(ids).Should(gomega.Equal(expected), filter)
+		}
+	}
+}
+
+func TestIndexPlanFallsBack(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	index := testIndex(benchmarkEntries(10))
+	for _, filter := range []string{
+		"(description=IoT sensor in room 1)",
+		"(cn=*ice1)",
+		"(macAddress=00:1a*)",
+		"(description=*)",
+		"(cn=*)",
+		"(!(cn=device1))",
+		"(cn>=device1)",
+		"(cn~=device1)",
+		"(|(cn=device1)(description=*room 2))",
+		"(&(!(cn=device1))(description=*))",
+		"(&)",
+	} {
+		q, _, err := Parse(filter, 0)
+		Ω(err).Should(gomega.BeNil())
+		_, ok := index.Plan(q)
+		Ω(ok).Should(gomega.BeFalse(), filter)
+	}
+
+	// without an index nothing can be planned.
+	var none *Index
+	_, ok := none.Plan(&Present{Attribute: "cn"})
+	Ω(ok).Should(gomega.BeFalse())
+}
+
+// TestIndexPlanFindsEveryMatch checks that the planned entries include every entry which
+// matches the filter, so that evaluating only those entries gives the same result as a scan.
+func TestIndexPlanFindsEveryMatch(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	entries := benchmarkEntries(500)
+	index := testIndex(entries)
+	for _, filter := range []string{
+		"(&(objectClass=device)(|(cn=device1*)(macAddress=00:1a:2b:00:01:ff))(!(description=*room 0)))",
+		"(&(objectClass=ieee802Device)(cn=device4*)(description=*room 4*))",
+		"(|(cn=device12*)(cn=device3))",
+		"(&(objectClass=*)(cn=Device  49*))",
+		"(|)",
+		"(&(cn=device1)(|))",
+	} {
+		q, _, err := Parse(filter, 0)
+		Ω(err).Should(gomega.BeNil())
+		ids, ok := index.Plan(q)
+		Ω(ok).Should(gomega.BeTrue(), filter)
+
+		matched := make([]int, 0)
+		for _, id := range ids {
+			if q.Evaluate(entries[id]) {
+				matched = append(matched, id)
+			}
+		}
+		Ω(matched).Should(gomega.Equal(scan(entries, q)), filter)
+		Ω(len(ids)).Should(gomega.BeNumerically("<", len(entries)), filter)
+	}
+}
+
+func TestParseIndexType(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	for name, expected := range map[string]IndexType{"eq": IndexEquality, "PRES": IndexPresence, "sub": IndexSubstrings} {
+		t, err := ParseIndexType(name)
+		Ω(err).Should(gomega.BeNil())
+		Ω(t).Should(gomega.Equal(expected))
+	}
+	_, err := ParseIndexType("approx")
+	Ω(err).Should(gomega.MatchError("unknown index type 'approx', expected one of eq, pres or sub"))
+}
@@ -0,0 +1,119 @@
+package query
+
+import (
+	"strings"
+
+	"github.com/nmcclain/ldap"
+)
+
+// substringMatcher is the compiled form of an assertion value. The parts are normalised once
+// using the attribute's matching rule when the matcher is compiled and matched literally, so
+// no character in the value has any special meaning.
+type substringMatcher struct {
+	rule    *MatchingRule // The rule used to normalise values, nil if the assertion isn't valid for the rule
+	exact   bool          // The value must equal initial, there are no wildcards
+	initial string        // The value must start with this
+	any     []string      // The value must contain these in order after the initial part
+	final   string        // The value must end with this after the other parts
+	length  int           // The minimum length of a matching value
+}
+
+// compileSubstrings compiles the parts of a substring assertion using the attribute's
+// substrings matching rule.
+func compileSubstrings(attribute, initial string, any []string, final string) *substringMatcher {
+	rule := SubstringsMatch(attribute)
+	parts := append(append([]string{initial}, any...), final)
+	for i, part := range parts {
+		normal, ok := rule.Normalize(part)
+		if !ok {
+			return &substringMatcher{}
+		}
+		parts[i] = normal
+	}
+
+	m := &substringMatcher{rule: rule, initial: parts[0], any: parts[1 : len(parts)-1], final: parts[len(parts)-1]}
+	for _, part := range parts {
+		m.length += len(part)
+	}
+	return m
+}
+
+// compileEquals compiles an equality assertion held in its escaped filter form, where an
+// unescaped '*' is a wildcard. Values without wildcards are compared using the attribute's
+// equality matching rule.
+func compileEquals(attribute, value string) *substringMatcher {
+	parts := strings.Split(value, "*")
+	for i, part := range parts {
+		unescaped, err := unescapeValue(part)
+		if err != nil {
+			return nil
+		}
+		parts[i] = unescaped
+	}
+
+	if len(parts) > 1 {
+		return compileSubstrings(attribute, parts[0], parts[1:len(parts)-1], parts[len(parts)-1])
+	}
+
+	rule := EqualityMatch(attribute)
+	normal, ok := rule.Normalize(parts[0])
+	if !ok {
+		return &substringMatcher{}
+	}
+	return &substringMatcher{rule: rule, exact: true, initial: normal}
+}
+
+// test matches the values of the attribute in the entry. The result is undefined if the
+// assertion isn't valid for the rule, whether or not the entry has the attribute.
+func (m *substringMatcher) test(entry *ldap.Entry, attribute string) Result {
+	if m.rule == nil {
+		return Undefined
+	}
+	for _, a := range entry.Attributes {
+		if !strings.EqualFold(a.Name, attribute) {
+			continue
+		}
+		for _, v := range a.Values {
+			if m.matches(v) {
+				return True
+			}
+		}
+	}
+	return False
+}
+
+// matches returns true if the value matches once it has been normalised.
+func (m *substringMatcher) matches(value string) bool {
+	if m.rule == nil {
+		return false
+	}
+	if m.exact && m.rule.match != nil {
+		return m.rule.match(value, m.initial)
+	}
+
+	value, ok := m.rule.Normalize(value)
+	if !ok {
+		return false
+	}
+	if m.exact {
+		return value == m.initial
+	}
+	if len(value) < m.length {
+		return false
+	}
+
+	if !strings.HasPrefix(value, m.initial) || !strings.HasSuffix(value, m.final) {
+		return false
+	}
+
+	// the any parts must fit between the initial and final parts without overlapping them.
+	value = value[len(m.initial) : len(value)-len(m.final)]
+	for _, part := range m.any {
+		i := strings.Index(value, part)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestMatcherIsLiteral(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	// regular expression characters have no special meaning.
+	tests := []struct {
+		value   string
+		pattern string
+		matches bool
+	}{
+		{"a+b", "a+b", true},
+		{"aab", "a+b", false},
+		{"person1", "pers?n*", false},
+		{"pers?n1", "pers?n*", true},
+		{"[admin]", "[admin]*", true},
+		{"a", "[admin]*", false},
+		{"x.y", "x.*", true},
+		{"xzy", "x.*", false},
+		{"(home)", "*\\28home\\29", true},
+		{"a^b$", "a^b$", true},
+		{"a|b", "*|*", true},
+		{"ab", "a|b", false},
+	}
+
+	for _, test := range tests {
+		Ω(compileEquals("cn", test.pattern).matches(test.value)).Should(gomega.Equal(test.matches), test.value+" "+test.pattern)
+	}
+}
+
+func TestMatcherParts(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	// the initial, any and final parts must not overlap.
+	Ω(compileSubstrings("cn", "ab", nil, "ba").matches("aba")).Should(gomega.Equal(false))
+	Ω(compileSubstrings("cn", "ab", nil, "ba").matches("abba")).Should(gomega.Equal(true))
+	Ω(compileSubstrings("cn", "a", []string{"b"}, "c").matches("abc")).Should(gomega.Equal(true))
+	Ω(compileSubstrings("cn", "a", []string{"b"}, "c").matches("ac")).Should(gomega.Equal(false))
+	Ω(compileSubstrings("cn", "a", []string{"bc"}, "c").matches("abc")).Should(gomega.Equal(false))
+	Ω(compileSubstrings("cn", "", []string{"b", "b"}, "").matches("abab")).Should(gomega.Equal(true))
+	Ω(compileSubstrings("cn", "", []string{"b", "b"}, "").matches("ab")).Should(gomega.Equal(false))
+
+	// case is ignored.
+	Ω(compileSubstrings("cn", "PER", []string{"SO"}, "N1").matches("person1")).Should(gomega.Equal(true))
+	Ω(compileEquals("cn", "Person1").matches("PERSON1")).Should(gomega.Equal(true))
+
+	// an exact value must match all of the value.
+	Ω(compileEquals("cn", "person").matches("person1")).Should(gomega.Equal(false))
+
+	// invalid escapes never match.
+	Ω(compileEquals("cn", "a\\zz")).Should(gomega.BeNil())
+	Ω((&Equals{Attribute: "uid", Value: "a\\zz"}).Evaluate(&person1)).Should(gomega.Equal(false))
+}
@@ -13,7 +13,19 @@ type Not struct {
 
 // Evaluate evaluates the query against the specified ldap entry.
 func (n *Not) Evaluate(entry *ldap.Entry) bool {
-	return !n.Parent.Evaluate(entry)
+	return n.Test(entry) == True
+}
+
+// Test evaluates the query against the entry. The negation of an undefined condition is
+// still undefined.
+func (n *Not) Test(entry *ldap.Entry) Result {
+	switch n.Parent.Test(entry) {
+	case True:
+		return False
+	case False:
+		return True
+	}
+	return Undefined
 }
 
 // ToString produces a string version of this query condition
@@ -27,7 +39,7 @@ func ParseNot(expression string, offset int) (*Not, int, error) {
 	}
 
 	if !strings.HasPrefix(expression[offset:], "(!") {
-		return nil, -1, errors.InvalidExpression("not")
+		return nil, -1, errors.InvalidExpressionAt("not", offset, "expected '(!'")
 	}
 
 	eq, pos, err := Parse(expression, offset+2)
@@ -36,7 +48,7 @@ func ParseNot(expression string, offset int) (*Not, int, error) {
 	}
 
 	if pos > len(expression)-1 || expression[pos] != ')' {
-		return nil, -1, errors.InvalidExpression("not")
+		return nil, -1, errors.InvalidExpressionAt("not", pos, "missing ')'")
 	}
 
 	return &Not{Parent: eq}, pos+1, nil
@@ -50,6 +50,24 @@ func TestNotEvaluationNonEmpty(t *testing.T) {
 	Ω(not.Evaluate(&person2)).Should(gomega.Equal(true))
 }
 
+func TestNotEvaluationUndefined(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	// the negation of an undefined condition is undefined, so it doesn't match either.
+	for _, expression := range []string{"(!(uidNumber=abc))", "(!(userPassword>=a))", "(!(uidNumber:2.5.13.14:=abc))"} {
+		not, _, err := Parse(expression, 0)
+		Ω(err).Should(gomega.BeNil())
+		Ω(not.Test(&person3)).Should(gomega.Equal(Undefined), expression)
+		Ω(not.Evaluate(&person3)).Should(gomega.Equal(false), expression)
+	}
+
+	not, _, err := Parse("(!(uidNumber=1004))", 0)
+	Ω(err).Should(gomega.BeNil())
+	Ω(not.Test(&person3)).Should(gomega.Equal(True))
+}
+
 func TestNotToString(t *testing.T) {
 	gomega.RegisterTestingT(t)
 	// workaround as our naming clashes with gomega.
@@ -85,12 +103,12 @@ func TestNotParseMissingBracket(t *testing.T) {
 	expression := "(!(uid=person1)"
 	_, _, err := ParseNot(expression, 0)
 	Ω(err).ShouldNot(gomega.BeNil())
-	Ω(err).Should(gomega.Equal(errors.InvalidExpression("not")))
+	Ω(err).Should(gomega.MatchError(errors.InvalidExpression("not")))
 
 	expression = "!(uid=person1))"
 	_, _, err = ParseNot(expression, 0)
 	Ω(err).ShouldNot(gomega.BeNil())
-	Ω(err).Should(gomega.Equal(errors.InvalidExpression("not")))
+	Ω(err).Should(gomega.MatchError(errors.InvalidExpression("not")))
 }
 
 func TestNotParseSillyOffsets(t *testing.T) {
@@ -117,5 +135,5 @@ func TestNotParseNoEquals(t *testing.T) {
 	expression := "(!)"
 	_, _, err := ParseNot(expression, 0)
 	Ω(err).ShouldNot(gomega.BeNil())
-	Ω(err).Should(gomega.Equal(errors.InvalidExpression("")))
+	Ω(err).Should(gomega.MatchError(errors.InvalidExpression("")))
 }
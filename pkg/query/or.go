@@ -11,12 +11,22 @@ type Or struct {
 }
 
 func (o *Or) Evaluate(entry *ldap.Entry) bool {
+	return o.Test(entry) == True
+}
+
+// Test evaluates the query against the entry. It is true if any condition is true, otherwise
+// it is undefined if any condition is undefined.
+func (o *Or) Test(entry *ldap.Entry) Result {
+	result := False
 	for _, condition := range o.Conditions {
-		if condition.Evaluate(entry) {
-			return true
+		switch condition.Test(entry) {
+		case True:
+			return True
+		case Undefined:
+			result = Undefined
 		}
 	}
-	return false
+	return result
 }
 
 // ToString produces a string version of this query condition
@@ -37,28 +47,13 @@ func ParseOr(expression string, offset int) (*Or, int, error) {
 	}
 
 	if !strings.HasPrefix(expression[offset:], "(|") {
-		return nil, -1, errors.InvalidExpression("or")
-	}
-
-	and := &Or{Conditions: make([]Evaluator, 0)}
-	var cond Evaluator
-	var err error
-	offset = offset+2
-	for offset < len(expression) -1 {
-		cond, offset, err = Parse(expression, offset)
-		if err != nil {
-			return nil, -1, err
-		}
-		and.Conditions = append(and.Conditions, cond)
-	}
-
-	if offset > len(expression)-1 || expression[offset] != ')' {
-		return nil, -1, errors.InvalidExpression("or")
+		return nil, -1, errors.InvalidExpressionAt("or", offset, "expected '(|'")
 	}
 
-	if len(and.Conditions) == 0 {
-		return nil, -1, errors.InvalidExpression("or")
+	conditions, offset, err := parseConditions(expression, offset+2, "or")
+	if err != nil {
+		return nil, -1, err
 	}
 
-	return and, offset+1, nil
+	return &Or{Conditions: conditions}, offset+1, nil
 }
\ No newline at end of file
@@ -107,12 +107,12 @@ func TestOrParseMissingBracket(t *testing.T) {
 	expression := "(|(uid=person1)(objectClass=inetOrgPerson)"
 	_, _, err := ParseOr(expression, 0)
 	Ω(err).ShouldNot(gomega.BeNil())
-	Ω(err).Should(gomega.Equal(errors.InvalidExpression("or")))
+	Ω(err).Should(gomega.MatchError(errors.InvalidExpression("or")))
 
 	expression = "|(uid=person1)(objectClass=inetOrgPerson))"
 	_, _, err = ParseOr(expression, 0)
 	Ω(err).ShouldNot(gomega.BeNil())
-	Ω(err).Should(gomega.Equal(errors.InvalidExpression("or")))
+	Ω(err).Should(gomega.MatchError(errors.InvalidExpression("or")))
 }
 
 func TestOrParseSillyOffsets(t *testing.T) {
@@ -136,10 +136,18 @@ func TestOrParseNoEquals(t *testing.T) {
 	// workaround as our naming clashes with gomega.
 	Ω := gomega.Ω
 
+	// an empty or is the absolute false filter (RFC 4526).
 	expression := "(|)"
-	_, _, err := ParseOr(expression, 0)
-	Ω(err).ShouldNot(gomega.BeNil())
-	Ω(err).Should(gomega.Equal(errors.InvalidExpression("or")))
+	cond, offset, err := ParseOr(expression, 0)
+	Ω(err).Should(gomega.BeNil())
+	Ω(offset).Should(gomega.Equal(len(expression)))
+	Ω(cond.ToString()).Should(gomega.Equal(expression))
+	Ω(cond.Evaluate(&person1)).Should(gomega.Equal(false))
+	Ω(cond.Evaluate(&person2)).Should(gomega.Equal(false))
+
+	expression = "(|"
+	_, _, err = ParseOr(expression, 0)
+	Ω(err).Should(gomega.MatchError(errors.InvalidExpression("or")))
 }
 
 
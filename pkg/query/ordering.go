@@ -0,0 +1,72 @@
+package query
+
+import (
+	"fmt"
+
+	"github.com/nmcclain/ldap"
+)
+
+// GreaterOrEqual conditions match attribute values which sort at or after the value using the
+// attribute's ordering matching rule, e.g. (uidNumber>=1000).
+type GreaterOrEqual struct {
+	Attribute string // The attribute whose values are compared
+	Value     string // The value to compare against
+}
+
+// Evaluate evaluates the query against the specified ldap entry.
+func (g *GreaterOrEqual) Evaluate(entry *ldap.Entry) bool {
+	return g.Test(entry) == True
+}
+
+// Test evaluates the query against the entry. It is undefined if the attribute has no ordering
+// rule or the value isn't valid for it.
+func (g *GreaterOrEqual) Test(entry *ldap.Entry) Result {
+	return compareValues(entry, g.Attribute, g.Value, func(c int) bool { return c >= 0 })
+}
+
+// ToString produces a string version of this query condition
+func (g *GreaterOrEqual) ToString() string {
+	return fmt.Sprintf("(%s>=%s)", g.Attribute, escapeValue(g.Value))
+}
+
+// LessOrEqual conditions match attribute values which sort at or before the value using the
+// attribute's ordering matching rule, e.g. (uidNumber<=1000).
+type LessOrEqual struct {
+	Attribute string // The attribute whose values are compared
+	Value     string // The value to compare against
+}
+
+// Evaluate evaluates the query against the specified ldap entry.
+func (l *LessOrEqual) Evaluate(entry *ldap.Entry) bool {
+	return l.Test(entry) == True
+}
+
+// Test evaluates the query against the entry. It is undefined if the attribute has no ordering
+// rule or the value isn't valid for it.
+func (l *LessOrEqual) Test(entry *ldap.Entry) Result {
+	return compareValues(entry, l.Attribute, l.Value, func(c int) bool { return c <= 0 })
+}
+
+// ToString produces a string version of this query condition
+func (l *LessOrEqual) ToString() string {
+	return fmt.Sprintf("(%s<=%s)", l.Attribute, escapeValue(l.Value))
+}
+
+// compareValues compares the values of the attribute in the entry with the value using the
+// attribute's ordering rule, and is true if any comparison is accepted. Registered attributes
+// without an ordering rule can't be ordered, so the result is undefined for them.
+func compareValues(entry *ldap.Entry, attribute, value string, accept func(c int) bool) Result {
+	if t, ok := LookupAttributeType(attribute); ok && t.Ordering == "" {
+		return Undefined
+	}
+	rule := OrderingMatch(attribute)
+	if _, ok := rule.Normalize(value); !ok {
+		return Undefined
+	}
+	for _, v := range attributeValues(entry, attribute) {
+		if c, ok := rule.Compare(v, value); ok && accept(c) {
+			return True
+		}
+	}
+	return False
+}
@@ -0,0 +1,49 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestGreaterOrEqualEvaluation(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	Ω((&GreaterOrEqual{Attribute: "uidNumber", Value: "1003"}).Evaluate(&person3)).Should(gomega.Equal(true))
+	Ω((&GreaterOrEqual{Attribute: "uidNumber", Value: "999"}).Evaluate(&person3)).Should(gomega.Equal(true))
+	Ω((&GreaterOrEqual{Attribute: "uidNumber", Value: "1004"}).Evaluate(&person3)).Should(gomega.Equal(false))
+	Ω((&GreaterOrEqual{Attribute: "uid", Value: "PERSON2"}).Evaluate(&person3)).Should(gomega.Equal(true))
+	Ω((&GreaterOrEqual{Attribute: "sn", Value: "a"}).Evaluate(&person3)).Should(gomega.Equal(false))
+}
+
+func TestLessOrEqualEvaluation(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	Ω((&LessOrEqual{Attribute: "uidNumber", Value: "1003"}).Evaluate(&person3)).Should(gomega.Equal(true))
+	Ω((&LessOrEqual{Attribute: "uidNumber", Value: "999"}).Evaluate(&person3)).Should(gomega.Equal(false))
+	Ω((&LessOrEqual{Attribute: "uid", Value: "person4"}).Evaluate(&person3)).Should(gomega.Equal(true))
+}
+
+func TestOrderingUndefined(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	// the value isn't an integer, or the attribute has no ordering rule.
+	Ω((&GreaterOrEqual{Attribute: "uidNumber", Value: "abc"}).Test(&person3)).Should(gomega.Equal(Undefined))
+	Ω((&LessOrEqual{Attribute: "userPassword", Value: "a"}).Test(&person1)).Should(gomega.Equal(Undefined))
+	Ω((&GreaterOrEqual{Attribute: "uidNumber", Value: "1004"}).Test(&person3)).Should(gomega.Equal(False))
+}
+
+func TestOrderingToString(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	Ω((&GreaterOrEqual{Attribute: "uidNumber", Value: "1000"}).ToString()).Should(gomega.Equal("(uidNumber>=1000)"))
+	Ω((&LessOrEqual{Attribute: "cn", Value: "a*"}).ToString()).Should(gomega.Equal("(cn<=a\\2a)"))
+}
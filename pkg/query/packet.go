@@ -0,0 +1,183 @@
+package query
+
+import (
+	"fmt"
+
+	ber "github.com/nmcclain/asn1-ber"
+	"github.com/shauncampbell/dapper/pkg/query/errors"
+)
+
+// The context specific tags of the Filter CHOICE (RFC 4511 section 4.5.1).
+const (
+	filterAnd             = 0
+	filterOr              = 1
+	filterNot             = 2
+	filterEqualityMatch   = 3
+	filterSubstrings      = 4
+	filterGreaterOrEqual  = 5
+	filterLessOrEqual     = 6
+	filterPresent         = 7
+	filterApproxMatch     = 8
+	filterExtensibleMatch = 9
+)
+
+// The context specific tags of the parts of a SubstringFilter.
+const (
+	substringInitial = 0
+	substringAny     = 1
+	substringFinal   = 2
+)
+
+// The context specific tags of the fields of a MatchingRuleAssertion.
+const (
+	extensibleMatchingRule = 1
+	extensibleType         = 2
+	extensibleMatchValue   = 3
+	extensibleDNAttributes = 4
+)
+
+// ParsePacket turns a filter decoded from the BER encoding of a search request into an
+// Evaluator chain. Unlike Parse, which works on the string form of a filter, the attribute
+// values are used exactly as the client sent them.
+func ParsePacket(packet *ber.Packet) (cond Evaluator, err error) {
+	// the ber library leaves malformed packets partially decoded, so guard against missing children.
+	defer func() {
+		if r := recover(); r != nil {
+			cond, err = nil, errors.InvalidPacket("", fmt.Sprintf("%v", r))
+		}
+	}()
+
+	if packet == nil || packet.ClassType != ber.ClassContext {
+		return nil, errors.InvalidPacket("", "not a filter")
+	}
+
+	switch packet.Tag {
+	case filterAnd, filterOr:
+		// an empty and is absolutely true and an empty or is absolutely false (RFC 4526).
+		conditions := make([]Evaluator, 0, len(packet.Children))
+		for _, child := range packet.Children {
+			c, err := ParsePacket(child)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, c)
+		}
+		if packet.Tag == filterAnd {
+			return &And{Conditions: conditions}, nil
+		}
+		return &Or{Conditions: conditions}, nil
+
+	case filterNot:
+		if len(packet.Children) != 1 {
+			return nil, errors.InvalidPacket("not", "expected exactly one condition")
+		}
+		c, err := ParsePacket(packet.Children[0])
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Parent: c}, nil
+
+	case filterEqualityMatch, filterGreaterOrEqual, filterLessOrEqual, filterApproxMatch:
+		if len(packet.Children) != 2 {
+			return nil, errors.InvalidPacket(filterName(packet.Tag), "expected an attribute and a value")
+		}
+		attribute := packet.Children[0].Data.String()
+		value := packet.Children[1].Data.String()
+		if attribute == "" {
+			return nil, errors.InvalidPacket(filterName(packet.Tag), "missing attribute description")
+		}
+
+		switch packet.Tag {
+		case filterGreaterOrEqual:
+			return &GreaterOrEqual{Attribute: attribute, Value: value}, nil
+		case filterLessOrEqual:
+			return &LessOrEqual{Attribute: attribute, Value: value}, nil
+		case filterApproxMatch:
+			return &Approx{Attribute: attribute, Value: value}, nil
+		}
+		return NewAssertion(attribute, value), nil
+
+	case filterSubstrings:
+		return parseSubstringsPacket(packet)
+
+	case filterPresent:
+		if packet.Data.Len() == 0 {
+			return nil, errors.InvalidPacket("present", "missing attribute description")
+		}
+		return &Present{Attribute: packet.Data.String()}, nil
+
+	case filterExtensibleMatch:
+		return parseExtensiblePacket(packet)
+	}
+	return nil, errors.InvalidPacket("", fmt.Sprintf("unknown filter type %d", packet.Tag))
+}
+
+// parseSubstringsPacket decodes a SubstringFilter.
+func parseSubstringsPacket(packet *ber.Packet) (*Substrings, error) {
+	if len(packet.Children) != 2 || packet.Children[0].Data.Len() == 0 || len(packet.Children[1].Children) == 0 {
+		return nil, errors.InvalidPacket("substrings", "expected an attribute and at least one substring")
+	}
+
+	var initial, final string
+	any := []string{}
+	parts := packet.Children[1].Children
+	for i, part := range parts {
+		switch {
+		case part.Tag == substringInitial && i == 0:
+			initial = part.Data.String()
+		case part.Tag == substringAny:
+			any = append(any, part.Data.String())
+		case part.Tag == substringFinal && i == len(parts)-1:
+			final = part.Data.String()
+		default:
+			return nil, errors.InvalidPacket("substrings", "substrings are out of order")
+		}
+	}
+	return NewSubstrings(packet.Children[0].Data.String(), initial, any, final), nil
+}
+
+// parseExtensiblePacket decodes a MatchingRuleAssertion.
+func parseExtensiblePacket(packet *ber.Packet) (*Extensible, error) {
+	ext := &Extensible{}
+	hasValue := false
+	for _, child := range packet.Children {
+		switch child.Tag {
+		case extensibleMatchingRule:
+			ext.MatchingRule = child.Data.String()
+		case extensibleType:
+			ext.Attribute = child.Data.String()
+		case extensibleMatchValue:
+			ext.Value = child.Data.String()
+			hasValue = true
+		case extensibleDNAttributes:
+			ext.DNAttributes = len(child.Data.Bytes()) > 0 && child.Data.Bytes()[0] != 0
+		default:
+			return nil, errors.InvalidPacket("extensible", fmt.Sprintf("unknown field %d", child.Tag))
+		}
+	}
+
+	if !hasValue {
+		return nil, errors.InvalidPacket("extensible", "missing match value")
+	}
+	if ext.Attribute == "" && ext.MatchingRule == "" {
+		return nil, errors.InvalidPacket("extensible", "a matching rule is required when there is no attribute")
+	}
+	return ext, nil
+}
+
+// filterName returns the condition type used in error messages for a filter tag.
+func filterName(tag uint8) string {
+	switch tag {
+	case filterAnd:
+		return "and"
+	case filterOr:
+		return "or"
+	case filterGreaterOrEqual:
+		return "greaterOrEqual"
+	case filterLessOrEqual:
+		return "lessOrEqual"
+	case filterApproxMatch:
+		return "approx"
+	}
+	return "equals"
+}
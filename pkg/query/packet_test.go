@@ -0,0 +1,139 @@
+package query
+
+import (
+	"testing"
+
+	ber "github.com/nmcclain/asn1-ber"
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+	"github.com/shauncampbell/dapper/pkg/query/errors"
+)
+
+// assertion builds an attribute value assertion filter such as an equality match.
+func assertion(tag uint8, attribute, value string) *ber.Packet {
+	packet := ber.Encode(ber.ClassContext, ber.TypeConstructed, tag, nil, "")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attribute, ""))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, ""))
+	return packet
+}
+
+func TestParsePacketCompiledFilters(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	// filters compiled by the ldap library parse to the same conditions as the string form.
+	for _, expression := range []string{
+		"(uid=person1)",
+		"(uid=*)",
+		"(uid=per*)",
+		"(uid=*son1)",
+		"(uid=*rso*)",
+		"(uidNumber>=1000)",
+		"(uidNumber<=1000)",
+		"(cn~=person)",
+		"(&(uid=person1)(!(objectClass=jellyfinUser)))",
+		"(|(uid=person1)(uid=person2))",
+	} {
+		packet, err := ldap.CompileFilter(expression)
+		Ω(err).Should(gomega.BeNil(), expression)
+
+		// round trip through the encoding so the packet looks like one read from a client.
+		cond, err := ParsePacket(ber.DecodePacket(packet.Bytes()))
+		Ω(err).Should(gomega.BeNil(), expression)
+
+		expected, _, _ := Parse(expression, 0)
+		Ω(cond).Should(gomega.Equal(expected), expression)
+	}
+}
+
+func TestParsePacketValuesAreExact(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	cond, err := ParsePacket(ber.DecodePacket(assertion(filterEqualityMatch, "cn", "Person (Three)").Bytes()))
+	Ω(err).Should(gomega.BeNil())
+	Ω(cond.ToString()).Should(gomega.Equal("(cn=Person \\28Three\\29)"))
+	Ω(cond.Evaluate(&person3)).Should(gomega.Equal(true))
+
+	// a star in an equality match is not a wildcard.
+	cond, _ = ParsePacket(ber.DecodePacket(assertion(filterEqualityMatch, "uid", "person*").Bytes()))
+	Ω(cond.Evaluate(&person1)).Should(gomega.Equal(false))
+
+	// substrings keep all of their parts.
+	packet := ber.Encode(ber.ClassContext, ber.TypeConstructed, filterSubstrings, nil, "")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "cn", ""))
+	parts := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	parts.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, substringInitial, "per", ""))
+	parts.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, substringAny, "(", ""))
+	parts.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, substringAny, "e", ""))
+	parts.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, substringFinal, ")", ""))
+	packet.AppendChild(parts)
+	cond, err = ParsePacket(ber.DecodePacket(packet.Bytes()))
+	Ω(err).Should(gomega.BeNil())
+	Ω(cond).Should(gomega.Equal(NewSubstrings("cn", "per", []string{"(", "e"}, ")")))
+	Ω(cond.Evaluate(&person3)).Should(gomega.Equal(true))
+}
+
+func TestParsePacketExtensible(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	packet := ber.Encode(ber.ClassContext, ber.TypeConstructed, filterExtensibleMatch, nil, "")
+	packet.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, extensibleMatchingRule, "caseExactMatch", ""))
+	packet.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, extensibleType, "ou", ""))
+	packet.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, extensibleMatchValue, "people", ""))
+	packet.AppendChild(ber.NewBoolean(ber.ClassContext, ber.TypePrimitive, extensibleDNAttributes, true, ""))
+
+	cond, err := ParsePacket(ber.DecodePacket(packet.Bytes()))
+	Ω(err).Should(gomega.BeNil())
+	Ω(cond).Should(gomega.Equal(&Extensible{Attribute: "ou", MatchingRule: "caseExactMatch", DNAttributes: true, Value: "people"}))
+	Ω(cond.Evaluate(&person3)).Should(gomega.Equal(true))
+}
+
+func TestParsePacketAbsoluteFilters(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	// an empty and is absolutely true and an empty or is absolutely false (RFC 4526).
+	cond, err := ParsePacket(ber.DecodePacket(ber.Encode(ber.ClassContext, ber.TypeConstructed, filterAnd, nil, "").Bytes()))
+	Ω(err).Should(gomega.BeNil())
+	Ω(cond.ToString()).Should(gomega.Equal("(&)"))
+	Ω(cond.Evaluate(&person1)).Should(gomega.Equal(true))
+
+	cond, err = ParsePacket(ber.DecodePacket(ber.Encode(ber.ClassContext, ber.TypeConstructed, filterOr, nil, "").Bytes()))
+	Ω(err).Should(gomega.BeNil())
+	Ω(cond.ToString()).Should(gomega.Equal("(|)"))
+	Ω(cond.Evaluate(&person1)).Should(gomega.Equal(false))
+}
+
+func TestParsePacketErrors(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	_, err := ParsePacket(nil)
+	Ω(err).Should(gomega.MatchError(errors.InvalidExpression("")))
+
+	_, err = ParsePacket(ber.Encode(ber.ClassContext, ber.TypeConstructed, filterNot, nil, ""))
+	Ω(err).Should(gomega.MatchError(errors.InvalidExpression("not")))
+
+	_, err = ParsePacket(ber.Encode(ber.ClassContext, ber.TypeConstructed, filterEqualityMatch, nil, ""))
+	Ω(err).Should(gomega.MatchError(errors.InvalidExpression("equals")))
+
+	_, err = ParsePacket(ber.Encode(ber.ClassContext, ber.TypePrimitive, 12, nil, ""))
+	Ω(err).Should(gomega.MatchError(errors.InvalidExpression("")))
+
+	// substrings must be initial, any then final.
+	packet := ber.Encode(ber.ClassContext, ber.TypeConstructed, filterSubstrings, nil, "")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "cn", ""))
+	parts := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	parts.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, substringFinal, "a", ""))
+	parts.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, substringInitial, "b", ""))
+	packet.AppendChild(parts)
+	_, err = ParsePacket(packet)
+	Ω(err).Should(gomega.MatchError(errors.InvalidExpression("substrings")))
+}
@@ -0,0 +1,30 @@
+package query
+
+import (
+	"fmt"
+
+	"github.com/nmcclain/ldap"
+)
+
+// Present conditions match entries which have at least one value for the attribute, e.g. (mail=*).
+type Present struct {
+	Attribute string // The attribute which must be present
+}
+
+// Evaluate evaluates the query against the specified ldap entry.
+func (p *Present) Evaluate(entry *ldap.Entry) bool {
+	return p.Test(entry) == True
+}
+
+// Test evaluates the query against the entry. A presence condition is never undefined.
+func (p *Present) Test(entry *ldap.Entry) Result {
+	if len(attributeValues(entry, p.Attribute)) > 0 {
+		return True
+	}
+	return False
+}
+
+// ToString produces a string version of this query condition
+func (p *Present) ToString() string {
+	return fmt.Sprintf("(%s=*)", p.Attribute)
+}
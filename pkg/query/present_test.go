@@ -0,0 +1,28 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestPresentEvaluation(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	present := Present{Attribute: "USERPASSWORD"}
+
+	Ω(present.Evaluate(&person1)).Should(gomega.Equal(true))
+	Ω(present.Evaluate(&person2)).Should(gomega.Equal(false))
+}
+
+func TestPresentToString(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	present := Present{Attribute: "mail"}
+
+	Ω(present.ToString()).Should(gomega.Equal("(mail=*)"))
+}
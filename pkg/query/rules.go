@@ -0,0 +1,225 @@
+package query
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shauncampbell/dapper/pkg/dn"
+)
+
+// RuleKind says how a matching rule compares values.
+type RuleKind int
+
+const (
+	EqualityRule   RuleKind = iota // The rule decides whether two values are equal
+	OrderingRule                   // The rule decides whether one value sorts before another
+	SubstringsRule                 // The rule decides whether a value contains substrings
+)
+
+// MatchingRule is a way of comparing attribute values (RFC 4517 section 4). Equality and
+// substrings rules work by reducing values to a normal form, ordering rules by comparing
+// the normal forms. Values which are not valid for the rule never match.
+type MatchingRule struct {
+	Name      string                             // The name of the rule, e.g. caseIgnoreMatch
+	OID       string                             // The numeric OID of the rule
+	Kind      RuleKind                           // Whether this is an equality, ordering or substrings rule
+	Syntax    string                             // The OID of the syntax of assertion values
+	normalize func(value string) (string, bool)  // Reduces a value to its normal form
+	compare   func(a, b string) int              // Orders two normalised values, ordering rules only
+	match     func(value, assertion string) bool // Replaces normalisation for rules which aren't comparisons
+}
+
+// Normalize reduces a value to the form in which the rule compares it. It returns false if
+// the value is not valid for the rule.
+func (r *MatchingRule) Normalize(value string) (string, bool) {
+	if r.normalize == nil {
+		return value, true
+	}
+	return r.normalize(value)
+}
+
+// Equal returns true if the attribute value matches the assertion value using the rule.
+// For ordering rules this means the attribute value sorts before the assertion value.
+func (r *MatchingRule) Equal(value, assertion string) bool {
+	if r.match != nil {
+		return r.match(value, assertion)
+	}
+	if r.Kind == OrderingRule {
+		c, ok := r.Compare(value, assertion)
+		return ok && c < 0
+	}
+	v, ok := r.Normalize(value)
+	if !ok {
+		return false
+	}
+	a, ok := r.Normalize(assertion)
+	return ok && v == a
+}
+
+// Compare orders two values using an ordering rule, returning a negative number if a sorts
+// before b, zero if they are equal and a positive number if a sorts after b. It returns false
+// if the rule is not an ordering rule or either value is not valid for it.
+func (r *MatchingRule) Compare(a, b string) (int, bool) {
+	if r.Kind != OrderingRule {
+		return 0, false
+	}
+	x, ok := r.Normalize(a)
+	if !ok {
+		return 0, false
+	}
+	y, ok := r.Normalize(b)
+	if !ok {
+		return 0, false
+	}
+	if r.compare == nil {
+		return strings.Compare(x, y), true
+	}
+	return r.compare(x, y), true
+}
+
+// builtinMatchingRules are the matching rules the registry starts with.
+var builtinMatchingRules = []MatchingRule{
+	{Name: "objectIdentifierMatch", OID: "2.5.13.0", Kind: EqualityRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.38", normalize: caseIgnoreNormalize},
+	{Name: "distinguishedNameMatch", OID: "2.5.13.1", Kind: EqualityRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.12", normalize: dnNormalize},
+	{Name: "caseIgnoreMatch", OID: "2.5.13.2", Kind: EqualityRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.15", normalize: caseIgnoreNormalize},
+	{Name: "caseIgnoreOrderingMatch", OID: "2.5.13.3", Kind: OrderingRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.15", normalize: caseIgnoreNormalize},
+	{Name: "caseIgnoreSubstringsMatch", OID: "2.5.13.4", Kind: SubstringsRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.58", normalize: lowerNormalize},
+	{Name: "caseExactMatch", OID: "2.5.13.5", Kind: EqualityRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.15", normalize: caseExactNormalize},
+	{Name: "caseExactOrderingMatch", OID: "2.5.13.6", Kind: OrderingRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.15", normalize: caseExactNormalize},
+	{Name: "caseExactSubstringsMatch", OID: "2.5.13.7", Kind: SubstringsRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.58"},
+	{Name: "numericStringMatch", OID: "2.5.13.8", Kind: EqualityRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.36", normalize: numericStringNormalize},
+	{Name: "numericStringOrderingMatch", OID: "2.5.13.9", Kind: OrderingRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.36", normalize: numericStringNormalize, compare: compareIntegers},
+	{Name: "numericStringSubstringsMatch", OID: "2.5.13.10", Kind: SubstringsRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.58", normalize: removeSpaces},
+	{Name: "booleanMatch", OID: "2.5.13.13", Kind: EqualityRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.7", normalize: booleanNormalize},
+	{Name: "integerMatch", OID: "2.5.13.14", Kind: EqualityRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.27", normalize: integerNormalize},
+	{Name: "integerOrderingMatch", OID: "2.5.13.15", Kind: OrderingRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.27", normalize: integerNormalize, compare: compareIntegers},
+	{Name: "octetStringMatch", OID: "2.5.13.17", Kind: EqualityRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.40"},
+	{Name: "octetStringOrderingMatch", OID: "2.5.13.18", Kind: OrderingRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.40"},
+	{Name: "octetStringSubstringsMatch", OID: "2.5.13.19", Kind: SubstringsRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.40"},
+	{Name: "telephoneNumberMatch", OID: "2.5.13.20", Kind: EqualityRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.50", normalize: telephoneNumberNormalize},
+	{Name: "telephoneNumberSubstringsMatch", OID: "2.5.13.21", Kind: SubstringsRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.58", normalize: telephoneNumberNormalize},
+	{Name: "uniqueMemberMatch", OID: "2.5.13.23", Kind: EqualityRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.34", normalize: dnNormalize},
+	{Name: "generalizedTimeMatch", OID: "2.5.13.27", Kind: EqualityRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.24", normalize: generalizedTimeNormalize},
+	{Name: "generalizedTimeOrderingMatch", OID: "2.5.13.28", Kind: OrderingRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.24", normalize: generalizedTimeNormalize},
+	{Name: "caseExactIA5Match", OID: "1.3.6.1.4.1.1466.109.114.1", Kind: EqualityRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.26", normalize: caseExactNormalize},
+	{Name: "caseIgnoreIA5Match", OID: "1.3.6.1.4.1.1466.109.114.2", Kind: EqualityRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.26", normalize: caseIgnoreNormalize},
+	{Name: "caseIgnoreIA5SubstringsMatch", OID: "1.3.6.1.4.1.1466.109.114.3", Kind: SubstringsRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.58", normalize: lowerNormalize},
+	{Name: "integerBitAndMatch", OID: "1.2.840.113556.1.4.803", Kind: EqualityRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.27", match: bitAndMatch},
+	{Name: "integerBitOrMatch", OID: "1.2.840.113556.1.4.804", Kind: EqualityRule, Syntax: "1.3.6.1.4.1.1466.115.121.1.27", match: bitOrMatch},
+}
+
+// prepareString removes leading and trailing spaces and collapses runs of spaces so that
+// they are not significant.
+func prepareString(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// caseIgnoreNormalize ignores case and insignificant spaces.
+func caseIgnoreNormalize(value string) (string, bool) {
+	return strings.ToLower(prepareString(value)), true
+}
+
+// caseExactNormalize ignores insignificant spaces.
+func caseExactNormalize(value string) (string, bool) {
+	return prepareString(value), true
+}
+
+// lowerNormalize ignores case. Substrings are only lower-cased, as collapsing spaces would
+// change the meaning of substrings which start or end with one.
+func lowerNormalize(value string) (string, bool) {
+	return strings.ToLower(value), true
+}
+
+// removeSpaces ignores all spaces, which are not significant in numeric strings.
+func removeSpaces(value string) (string, bool) {
+	return strings.ReplaceAll(value, " ", ""), true
+}
+
+// numericStringNormalize ignores spaces and rejects values which contain anything but digits.
+func numericStringNormalize(value string) (string, bool) {
+	value, _ = removeSpaces(value)
+	for i := 0; i < len(value); i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return "", false
+		}
+	}
+	return value, true
+}
+
+// integerNormalize reduces an integer of any size to its canonical decimal form.
+func integerNormalize(value string) (string, bool) {
+	i, ok := new(big.Int).SetString(strings.TrimSpace(value), 10)
+	if !ok {
+		return "", false
+	}
+	return i.String(), true
+}
+
+// compareIntegers orders two canonical integers by their value.
+func compareIntegers(a, b string) int {
+	x, _ := new(big.Int).SetString(a, 10)
+	y, _ := new(big.Int).SetString(b, 10)
+	if x == nil || y == nil {
+		return strings.Compare(a, b)
+	}
+	return x.Cmp(y)
+}
+
+// booleanNormalize accepts TRUE or FALSE in any case.
+func booleanNormalize(value string) (string, bool) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	return value, value == "TRUE" || value == "FALSE"
+}
+
+// dnNormalize reduces a distinguished name to its normal form.
+func dnNormalize(value string) (string, bool) {
+	normal, err := dn.Normalize(value)
+	return normal, err == nil
+}
+
+// telephoneNumberNormalize ignores case, spaces and hyphens.
+func telephoneNumberNormalize(value string) (string, bool) {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(value)), true
+}
+
+// generalizedTimeLayouts are the forms of generalized time which are accepted, most precise first.
+var generalizedTimeLayouts = []string{
+	"20060102150405.999999999Z0700",
+	"20060102150405Z0700",
+	"200601021504Z0700",
+	"2006010215Z0700",
+}
+
+// generalizedTimeNormalize reduces a generalized time (e.g. 20200102150405Z) to a UTC form
+// which sorts chronologically.
+func generalizedTimeNormalize(value string) (string, bool) {
+	value = strings.Replace(strings.TrimSpace(value), ",", ".", 1)
+	for _, layout := range generalizedTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC().Format("20060102150405.000000000Z"), true
+		}
+	}
+	return "", false
+}
+
+// bitAndMatch matches integer values which have all of the bits of the assertion set.
+func bitAndMatch(value, assertion string) bool {
+	x, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return false
+	}
+	y, err := strconv.ParseInt(strings.TrimSpace(assertion), 10, 64)
+	return err == nil && x&y == y
+}
+
+// bitOrMatch matches integer values which have any of the bits of the assertion set.
+func bitOrMatch(value, assertion string) bool {
+	x, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return false
+	}
+	y, err := strconv.ParseInt(strings.TrimSpace(assertion), 10, 64)
+	return err == nil && x&y != 0
+}
@@ -0,0 +1,77 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestMatchingRuleEqual(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	tests := []struct {
+		rule      string
+		value     string
+		assertion string
+		matches   bool
+	}{
+		{"caseIgnoreMatch", "  John   Smith ", "john smith", true},
+		{"caseExactMatch", "John  Smith", "John Smith", true},
+		{"caseExactMatch", "John Smith", "john smith", false},
+		{"integerMatch", "01003", "1003", true},
+		{"integerMatch", "1003", "abc", false},
+		{"numericStringMatch", "123 456", "123456", true},
+		{"distinguishedNameMatch", "CN=Person1, DC=test,DC=lab", "cn=person1,dc=test,dc=lab", true},
+		{"distinguishedNameMatch", "cn=person1,dc=test,dc=lab", "cn=person2,dc=test,dc=lab", false},
+		{"distinguishedNameMatch", "not a dn", "not a dn", false},
+		{"generalizedTimeMatch", "20200102150405Z", "20200102160405+0100", true},
+		{"generalizedTimeMatch", "20200102150405Z", "yesterday", false},
+		{"octetStringMatch", "Secret", "secret", false},
+		{"octetStringMatch", "Secret", "Secret", true},
+		{"booleanMatch", "true", "TRUE", true},
+		{"telephoneNumberMatch", "+44 1234-567890", "+441234567890", true},
+		{"2.5.13.15", "10", "9", false},
+		{"2.5.13.15", "9", "10", true},
+		{"integerBitAndMatch", "7", "5", true},
+		{"integerBitOrMatch", "8", "5", false},
+	}
+
+	for _, test := range tests {
+		rule := LookupMatchingRule(test.rule)
+		Ω(rule).ShouldNot(gomega.BeNil(), test.rule)
+		Ω(rule.Equal(test.value, test.assertion)).Should(gomega.Equal(test.matches), test.rule+" "+test.value+" "+test.assertion)
+	}
+}
+
+func TestMatchingRuleCompare(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	tests := []struct {
+		rule  string
+		a     string
+		b     string
+		order int
+		valid bool
+	}{
+		{"integerOrderingMatch", "20", "1000", -1, true},
+		{"integerOrderingMatch", "-5", "-50", 1, true},
+		{"integerOrderingMatch", "007", "7", 0, true},
+		{"integerOrderingMatch", "a", "7", 0, false},
+		{"caseIgnoreOrderingMatch", "alice", "Bob", -1, true},
+		{"caseExactOrderingMatch", "alice", "Bob", 1, true},
+		{"numericStringOrderingMatch", "9", "1 0", -1, true},
+		{"generalizedTimeOrderingMatch", "20200102150405Z", "20200102150000-0100", -1, true},
+		{"generalizedTimeOrderingMatch", "20200102150405.5Z", "20200102150405Z", 1, true},
+		{"caseIgnoreMatch", "a", "b", 0, false},
+	}
+
+	for _, test := range tests {
+		order, valid := LookupMatchingRule(test.rule).Compare(test.a, test.b)
+		Ω(valid).Should(gomega.Equal(test.valid), test.rule+" "+test.a+" "+test.b)
+		Ω(order).Should(gomega.Equal(test.order), test.rule+" "+test.a+" "+test.b)
+	}
+}
@@ -0,0 +1,201 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// AttributeType describes how the values of an attribute are compared. The rules are named by
+// the names or OIDs of registered matching rules, and are left empty if the attribute has no
+// rule of that kind.
+type AttributeType struct {
+	OID        string   // The numeric OID of the attribute type
+	Names      []string // The names of the attribute type, e.g. cn and commonName
+	Equality   string   // The equality matching rule
+	Ordering   string   // The ordering matching rule
+	Substrings string   // The substrings matching rule
+}
+
+// registry holds the matching rules and attribute types known to the query package. Both are
+// keyed by their lower-cased names and OIDs.
+type registry struct {
+	lock       sync.RWMutex
+	rules      map[string]*MatchingRule
+	attributes map[string]*AttributeType
+}
+
+// schema is the registry used when evaluating conditions.
+var schema = newRegistry()
+
+// newRegistry creates a registry holding the built-in matching rules and attribute types.
+func newRegistry() *registry {
+	r := &registry{rules: make(map[string]*MatchingRule), attributes: make(map[string]*AttributeType)}
+	for i := range builtinMatchingRules {
+		rule := &builtinMatchingRules[i]
+		r.rules[strings.ToLower(rule.Name)] = rule
+		r.rules[rule.OID] = rule
+	}
+	for _, t := range builtinAttributeTypes {
+		if err := r.register(t); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// builtinAttributeTypes are the attribute types whose values aren't compared ignoring case,
+// from RFC 4512, RFC 4519, RFC 2307 and RFC 2798.
+var builtinAttributeTypes = []AttributeType{
+	{OID: "2.5.4.0", Names: []string{"objectClass"}, Equality: "objectIdentifierMatch"},
+	{OID: "2.5.4.35", Names: []string{"userPassword"}, Equality: "octetStringMatch", Substrings: "octetStringSubstringsMatch"},
+	{OID: "2.5.4.20", Names: []string{"telephoneNumber"}, Equality: "telephoneNumberMatch", Substrings: "telephoneNumberSubstringsMatch"},
+	{OID: "0.9.2342.19200300.100.1.41", Names: []string{"mobile", "mobileTelephoneNumber"}, Equality: "telephoneNumberMatch", Substrings: "telephoneNumberSubstringsMatch"},
+	{OID: "0.9.2342.19200300.100.1.3", Names: []string{"mail", "rfc822Mailbox"}, Equality: "caseIgnoreIA5Match", Substrings: "caseIgnoreIA5SubstringsMatch"},
+	{OID: "0.9.2342.19200300.100.1.25", Names: []string{"dc", "domainComponent"}, Equality: "caseIgnoreIA5Match", Substrings: "caseIgnoreIA5SubstringsMatch"},
+	{OID: "2.5.4.31", Names: []string{"member"}, Equality: "distinguishedNameMatch"},
+	{OID: "2.5.4.50", Names: []string{"uniqueMember"}, Equality: "uniqueMemberMatch"},
+	{OID: "2.5.4.32", Names: []string{"owner"}, Equality: "distinguishedNameMatch"},
+	{OID: "2.5.4.34", Names: []string{"seeAlso"}, Equality: "distinguishedNameMatch"},
+	{OID: "0.9.2342.19200300.100.1.10", Names: []string{"manager"}, Equality: "distinguishedNameMatch"},
+	{OID: "2.5.4.49", Names: []string{"distinguishedName"}, Equality: "distinguishedNameMatch"},
+	{OID: "1.2.840.113556.1.2.102", Names: []string{"memberOf"}, Equality: "distinguishedNameMatch"},
+	{OID: "2.5.18.3", Names: []string{"creatorsName"}, Equality: "distinguishedNameMatch"},
+	{OID: "2.5.18.4", Names: []string{"modifiersName"}, Equality: "distinguishedNameMatch"},
+	{OID: "2.5.18.1", Names: []string{"createTimestamp"}, Equality: "generalizedTimeMatch", Ordering: "generalizedTimeOrderingMatch"},
+	{OID: "2.5.18.2", Names: []string{"modifyTimestamp"}, Equality: "generalizedTimeMatch", Ordering: "generalizedTimeOrderingMatch"},
+	{OID: "1.3.6.1.1.1.1.0", Names: []string{"uidNumber"}, Equality: "integerMatch", Ordering: "integerOrderingMatch"},
+	{OID: "1.3.6.1.1.1.1.1", Names: []string{"gidNumber"}, Equality: "integerMatch", Ordering: "integerOrderingMatch"},
+	{OID: "1.3.6.1.1.1.1.3", Names: []string{"homeDirectory"}, Equality: "caseExactIA5Match"},
+	{OID: "1.3.6.1.1.1.1.4", Names: []string{"loginShell"}, Equality: "caseExactIA5Match"},
+	{OID: "1.3.6.1.1.1.1.12", Names: []string{"memberUid"}, Equality: "caseExactIA5Match", Substrings: "caseIgnoreIA5SubstringsMatch"},
+	{OID: "2.16.840.1.113730.3.1.3", Names: []string{"employeeNumber"}, Equality: "caseIgnoreMatch", Substrings: "caseIgnoreSubstringsMatch"},
+}
+
+// register adds an attribute type to the registry, replacing any type with the same name or OID.
+func (r *registry) register(t AttributeType) error {
+	if len(t.Names) == 0 && t.OID == "" {
+		return fmt.Errorf("attribute type has no name or OID")
+	}
+	checks := []struct {
+		name string
+		kind RuleKind
+	}{{t.Equality, EqualityRule}, {t.Ordering, OrderingRule}, {t.Substrings, SubstringsRule}}
+	for _, check := range checks {
+		if check.name == "" {
+			continue
+		}
+		rule, ok := r.rules[strings.ToLower(check.name)]
+		if !ok {
+			return fmt.Errorf("attribute type %s uses unknown matching rule '%s'", t.name(), check.name)
+		}
+		if rule.Kind != check.kind {
+			return fmt.Errorf("attribute type %s uses '%s' for the wrong kind of matching", t.name(), check.name)
+		}
+	}
+
+	if t.OID != "" {
+		r.attributes[t.OID] = &t
+	}
+	for _, name := range t.Names {
+		r.attributes[strings.ToLower(name)] = &t
+	}
+	return nil
+}
+
+// name returns the name used for the attribute type in error messages.
+func (t *AttributeType) name() string {
+	if len(t.Names) > 0 {
+		return t.Names[0]
+	}
+	return t.OID
+}
+
+// RegisterAttributeType adds an attribute type to the registry used when evaluating conditions,
+// replacing any type with the same name or OID. The type's matching rules must be registered.
+func RegisterAttributeType(t AttributeType) error {
+	schema.lock.Lock()
+	defer schema.lock.Unlock()
+	return schema.register(t)
+}
+
+// LookupAttributeType returns the registered attribute type for an attribute description. Any
+// options in the description, e.g. ;lang-en, are ignored.
+func LookupAttributeType(attribute string) (AttributeType, bool) {
+	if i := strings.IndexByte(attribute, ';'); i >= 0 {
+		attribute = attribute[:i]
+	}
+	schema.lock.RLock()
+	defer schema.lock.RUnlock()
+	t, ok := schema.attributes[strings.ToLower(attribute)]
+	if !ok {
+		return AttributeType{}, false
+	}
+	return *t, true
+}
+
+// LookupMatchingRule returns the matching rule with the given name or OID, or nil if there
+// isn't one.
+func LookupMatchingRule(name string) *MatchingRule {
+	schema.lock.RLock()
+	defer schema.lock.RUnlock()
+	return schema.rules[strings.ToLower(name)]
+}
+
+// MatchingRules returns every registered matching rule, in the order of their OIDs.
+func MatchingRules() []*MatchingRule {
+	schema.lock.RLock()
+	defer schema.lock.RUnlock()
+	rules := make([]*MatchingRule, 0)
+	for key, rule := range schema.rules {
+		if key == rule.OID {
+			rules = append(rules, rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return compareOIDs(rules[i].OID, rules[j].OID) < 0 })
+	return rules
+}
+
+// compareOIDs orders two numeric OIDs arc by arc.
+func compareOIDs(a, b string) int {
+	x, y := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(x) && i < len(y); i++ {
+		if len(x[i]) != len(y[i]) {
+			return len(x[i]) - len(y[i])
+		}
+		if c := strings.Compare(x[i], y[i]); c != 0 {
+			return c
+		}
+	}
+	return len(x) - len(y)
+}
+
+// EqualityMatch returns the equality matching rule for an attribute. Attributes which aren't
+// registered, or which have no equality rule, are compared using caseIgnoreMatch.
+func EqualityMatch(attribute string) *MatchingRule {
+	t, _ := LookupAttributeType(attribute)
+	return attributeRule(t.Equality, "caseIgnoreMatch")
+}
+
+// OrderingMatch returns the ordering matching rule for an attribute. Attributes which aren't
+// registered, or which have no ordering rule, are compared using caseIgnoreOrderingMatch.
+func OrderingMatch(attribute string) *MatchingRule {
+	t, _ := LookupAttributeType(attribute)
+	return attributeRule(t.Ordering, "caseIgnoreOrderingMatch")
+}
+
+// SubstringsMatch returns the substrings matching rule for an attribute. Attributes which aren't
+// registered, or which have no substrings rule, are compared using caseIgnoreSubstringsMatch.
+func SubstringsMatch(attribute string) *MatchingRule {
+	t, _ := LookupAttributeType(attribute)
+	return attributeRule(t.Substrings, "caseIgnoreSubstringsMatch")
+}
+
+// attributeRule returns the named rule, or the fallback if no rule is named.
+func attributeRule(name string, fallback string) *MatchingRule {
+	if rule := LookupMatchingRule(name); rule != nil {
+		return rule
+	}
+	return LookupMatchingRule(fallback)
+}
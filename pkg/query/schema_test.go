@@ -0,0 +1,111 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+)
+
+var account = ldap.Entry{DN: "uid=jsmith,ou=people,dc=test,dc=lab",
+	Attributes: []*ldap.EntryAttribute{
+		{Name: "uid", Values: []string{"jsmith"}},
+		{Name: "uidNumber", Values: []string{"1000"}},
+		{Name: "homeDirectory", Values: []string{"/home/JSmith"}},
+		{Name: "manager", Values: []string{"uid=Boss, ou=People, dc=test, dc=lab"}},
+		{Name: "userPassword", Values: []string{"{SSHA}I8wq1+4gyJVJUtQW96JGcmCL46ADyPnW"}},
+		{Name: "createTimestamp", Values: []string{"20200102150405Z"}},
+	},
+}
+
+func TestAttributeTypeLookup(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	uidNumber, ok := LookupAttributeType("UIDNUMBER")
+	Ω(ok).Should(gomega.Equal(true))
+	Ω(uidNumber.Equality).Should(gomega.Equal("integerMatch"))
+
+	_, ok = LookupAttributeType("1.3.6.1.1.1.1.0")
+	Ω(ok).Should(gomega.Equal(true))
+	_, ok = LookupAttributeType("mail;x-work")
+	Ω(ok).Should(gomega.Equal(true))
+	_, ok = LookupAttributeType("madeUp")
+	Ω(ok).Should(gomega.Equal(false))
+
+	// attributes without rules fall back to ignoring case.
+	Ω(EqualityMatch("madeUp").Name).Should(gomega.Equal("caseIgnoreMatch"))
+	Ω(OrderingMatch("homeDirectory").Name).Should(gomega.Equal("caseIgnoreOrderingMatch"))
+	Ω(SubstringsMatch("uidNumber").Name).Should(gomega.Equal("caseIgnoreSubstringsMatch"))
+	Ω(OrderingMatch("gidNumber").Name).Should(gomega.Equal("integerOrderingMatch"))
+}
+
+func TestMatchingRules(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	rules := MatchingRules()
+	Ω(rules).Should(gomega.HaveLen(len(builtinMatchingRules)))
+	Ω(rules[0].Name).Should(gomega.Equal("integerBitAndMatch"))
+	Ω(rules[len(rules)-1].Name).Should(gomega.Equal("generalizedTimeOrderingMatch"))
+	for _, rule := range rules {
+		Ω(rule.Syntax).ShouldNot(gomega.BeEmpty(), rule.Name)
+	}
+
+	// OIDs are ordered arc by arc, not as strings.
+	Ω(compareOIDs("2.5.13.9", "2.5.13.10")).Should(gomega.BeNumerically("<", 0))
+	Ω(compareOIDs("2.5.13", "2.5.13.0")).Should(gomega.BeNumerically("<", 0))
+	Ω(compareOIDs("2.5.13.0", "2.5.13.0")).Should(gomega.Equal(0))
+}
+
+func TestRegisterAttributeType(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	Ω(RegisterAttributeType(AttributeType{Names: []string{"x-test-unknown"}, Equality: "madeUpMatch"})).ShouldNot(gomega.Succeed())
+	Ω(RegisterAttributeType(AttributeType{Names: []string{"x-test-kind"}, Ordering: "caseExactMatch"})).ShouldNot(gomega.Succeed())
+	Ω(RegisterAttributeType(AttributeType{})).ShouldNot(gomega.Succeed())
+
+	Ω(RegisterAttributeType(AttributeType{OID: "1.2.3.4", Names: []string{"x-test-code", "x-test-alias"}, Equality: "caseExactMatch", Substrings: "2.5.13.7"})).Should(gomega.Succeed())
+	entry := &ldap.Entry{Attributes: []*ldap.EntryAttribute{{Name: "x-test-alias", Values: []string{"ABC"}}}}
+	Ω(NewEquals("x-test-code", "ABC").Evaluate(&ldap.Entry{Attributes: []*ldap.EntryAttribute{{Name: "x-test-code", Values: []string{"ABC"}}}})).Should(gomega.Equal(true))
+	Ω(EqualityMatch("x-test-alias").Name).Should(gomega.Equal("caseExactMatch"))
+	Ω(NewEquals("x-test-alias", "abc").Evaluate(entry)).Should(gomega.Equal(false))
+	Ω(NewSubstrings("x-test-alias", "a", nil, "").Evaluate(entry)).Should(gomega.Equal(false))
+	Ω(NewSubstrings("x-test-alias", "A", nil, "").Evaluate(entry)).Should(gomega.Equal(true))
+}
+
+func TestSchemaAwareEvaluation(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	tests := map[string]bool{
+		"(uid=JSMITH)":                                  true,
+		"(uidNumber=01000)":                             true,
+		"(uidNumber=1e3)":                               false,
+		"(uidNumber>=999)":                              true,
+		"(uidNumber<=999)":                              false,
+		"(uidNumber=10*)":                               true,
+		"(homeDirectory=/home/JSmith)":                  true,
+		"(homeDirectory=/home/jsmith)":                  false,
+		"(manager=uid=boss,ou=people,dc=test,dc=lab)":   true,
+		"(manager=uid=boss,ou=people,dc=test)":          false,
+		"(userPassword={ssha}*)":                        false,
+		"(userPassword={SSHA}*)":                        true,
+		"(createTimestamp>=20200102150405+0100)":        true,
+		"(createTimestamp<=202001021504Z)":              false,
+		"(createTimestamp=20200102160405+0100)":         true,
+		"(homeDirectory:caseIgnoreMatch:=/home/jsmith)": true,
+		"(uidNumber:=1000.0)":                           false,
+	}
+
+	for filter, matches := range tests {
+		cond, _, err := Parse(filter, 0)
+		Ω(err).Should(gomega.BeNil(), filter)
+		Ω(cond.Evaluate(&account)).Should(gomega.Equal(matches), filter)
+	}
+}
@@ -0,0 +1,45 @@
+package query
+
+import (
+	"github.com/nmcclain/ldap"
+)
+
+// Substrings conditions match attribute values which start with the initial part, contain
+// each of the any parts in order and end with the final part, e.g. (cn=jo*n*s). Values are
+// compared using the attribute's substrings matching rule.
+type Substrings struct {
+	Attribute string            // The attribute whose values are matched
+	Initial   string            // The value must start with this, if it isn't empty
+	Any       []string          // The value must contain these in order after the initial part
+	Final     string            // The value must end with this, if it isn't empty
+	matcher   *substringMatcher // The compiled parts, nil if the condition wasn't created by NewSubstrings
+}
+
+// NewSubstrings creates a Substrings condition with its parts compiled ready for evaluation.
+func NewSubstrings(attribute, initial string, any []string, final string) *Substrings {
+	return &Substrings{Attribute: attribute, Initial: initial, Any: any, Final: final, matcher: compileSubstrings(attribute, initial, any, final)}
+}
+
+// Evaluate evaluates the query against the specified ldap entry.
+func (s *Substrings) Evaluate(entry *ldap.Entry) bool {
+	return s.Test(entry) == True
+}
+
+// Test evaluates the query against the entry. It is undefined if a part isn't valid for the
+// attribute's substrings matching rule.
+func (s *Substrings) Test(entry *ldap.Entry) Result {
+	m := s.matcher
+	if m == nil {
+		m = compileSubstrings(s.Attribute, s.Initial, s.Any, s.Final)
+	}
+	return m.test(entry, s.Attribute)
+}
+
+// ToString produces a string version of this query condition
+func (s *Substrings) ToString() string {
+	out := "(" + s.Attribute + "=" + escapeValue(s.Initial) + "*"
+	for _, part := range s.Any {
+		out = out + escapeValue(part) + "*"
+	}
+	return out + escapeValue(s.Final) + ")"
+}
@@ -0,0 +1,43 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestSubstringsEvaluation(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	tests := []struct {
+		substrings Substrings
+		matches    bool
+	}{
+		{Substrings{Attribute: "cn", Initial: "person"}, true},
+		{Substrings{Attribute: "cn", Initial: "PERSON ("}, true},
+		{Substrings{Attribute: "cn", Final: "(three)"}, true},
+		{Substrings{Attribute: "cn", Any: []string{"son", "thr"}}, true},
+		{Substrings{Attribute: "cn", Any: []string{"thr", "son"}}, false},
+		{Substrings{Attribute: "cn", Initial: "person (t", Final: "three)"}, false},
+		{Substrings{Attribute: "cn", Initial: "per", Any: []string{"son"}, Final: "e)"}, true},
+		{Substrings{Attribute: "description", Any: []string{"*stars*"}}, true},
+		{Substrings{Attribute: "description", Initial: "likes.", Final: ""}, false},
+		{Substrings{Attribute: "sn", Initial: "p"}, false},
+	}
+
+	for _, test := range tests {
+		Ω(test.substrings.Evaluate(&person3)).Should(gomega.Equal(test.matches), test.substrings.ToString())
+	}
+}
+
+func TestSubstringsToString(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	substrings := Substrings{Attribute: "cn", Initial: "a(", Any: []string{"b*"}, Final: "c\\"}
+
+	Ω(substrings.ToString()).Should(gomega.Equal("(cn=a\\28*b\\2a*c\\5c)"))
+}
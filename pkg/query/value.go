@@ -0,0 +1,93 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shauncampbell/dapper/pkg/query/errors"
+)
+
+// isAttributeChar returns true if the character may appear in an attribute description,
+// which is either a name or a numeric OID optionally followed by ";option"s.
+func isAttributeChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '.' || c == ';'
+}
+
+// isRuleChar returns true if the character may appear in a matching rule name or OID.
+func isRuleChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '.'
+}
+
+// fromHex returns the value of a hexadecimal digit, or -1 if the character is not one.
+func fromHex(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'f':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return -1
+}
+
+// parseValue reads an assertion value starting at the offset, decoding \XX escapes, up to the
+// closing bracket of the item. The value is returned split on its unescaped '*' characters
+// along with the offset of the closing bracket.
+func parseValue(expression string, offset int, conditionType string) ([]string, int, error) {
+	parts := make([]string, 0, 1)
+	var part strings.Builder
+	for offset < len(expression) {
+		switch c := expression[offset]; c {
+		case ')':
+			return append(parts, part.String()), offset, nil
+		case '(':
+			return nil, -1, errors.InvalidExpressionAt(conditionType, offset, "'(' must be escaped as \\28")
+		case '*':
+			parts = append(parts, part.String())
+			part.Reset()
+			offset++
+		case '\\':
+			if offset+2 >= len(expression) || fromHex(expression[offset+1]) < 0 || fromHex(expression[offset+2]) < 0 {
+				return nil, -1, errors.InvalidExpressionAt(conditionType, offset, "invalid escape sequence")
+			}
+			part.WriteByte(byte(fromHex(expression[offset+1])<<4 | fromHex(expression[offset+2])))
+			offset += 3
+		default:
+			part.WriteByte(c)
+			offset++
+		}
+	}
+	return nil, -1, errors.InvalidExpressionAt(conditionType, offset, "missing ')'")
+}
+
+// unescapeValue decodes the \XX escapes in a value taken from a filter string.
+func unescapeValue(value string) (string, error) {
+	var out strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			out.WriteByte(value[i])
+			continue
+		}
+		if i+2 >= len(value) || fromHex(value[i+1]) < 0 || fromHex(value[i+2]) < 0 {
+			return "", errors.InvalidExpressionAt("equals", i, "invalid escape sequence")
+		}
+		out.WriteByte(byte(fromHex(value[i+1])<<4 | fromHex(value[i+2])))
+		i += 2
+	}
+	return out.String(), nil
+}
+
+// escapeValue encodes a value for use in a filter string (RFC 4515 section 3).
+func escapeValue(value string) string {
+	var out strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '(', ')', '*', '\\', 0:
+			fmt.Fprintf(&out, "\\%02x", c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,136 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// description is a parsed RFC 4512 schema description such as an attribute type or object
+// class description. The fields are keyed by their upper-cased keyword. Flags, such as
+// SINGLE-VALUE, have no values.
+type description struct {
+	oid    string
+	fields map[string][]string
+}
+
+// has returns true if the description contains the keyword.
+func (d *description) has(keyword string) bool {
+	_, ok := d.fields[keyword]
+	return ok
+}
+
+// value returns the first value of the keyword, or an empty string if there isn't one.
+func (d *description) value(keyword string) string {
+	if values := d.fields[keyword]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// parseDescription parses a schema description of the form
+//
+//	( numericoid NAME 'name' DESC 'description' SUP ( a $ b ) SINGLE-VALUE ... )
+//
+// Lists may be written either as a single value or as a bracketed list separated by '$'
+// (or spaces for lists of names). Keywords which aren't known to take a value are flags.
+func parseDescription(text string, valued map[string]bool) (*description, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) < 3 || tokens[0] != "(" || tokens[len(tokens)-1] != ")" {
+		return nil, fmt.Errorf("definition must be enclosed in brackets: %s", text)
+	}
+	tokens = tokens[1 : len(tokens)-1]
+
+	d := &description{oid: tokens[0], fields: make(map[string][]string)}
+	if !isNumericOID(d.oid) {
+		return nil, fmt.Errorf("definition must start with a numeric OID: %s", text)
+	}
+
+	for i := 1; i < len(tokens); {
+		keyword := strings.ToUpper(tokens[i])
+		i++
+		if _, ok := d.fields[keyword]; ok {
+			return nil, fmt.Errorf("'%s' appears more than once in: %s", keyword, text)
+		}
+		if !valued[keyword] && !strings.HasPrefix(keyword, "X-") {
+			d.fields[keyword] = nil
+			continue
+		}
+		if i >= len(tokens) {
+			return nil, fmt.Errorf("'%s' is missing its value in: %s", keyword, text)
+		}
+
+		if tokens[i] != "(" {
+			d.fields[keyword] = []string{unquote(tokens[i])}
+			i++
+			continue
+		}
+
+		values := make([]string, 0)
+		for i++; i < len(tokens) && tokens[i] != ")"; i++ {
+			if tokens[i] != "$" {
+				values = append(values, unquote(tokens[i]))
+			}
+		}
+		if i >= len(tokens) {
+			return nil, fmt.Errorf("'%s' has an unterminated list in: %s", keyword, text)
+		}
+		d.fields[keyword] = values
+		i++
+	}
+	return d, nil
+}
+
+// tokenize splits a description into brackets, dollar signs, quoted strings (which keep their
+// quotes) and words.
+func tokenize(text string) ([]string, error) {
+	tokens := make([]string, 0)
+	for i := 0; i < len(text); {
+		switch c := text[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == '$':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '\'':
+			end := strings.IndexByte(text[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted string in: %s", text)
+			}
+			tokens = append(tokens, text[i:i+end+2])
+			i += end + 2
+		default:
+			start := i
+			for i < len(text) && !strings.ContainsRune(" \t\n\r()$'", rune(text[i])) {
+				i++
+			}
+			tokens = append(tokens, text[start:i])
+		}
+	}
+	return tokens, nil
+}
+
+// unquote removes the quotes from a quoted string and decodes the \27 and \5C escapes which
+// RFC 4512 allows within them. Other tokens are returned unchanged.
+func unquote(token string) string {
+	if len(token) < 2 || token[0] != '\'' {
+		return token
+	}
+	token = token[1 : len(token)-1]
+	return strings.NewReplacer("\\27", "'", "\\5C", "\\", "\\5c", "\\").Replace(token)
+}
+
+// isNumericOID returns true if the value is a dotted decimal OID such as 2.5.4.3.
+func isNumericOID(value string) bool {
+	if value == "" || value[0] == '.' || value[len(value)-1] == '.' || strings.Contains(value, "..") {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if (value[i] < '0' || value[i] > '9') && value[i] != '.' {
+			return false
+		}
+	}
+	return true
+}
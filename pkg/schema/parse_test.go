@@ -0,0 +1,55 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestParseDescription(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	d, err := parseDescription("( 2.5.6.6 NAME 'person' DESC 'a \\27person\\27' SUP top STRUCTURAL MUST ( sn $ cn ) MAY userPassword X-ORIGIN ( 'RFC 4519' 'core' ) )", objectClassKeywords)
+	Ω(err).Should(gomega.BeNil())
+	Ω(d.oid).Should(gomega.Equal("2.5.6.6"))
+	Ω(d.fields["NAME"]).Should(gomega.Equal([]string{"person"}))
+	Ω(d.value("DESC")).Should(gomega.Equal("a 'person'"))
+	Ω(d.fields["MUST"]).Should(gomega.Equal([]string{"sn", "cn"}))
+	Ω(d.fields["MAY"]).Should(gomega.Equal([]string{"userPassword"}))
+	Ω(d.fields["X-ORIGIN"]).Should(gomega.Equal([]string{"RFC 4519", "core"}))
+	Ω(d.has("STRUCTURAL")).Should(gomega.Equal(true))
+	Ω(d.has("ABSTRACT")).Should(gomega.Equal(false))
+
+	d, err = parseDescription("(2.5.4.3 NAME ('cn' 'commonName') SUP name)", attributeTypeKeywords)
+	Ω(err).Should(gomega.BeNil())
+	Ω(d.fields["NAME"]).Should(gomega.Equal([]string{"cn", "commonName"}))
+	Ω(d.value("SUP")).Should(gomega.Equal("name"))
+
+	d, err = parseDescription("( 2.5.4.13 NAME 'description' DESC 'a \\27quoted\\27 \\5c value' SYNTAX 1.3.6.1.4.1.1466.115.121.1.15{1024} )", attributeTypeKeywords)
+	Ω(err).Should(gomega.BeNil())
+	Ω(d.value("DESC")).Should(gomega.Equal("a 'quoted' \\ value"))
+	Ω(d.value("SYNTAX")).Should(gomega.Equal("1.3.6.1.4.1.1466.115.121.1.15{1024}"))
+}
+
+func TestParseDescriptionErrors(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	invalid := []string{
+		"",
+		"2.5.4.3 NAME 'cn'",
+		"( NAME 'cn' )",
+		"( 2.5..4 NAME 'cn' )",
+		"( 2.5.4.3 NAME 'cn )",
+		"( 2.5.4.3 NAME )",
+		"( 2.5.4.3 NAME ( 'cn' 'commonName' )",
+		"( 2.5.4.3 NAME 'cn' NAME 'commonName' )",
+	}
+	for _, text := range invalid {
+		_, err := parseDescription(text, attributeTypeKeywords)
+		Ω(err).ShouldNot(gomega.BeNil(), text)
+	}
+}
@@ -0,0 +1,306 @@
+// Package schema holds the LDAP schema known to the server: the attribute types and object
+// classes which describe what entries may contain (RFC 4512 section 4).
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shauncampbell/dapper/pkg/query"
+)
+
+// Usage says whether an attribute type holds user data or is maintained by the server.
+type Usage int
+
+const (
+	UserApplications     Usage = iota // The attribute holds user data
+	DirectoryOperation                // The attribute is maintained by the directory
+	DistributedOperation              // The attribute is shared between servers
+	DSAOperation                      // The attribute is specific to this server
+)
+
+// usages maps the USAGE keywords onto their Usage.
+var usages = map[string]Usage{
+	"userapplications":     UserApplications,
+	"directoryoperation":   DirectoryOperation,
+	"distributedoperation": DistributedOperation,
+	"dsaoperation":         DSAOperation,
+}
+
+// Kind says what role an object class plays in an entry.
+type Kind int
+
+const (
+	Structural Kind = iota // The class describes what the entry is, each entry needs one
+	Abstract               // The class only exists to be inherited from
+	Auxiliary              // The class adds attributes to an entry of any structural class
+)
+
+// AttributeType is an attribute type definition (RFC 4512 section 4.1.2). Matching rules and the
+// syntax are inherited from the supertype when they aren't given.
+type AttributeType struct {
+	OID                string   // The numeric OID of the attribute type
+	Names              []string // The names of the attribute type, e.g. cn and commonName
+	Description        string   // A description of the attribute type
+	Obsolete           bool     // The attribute type should no longer be used
+	Sup                string   // The name of the supertype, if any
+	Equality           string   // The equality matching rule
+	Ordering           string   // The ordering matching rule
+	Substrings         string   // The substrings matching rule
+	Syntax             string   // The OID of the syntax, optionally followed by a length bound, e.g. {256}
+	SingleValue        bool     // Entries may only hold one value
+	Collective         bool     // The attribute is a collective attribute
+	NoUserModification bool     // Clients may not change the attribute
+	Usage              Usage    // Whether the attribute holds user data or is operational
+}
+
+// Name returns the primary name of the attribute type, or its OID if it has no name.
+func (t *AttributeType) Name() string {
+	if len(t.Names) > 0 {
+		return t.Names[0]
+	}
+	return t.OID
+}
+
+// ObjectClass is an object class definition (RFC 4512 section 4.1.1).
+type ObjectClass struct {
+	OID         string   // The numeric OID of the object class
+	Names       []string // The names of the object class
+	Description string   // A description of the object class
+	Obsolete    bool     // The object class should no longer be used
+	Sup         []string // The names of the superclasses
+	Kind        Kind     // Whether the class is structural, abstract or auxiliary
+	Must        []string // The attributes an entry of this class must have
+	May         []string // The attributes an entry of this class may have
+}
+
+// Name returns the primary name of the object class, or its OID if it has no name.
+func (c *ObjectClass) Name() string {
+	if len(c.Names) > 0 {
+		return c.Names[0]
+	}
+	return c.OID
+}
+
+// Schema is a set of attribute types and object classes. Definitions must be added after any
+// definitions they refer to.
+type Schema struct {
+	attributes     map[string]*AttributeType // The attribute types keyed by lower-cased name and OID
+	classes        map[string]*ObjectClass   // The object classes keyed by lower-cased name and OID
+	attributeTypes []*AttributeType          // The attribute types in the order they were added
+	objectClasses  []*ObjectClass            // The object classes in the order they were added
+}
+
+// New creates an empty schema.
+func New() *Schema {
+	return &Schema{attributes: make(map[string]*AttributeType), classes: make(map[string]*ObjectClass)}
+}
+
+// definitions is a set of attribute type and object class descriptions, in the order they
+// must be added to a schema.
+type definitions struct {
+	attributeTypes []string
+	objectClasses  []string
+}
+
+// builtin are the definitions every server knows about.
+var builtin = []definitions{core, cosine, inetOrgPerson, nis}
+
+// Default creates a schema holding the built-in core, cosine, inetOrgPerson and nis definitions.
+func Default() *Schema {
+	s := New()
+	for _, set := range builtin {
+		for _, definition := range set.attributeTypes {
+			if err := s.AddAttributeType(definition); err != nil {
+				panic(err)
+			}
+		}
+		for _, definition := range set.objectClasses {
+			if err := s.AddObjectClass(definition); err != nil {
+				panic(err)
+			}
+		}
+	}
+	return s
+}
+
+// attributeTypeKeywords are the keywords of an attribute type description which take a value.
+var attributeTypeKeywords = map[string]bool{"NAME": true, "DESC": true, "SUP": true, "EQUALITY": true, "ORDERING": true, "SUBSTR": true, "SYNTAX": true, "USAGE": true}
+
+// objectClassKeywords are the keywords of an object class description which take a value.
+var objectClassKeywords = map[string]bool{"NAME": true, "DESC": true, "SUP": true, "MUST": true, "MAY": true}
+
+// AddAttributeType parses an attribute type description and adds it to the schema.
+func (s *Schema) AddAttributeType(definition string) error {
+	d, err := parseDescription(definition, attributeTypeKeywords)
+	if err != nil {
+		return err
+	}
+
+	t := &AttributeType{
+		OID:                d.oid,
+		Names:              d.fields["NAME"],
+		Description:        d.value("DESC"),
+		Obsolete:           d.has("OBSOLETE"),
+		Sup:                d.value("SUP"),
+		Equality:           d.value("EQUALITY"),
+		Ordering:           d.value("ORDERING"),
+		Substrings:         d.value("SUBSTR"),
+		Syntax:             d.value("SYNTAX"),
+		SingleValue:        d.has("SINGLE-VALUE"),
+		Collective:         d.has("COLLECTIVE"),
+		NoUserModification: d.has("NO-USER-MODIFICATION"),
+	}
+
+	if d.has("USAGE") {
+		usage, ok := usages[strings.ToLower(d.value("USAGE"))]
+		if !ok {
+			return fmt.Errorf("attribute type %s has an unknown usage '%s'", t.Name(), d.value("USAGE"))
+		}
+		t.Usage = usage
+	}
+
+	if t.Sup != "" {
+		sup, ok := s.AttributeType(t.Sup)
+		if !ok {
+			return fmt.Errorf("attribute type %s has an unknown supertype '%s'", t.Name(), t.Sup)
+		}
+		if t.Equality == "" {
+			t.Equality = sup.Equality
+		}
+		if t.Ordering == "" {
+			t.Ordering = sup.Ordering
+		}
+		if t.Substrings == "" {
+			t.Substrings = sup.Substrings
+		}
+		if t.Syntax == "" {
+			t.Syntax = sup.Syntax
+		}
+	}
+	if t.Syntax == "" {
+		return fmt.Errorf("attribute type %s has no syntax or supertype", t.Name())
+	}
+
+	if err := s.checkUnused(t.OID, t.Names, func(key string) bool { _, ok := s.attributes[key]; return ok }); err != nil {
+		return err
+	}
+	s.attributes[t.OID] = t
+	for _, name := range t.Names {
+		s.attributes[strings.ToLower(name)] = t
+	}
+	s.attributeTypes = append(s.attributeTypes, t)
+	return nil
+}
+
+// AddObjectClass parses an object class description and adds it to the schema. Its
+// superclasses and attributes must already be in the schema.
+func (s *Schema) AddObjectClass(definition string) error {
+	d, err := parseDescription(definition, objectClassKeywords)
+	if err != nil {
+		return err
+	}
+
+	c := &ObjectClass{
+		OID:         d.oid,
+		Names:       d.fields["NAME"],
+		Description: d.value("DESC"),
+		Obsolete:    d.has("OBSOLETE"),
+		Sup:         d.fields["SUP"],
+		Must:        d.fields["MUST"],
+		May:         d.fields["MAY"],
+	}
+
+	switch {
+	case d.has("ABSTRACT"):
+		c.Kind = Abstract
+	case d.has("AUXILIARY"):
+		c.Kind = Auxiliary
+	}
+
+	for _, sup := range c.Sup {
+		if _, ok := s.ObjectClass(sup); !ok {
+			return fmt.Errorf("object class %s has an unknown superclass '%s'", c.Name(), sup)
+		}
+	}
+	for _, name := range append(append([]string{}, c.Must...), c.May...) {
+		if _, ok := s.AttributeType(name); !ok {
+			return fmt.Errorf("object class %s uses an unknown attribute type '%s'", c.Name(), name)
+		}
+	}
+
+	if err := s.checkUnused(c.OID, c.Names, func(key string) bool { _, ok := s.classes[key]; return ok }); err != nil {
+		return err
+	}
+	s.classes[c.OID] = c
+	for _, name := range c.Names {
+		s.classes[strings.ToLower(name)] = c
+	}
+	s.objectClasses = append(s.objectClasses, c)
+	return nil
+}
+
+// checkUnused returns an error if the OID or any of the names is already defined.
+func (s *Schema) checkUnused(oid string, names []string, defined func(key string) bool) error {
+	if defined(oid) {
+		return fmt.Errorf("'%s' is already defined", oid)
+	}
+	for _, name := range names {
+		if defined(strings.ToLower(name)) {
+			return fmt.Errorf("'%s' is already defined", name)
+		}
+	}
+	return nil
+}
+
+// AttributeType returns the attribute type with the given name or OID. Any options in an
+// attribute description, e.g. ;lang-en, are ignored.
+func (s *Schema) AttributeType(name string) (*AttributeType, bool) {
+	if i := strings.IndexByte(name, ';'); i >= 0 {
+		name = name[:i]
+	}
+	t, ok := s.attributes[strings.ToLower(name)]
+	return t, ok
+}
+
+// ObjectClass returns the object class with the given name or OID.
+func (s *Schema) ObjectClass(name string) (*ObjectClass, bool) {
+	c, ok := s.classes[strings.ToLower(name)]
+	return c, ok
+}
+
+// AttributeTypes returns every attribute type in the order they were added.
+func (s *Schema) AttributeTypes() []*AttributeType {
+	return s.attributeTypes
+}
+
+// ObjectClasses returns every object class in the order they were added.
+func (s *Schema) ObjectClasses() []*ObjectClass {
+	return s.objectClasses
+}
+
+// Register makes the matching rules of the schema's attribute types available when evaluating
+// search filters. Matching rules which the query package doesn't implement are left out, so
+// values of those attributes are compared ignoring case.
+func (s *Schema) Register() error {
+	rule := func(name string) string {
+		if query.LookupMatchingRule(name) == nil {
+			return ""
+		}
+		return name
+	}
+
+	for _, t := range s.attributeTypes {
+		err := query.RegisterAttributeType(query.AttributeType{
+			OID:        t.OID,
+			Names:      t.Names,
+			Equality:   rule(t.Equality),
+			Ordering:   rule(t.Ordering),
+			Substrings: rule(t.Substrings),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
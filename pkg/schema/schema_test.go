@@ -0,0 +1,96 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	"github.com/shauncampbell/dapper/pkg/query"
+)
+
+func TestDefaultSchema(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := Default()
+
+	// names and OIDs both find definitions, ignoring case and options.
+	cn, ok := s.AttributeType("commonName")
+	Ω(ok).Should(gomega.Equal(true))
+	Ω(cn.OID).Should(gomega.Equal("2.5.4.3"))
+	same, _ := s.AttributeType("CN;lang-en")
+	Ω(same).Should(gomega.BeIdenticalTo(cn))
+	same, _ = s.AttributeType("2.5.4.3")
+	Ω(same).Should(gomega.BeIdenticalTo(cn))
+
+	// matching rules and syntax are inherited from the supertype.
+	Ω(cn.Equality).Should(gomega.Equal("caseIgnoreMatch"))
+	Ω(cn.Substrings).Should(gomega.Equal("caseIgnoreSubstringsMatch"))
+	Ω(cn.Syntax).Should(gomega.Equal("1.3.6.1.4.1.1466.115.121.1.15"))
+
+	uidNumber, _ := s.AttributeType("uidNumber")
+	Ω(uidNumber.SingleValue).Should(gomega.Equal(true))
+	modifyTimestamp, _ := s.AttributeType("modifyTimestamp")
+	Ω(modifyTimestamp.Usage).Should(gomega.Equal(DirectoryOperation))
+	Ω(modifyTimestamp.NoUserModification).Should(gomega.Equal(true))
+
+	person, ok := s.ObjectClass("inetorgperson")
+	Ω(ok).Should(gomega.Equal(true))
+	Ω(person.Kind).Should(gomega.Equal(Structural))
+	Ω(person.Sup).Should(gomega.Equal([]string{"organizationalPerson"}))
+	posixAccount, _ := s.ObjectClass("posixAccount")
+	Ω(posixAccount.Kind).Should(gomega.Equal(Auxiliary))
+	Ω(posixAccount.Must).Should(gomega.Equal([]string{"cn", "uid", "uidNumber", "gidNumber", "homeDirectory"}))
+	top, _ := s.ObjectClass("2.5.6.0")
+	Ω(top.Kind).Should(gomega.Equal(Abstract))
+
+	Ω(s.AttributeTypes()[0].Name()).Should(gomega.Equal("objectClass"))
+	Ω(s.ObjectClasses()[0].Name()).Should(gomega.Equal("top"))
+}
+
+func TestAddDefinitions(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := Default()
+	Ω(s.AddAttributeType("( 1.3.6.1.4.1.99999.1.1 NAME 'jellyfinId' EQUALITY caseExactMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.15 SINGLE-VALUE )")).Should(gomega.Succeed())
+	Ω(s.AddObjectClass("( 1.3.6.1.4.1.99999.2.1 NAME 'jellyfinUser' SUP top AUXILIARY MAY jellyfinId )")).Should(gomega.Succeed())
+	_, ok := s.ObjectClass("jellyfinUser")
+	Ω(ok).Should(gomega.Equal(true))
+
+	errors := []error{
+		s.AddAttributeType("( 1.3.6.1.4.1.99999.1.2 NAME 'cn' SUP name )"),
+		s.AddAttributeType("( 2.5.4.3 NAME 'anotherName' SUP name )"),
+		s.AddAttributeType("( 1.3.6.1.4.1.99999.1.3 NAME 'noSyntax' )"),
+		s.AddAttributeType("( 1.3.6.1.4.1.99999.1.4 NAME 'badSup' SUP madeUp )"),
+		s.AddAttributeType("( 1.3.6.1.4.1.99999.1.5 NAME 'badUsage' SUP name USAGE sometimes )"),
+		s.AddObjectClass("( 1.3.6.1.4.1.99999.2.2 NAME 'badMay' SUP top AUXILIARY MAY madeUp )"),
+		s.AddObjectClass("( 1.3.6.1.4.1.99999.2.3 NAME 'badSup' SUP madeUp AUXILIARY )"),
+		s.AddObjectClass("( 1.3.6.1.4.1.99999.2.4 NAME 'person' SUP top STRUCTURAL )"),
+	}
+	for i, err := range errors {
+		Ω(err).ShouldNot(gomega.BeNil(), i)
+	}
+	_, ok = s.AttributeType("1.3.6.1.4.1.99999.1.2")
+	Ω(ok).Should(gomega.Equal(false))
+}
+
+func TestRegister(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := Default()
+	Ω(s.AddAttributeType("( 1.3.6.1.4.1.99999.1.9 NAME 'x-schema-test' EQUALITY caseExactMatch SUBSTR madeUpSubstringsMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.15 )")).Should(gomega.Succeed())
+	Ω(s.Register()).Should(gomega.Succeed())
+
+	t1, ok := query.LookupAttributeType("x-schema-test")
+	Ω(ok).Should(gomega.Equal(true))
+	Ω(t1.Equality).Should(gomega.Equal("caseExactMatch"))
+	Ω(t1.Substrings).Should(gomega.Equal(""))
+
+	// inherited matching rules are registered too.
+	Ω(query.EqualityMatch("surname").Name).Should(gomega.Equal("caseIgnoreMatch"))
+	Ω(query.EqualityMatch("labeledURI").Name).Should(gomega.Equal("caseExactMatch"))
+}
@@ -0,0 +1,226 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nmcclain/ldap"
+	"github.com/shauncampbell/dapper/pkg/dn"
+	"github.com/shauncampbell/dapper/pkg/query"
+)
+
+// Syntax is an LDAP syntax (RFC 4512 section 4.1.5).
+type Syntax struct {
+	OID         string // The numeric OID of the syntax
+	Description string // A description of the syntax
+}
+
+// String returns the syntax as an LDAP syntax description.
+func (s Syntax) String() string {
+	return fmt.Sprintf("( %s DESC %s )", s.OID, quote(s.Description))
+}
+
+// syntaxes are the syntaxes used by the built-in attribute types and matching rules, from
+// RFC 4517, RFC 2252, RFC 3672 and RFC 4530.
+var syntaxes = []Syntax{
+	{OID: "1.3.6.1.4.1.1466.115.121.1.3", Description: "Attribute Type Description"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.4", Description: "Audio"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.5", Description: "Binary"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.6", Description: "Bit String"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.7", Description: "Boolean"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.8", Description: "Certificate"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.9", Description: "Certificate List"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.10", Description: "Certificate Pair"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.11", Description: "Country String"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.12", Description: "DN"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.14", Description: "Delivery Method"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.15", Description: "Directory String"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.16", Description: "DIT Content Rule Description"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.17", Description: "DIT Structure Rule Description"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.21", Description: "Enhanced Guide"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.22", Description: "Facsimile Telephone Number"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.23", Description: "Fax"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.24", Description: "Generalized Time"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.25", Description: "Guide"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.26", Description: "IA5 String"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.27", Description: "INTEGER"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.28", Description: "JPEG"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.30", Description: "Matching Rule Description"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.31", Description: "Matching Rule Use Description"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.34", Description: "Name And Optional UID"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.35", Description: "Name Form Description"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.36", Description: "Numeric String"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.37", Description: "Object Class Description"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.38", Description: "OID"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.39", Description: "Other Mailbox"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.40", Description: "Octet String"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.41", Description: "Postal Address"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.44", Description: "Printable String"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.45", Description: "Subtree Specification"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.49", Description: "Supported Algorithm"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.50", Description: "Telephone Number"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.51", Description: "Teletex Terminal Identifier"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.52", Description: "Telex Number"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.54", Description: "LDAP Syntax Description"},
+	{OID: "1.3.6.1.4.1.1466.115.121.1.58", Description: "Substring Assertion"},
+	{OID: "1.3.6.1.1.16.1", Description: "UUID"},
+}
+
+// Syntaxes returns the syntaxes known to the server.
+func Syntaxes() []Syntax {
+	return syntaxes
+}
+
+// usageKeywords are the USAGE keywords for each Usage, as written in a description.
+var usageKeywords = map[Usage]string{
+	DirectoryOperation:   "directoryOperation",
+	DistributedOperation: "distributedOperation",
+	DSAOperation:         "dSAOperation",
+}
+
+// kindKeywords are the keywords for each Kind, as written in a description.
+var kindKeywords = map[Kind]string{
+	Structural: "STRUCTURAL",
+	Abstract:   "ABSTRACT",
+	Auxiliary:  "AUXILIARY",
+}
+
+// String returns the attribute type as an attribute type description (RFC 4512 section 4.1.2).
+func (t *AttributeType) String() string {
+	b := &strings.Builder{}
+	b.WriteString("( " + t.OID)
+	if len(t.Names) > 0 {
+		b.WriteString(" NAME " + qdescrs(t.Names))
+	}
+	if t.Description != "" {
+		b.WriteString(" DESC " + quote(t.Description))
+	}
+	if t.Obsolete {
+		b.WriteString(" OBSOLETE")
+	}
+	if t.Sup != "" {
+		b.WriteString(" SUP " + t.Sup)
+	}
+	if t.Equality != "" {
+		b.WriteString(" EQUALITY " + t.Equality)
+	}
+	if t.Ordering != "" {
+		b.WriteString(" ORDERING " + t.Ordering)
+	}
+	if t.Substrings != "" {
+		b.WriteString(" SUBSTR " + t.Substrings)
+	}
+	b.WriteString(" SYNTAX " + t.Syntax)
+	if t.SingleValue {
+		b.WriteString(" SINGLE-VALUE")
+	}
+	if t.Collective {
+		b.WriteString(" COLLECTIVE")
+	}
+	if t.NoUserModification {
+		b.WriteString(" NO-USER-MODIFICATION")
+	}
+	if usage, ok := usageKeywords[t.Usage]; ok {
+		b.WriteString(" USAGE " + usage)
+	}
+	b.WriteString(" )")
+	return b.String()
+}
+
+// String returns the object class as an object class description (RFC 4512 section 4.1.1).
+func (c *ObjectClass) String() string {
+	b := &strings.Builder{}
+	b.WriteString("( " + c.OID)
+	if len(c.Names) > 0 {
+		b.WriteString(" NAME " + qdescrs(c.Names))
+	}
+	if c.Description != "" {
+		b.WriteString(" DESC " + quote(c.Description))
+	}
+	if c.Obsolete {
+		b.WriteString(" OBSOLETE")
+	}
+	if len(c.Sup) > 0 {
+		b.WriteString(" SUP " + oids(c.Sup))
+	}
+	b.WriteString(" " + kindKeywords[c.Kind])
+	if len(c.Must) > 0 {
+		b.WriteString(" MUST " + oids(c.Must))
+	}
+	if len(c.May) > 0 {
+		b.WriteString(" MAY " + oids(c.May))
+	}
+	b.WriteString(" )")
+	return b.String()
+}
+
+// describeMatchingRule returns a matching rule as a matching rule description (RFC 4512
+// section 4.1.3).
+func describeMatchingRule(rule *query.MatchingRule) string {
+	return fmt.Sprintf("( %s NAME %s SYNTAX %s )", rule.OID, quote(rule.Name), rule.Syntax)
+}
+
+// Subentry returns the subschema subentry publishing the schema (RFC 4512 section 4.2), so that
+// clients can find out which attribute types, object classes, syntaxes and matching rules the
+// server knows about. The schema attributes are operational, so clients must ask for them.
+func (s *Schema) Subentry(name string) *ldap.Entry {
+	attributeTypes := make([]string, 0, len(s.attributeTypes))
+	for _, t := range s.attributeTypes {
+		attributeTypes = append(attributeTypes, t.String())
+	}
+	objectClasses := make([]string, 0, len(s.objectClasses))
+	for _, c := range s.objectClasses {
+		objectClasses = append(objectClasses, c.String())
+	}
+	ldapSyntaxes := make([]string, 0, len(syntaxes))
+	for _, syntax := range syntaxes {
+		ldapSyntaxes = append(ldapSyntaxes, syntax.String())
+	}
+	matchingRules := make([]string, 0)
+	for _, rule := range query.MatchingRules() {
+		matchingRules = append(matchingRules, describeMatchingRule(rule))
+	}
+
+	cn := "Subschema"
+	if parsed, err := dn.Parse(name); err == nil && len(parsed) > 0 {
+		cn = parsed[0][0].Value
+	}
+
+	return &ldap.Entry{
+		DN: name,
+		Attributes: []*ldap.EntryAttribute{
+			{Name: "objectClass", Values: []string{"top", "subentry", "subschema", "extensibleObject"}},
+			{Name: "cn", Values: []string{cn}},
+			{Name: "subtreeSpecification", Values: []string{"{}"}},
+			{Name: "attributeTypes", Values: attributeTypes},
+			{Name: "objectClasses", Values: objectClasses},
+			{Name: "ldapSyntaxes", Values: ldapSyntaxes},
+			{Name: "matchingRules", Values: matchingRules},
+		},
+	}
+}
+
+// qdescrs formats one or more names for a description, e.g. 'cn' or ( 'cn' 'commonName' ).
+func qdescrs(names []string) string {
+	if len(names) == 1 {
+		return quote(names[0])
+	}
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		quoted = append(quoted, quote(name))
+	}
+	return "( " + strings.Join(quoted, " ") + " )"
+}
+
+// oids formats one or more OIDs or names for a description, e.g. cn or ( cn $ sn ).
+func oids(names []string) string {
+	if len(names) == 1 {
+		return names[0]
+	}
+	return "( " + strings.Join(names, " $ ") + " )"
+}
+
+// quote returns the value as a quoted string, escaping quotes and backslashes.
+func quote(value string) string {
+	return "'" + strings.NewReplacer("\\", "\\5C", "'", "\\27").Replace(value) + "'"
+}
@@ -0,0 +1,68 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestDescriptions(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := Default()
+	cn, _ := s.AttributeType("cn")
+	Ω(cn.String()).Should(gomega.Equal("( 2.5.4.3 NAME ( 'cn' 'commonName' ) SUP name EQUALITY caseIgnoreMatch SUBSTR caseIgnoreSubstringsMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.15 )"))
+	modifyTimestamp, _ := s.AttributeType("modifyTimestamp")
+	Ω(modifyTimestamp.String()).Should(gomega.HaveSuffix(" SINGLE-VALUE NO-USER-MODIFICATION USAGE directoryOperation )"))
+
+	account, _ := s.ObjectClass("posixAccount")
+	Ω(account.String()).Should(gomega.Equal("( 1.3.6.1.1.1.2.0 NAME 'posixAccount' DESC 'Abstraction of an account with POSIX attributes' SUP top AUXILIARY MUST ( cn $ uid $ uidNumber $ gidNumber $ homeDirectory ) MAY ( userPassword $ loginShell $ gecos $ description ) )"))
+
+	// quotes and backslashes in descriptions are escaped.
+	Ω(quote(`it's a \ path`)).Should(gomega.Equal(`'it\27s a \5C path'`))
+
+	// every description can be read back into an identical definition.
+	copied := New()
+	for _, t := range s.AttributeTypes() {
+		Ω(copied.AddAttributeType(t.String())).Should(gomega.Succeed())
+		same, _ := copied.AttributeType(t.OID)
+		Ω(*same).Should(gomega.Equal(*t))
+	}
+	for _, c := range s.ObjectClasses() {
+		Ω(copied.AddObjectClass(c.String())).Should(gomega.Succeed())
+		same, _ := copied.ObjectClass(c.OID)
+		Ω(*same).Should(gomega.Equal(*c))
+	}
+}
+
+func TestSubentry(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := Default()
+	entry := s.Subentry("cn=Subschema")
+	Ω(entry.DN).Should(gomega.Equal("cn=Subschema"))
+	Ω(entry.GetAttributeValue("cn")).Should(gomega.Equal("Subschema"))
+	Ω(entry.GetAttributeValues("objectClass")).Should(gomega.ContainElement("subschema"))
+	Ω(entry.GetAttributeValues("attributeTypes")).Should(gomega.HaveLen(len(s.AttributeTypes())))
+	Ω(entry.GetAttributeValues("objectClasses")).Should(gomega.HaveLen(len(s.ObjectClasses())))
+	Ω(entry.GetAttributeValues("ldapSyntaxes")).Should(gomega.ContainElement("( 1.3.6.1.4.1.1466.115.121.1.15 DESC 'Directory String' )"))
+	Ω(entry.GetAttributeValues("matchingRules")).Should(gomega.ContainElement("( 2.5.13.2 NAME 'caseIgnoreMatch' SYNTAX 1.3.6.1.4.1.1466.115.121.1.15 )"))
+
+	// the subentry itself conforms to the schema it publishes.
+	Ω(s.Validate(entry)).Should(gomega.Succeed())
+
+	// every syntax used by an attribute type is published.
+	published := make(map[string]bool)
+	for _, syntax := range Syntaxes() {
+		published[syntax.OID] = true
+	}
+	for _, t := range s.AttributeTypes() {
+		oid := strings.SplitN(t.Syntax, "{", 2)[0]
+		Ω(published[oid]).Should(gomega.Equal(true), t.Name())
+	}
+}
@@ -0,0 +1,160 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nmcclain/ldap"
+	"github.com/shauncampbell/dapper/pkg/dn"
+	"github.com/shauncampbell/dapper/pkg/query"
+)
+
+// ViolationError is returned when an entry doesn't conform to the schema. It lists every
+// problem found with the entry.
+type ViolationError struct {
+	DN       string   // The DN of the entry
+	Problems []string // A description of each problem
+}
+
+// Error returns the error message.
+func (e *ViolationError) Error() string {
+	return fmt.Sprintf("entry '%s' violates the schema: %s", e.DN, strings.Join(e.Problems, "; "))
+}
+
+// Validate checks an entry against the schema (RFC 4512 section 2.4 and 2.5). Every object
+// class must be defined and the classes must include a structural class, every attribute
+// required by the classes must be present, every user attribute must be allowed by one of
+// the classes unless the entry is an extensibleObject, single valued attributes must only
+// have one value and the values in the entry's RDN must be present in the entry.
+func (s *Schema) Validate(entry *ldap.Entry) error {
+	problems := make([]string, 0)
+
+	// collect the classes and their superclasses, and what they require and allow.
+	classes := make(map[*ObjectClass]bool)
+	requiredBy := make(map[*AttributeType]*ObjectClass)
+	required := make([]*AttributeType, 0)
+	allowed := make(map[*AttributeType]bool)
+	structural, extensible := false, false
+
+	var include func(c *ObjectClass)
+	include = func(c *ObjectClass) {
+		if classes[c] {
+			return
+		}
+		classes[c] = true
+		structural = structural || c.Kind == Structural
+		extensible = extensible || c.OID == "1.3.6.1.4.1.1466.101.120.111"
+		for _, name := range c.Must {
+			t, _ := s.AttributeType(name)
+			if _, ok := requiredBy[t]; !ok {
+				requiredBy[t] = c
+				required = append(required, t)
+			}
+			allowed[t] = true
+		}
+		for _, name := range c.May {
+			t, _ := s.AttributeType(name)
+			allowed[t] = true
+		}
+		for _, name := range c.Sup {
+			sup, _ := s.ObjectClass(name)
+			include(sup)
+		}
+	}
+
+	names := values(entry, "objectClass")
+	if len(names) == 0 {
+		problems = append(problems, "it has no objectClass")
+	}
+	for _, name := range names {
+		c, ok := s.ObjectClass(name)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("objectClass '%s' is not defined", name))
+			continue
+		}
+		include(c)
+	}
+	if len(classes) > 0 && !structural {
+		problems = append(problems, "it has no structural objectClass")
+	}
+
+	// check each attribute is defined, allowed and has the right number of values.
+	present := make(map[*AttributeType]bool)
+	for _, a := range entry.Attributes {
+		t, ok := s.AttributeType(a.Name)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("attribute '%s' is not defined", a.Name))
+			continue
+		}
+		if t.SingleValue && (len(a.Values) > 1 || (present[t] && len(a.Values) > 0)) {
+			problems = append(problems, fmt.Sprintf("attribute '%s' may only have one value", a.Name))
+		}
+		present[t] = present[t] || len(a.Values) > 0
+
+		// operational attributes aren't governed by object classes.
+		if t.Usage != UserApplications || extensible || len(classes) == 0 || s.allows(allowed, t) {
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("attribute '%s' is not allowed by the entry's object classes", a.Name))
+	}
+
+	for _, t := range required {
+		if !present[t] {
+			problems = append(problems, fmt.Sprintf("attribute '%s' is required by objectClass '%s'", t.Name(), requiredBy[t].Name()))
+		}
+	}
+
+	// the values of the RDN must be present in the entry.
+	name, err := dn.Parse(entry.DN)
+	if err != nil {
+		problems = append(problems, err.Error())
+	} else if len(name) > 0 {
+		for _, ava := range name[0] {
+			if !hasValue(entry, ava.Type, ava.Value) {
+				problems = append(problems, fmt.Sprintf("naming attribute '%s=%s' is not present in the entry", ava.Type, ava.Value))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ViolationError{DN: entry.DN, Problems: problems}
+	}
+	return nil
+}
+
+// allows returns true if the attribute type, or one of its supertypes, is allowed.
+func (s *Schema) allows(allowed map[*AttributeType]bool, t *AttributeType) bool {
+	for t != nil {
+		if allowed[t] {
+			return true
+		}
+		if t.Sup == "" {
+			return false
+		}
+		t, _ = s.AttributeType(t.Sup)
+	}
+	return false
+}
+
+// values returns the values of every attribute in the entry with the given name.
+func values(entry *ldap.Entry, attribute string) []string {
+	out := make([]string, 0)
+	for _, a := range entry.Attributes {
+		if strings.EqualFold(a.Name, attribute) {
+			out = append(out, a.Values...)
+		}
+	}
+	return out
+}
+
+// hasValue returns true if the entry has a value of the attribute equal to the value using the
+// attribute's equality matching rule.
+func hasValue(entry *ldap.Entry, attribute, value string) bool {
+	rule := query.EqualityMatch(attribute)
+	for _, v := range values(entry, attribute) {
+		if rule.Equal(v, value) {
+			return true
+		}
+	}
+	return false
+}
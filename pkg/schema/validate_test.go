@@ -0,0 +1,96 @@
+package schema
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/nmcclain/ldap"
+	"github.com/onsi/gomega"
+)
+
+// newEntry creates an entry with the attributes in alphabetical order.
+func newEntry(dn string, attributes map[string][]string) *ldap.Entry {
+	entry := &ldap.Entry{DN: dn}
+	for name, values := range attributes {
+		entry.Attributes = append(entry.Attributes, &ldap.EntryAttribute{Name: name, Values: values})
+	}
+	sort.Slice(entry.Attributes, func(i, j int) bool { return entry.Attributes[i].Name < entry.Attributes[j].Name })
+	return entry
+}
+
+// problems returns the problems found when validating the entry.
+func problems(s *Schema, entry *ldap.Entry) []string {
+	err := s.Validate(entry)
+	if err == nil {
+		return nil
+	}
+	return err.(*ViolationError).Problems
+}
+
+func TestValidateValidEntries(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := Default()
+	entries := []*ldap.Entry{
+		newEntry("dc=home,dc=lab", map[string][]string{"objectClass": {"top", "domain"}, "dc": {"home"}}),
+		newEntry("ou=users,dc=home,dc=lab", map[string][]string{"objectClass": {"organizationalUnit"}, "ou": {"users"}}),
+		newEntry("uid=jsmith,ou=users,dc=home,dc=lab", map[string][]string{
+			"objectClass":     {"inetOrgPerson", "posixAccount", "shadowAccount"},
+			"cn":              {"John Smith"},
+			"sn":              {"Smith"},
+			"givenname":       {"John"},
+			"uid":             {"JSmith"},
+			"uidNumber":       {"1000"},
+			"gidNumber":       {"1000"},
+			"homeDirectory":   {"/home/jsmith"},
+			"mail;x-work":     {"john@home.lab"},
+			"userPassword":    {"{SSHA}I8wq1+4gyJVJUtQW96JGcmCL46ADyPnW"},
+			"createTimestamp": {"20200102150405Z"},
+		}),
+		newEntry("cn=admins,dc=home,dc=lab", map[string][]string{"objectClass": {"groupOfNames"}, "cn": {"admins"}, "member": {"uid=jsmith,ou=users,dc=home,dc=lab"}}),
+		newEntry("cn=anything,dc=home,dc=lab", map[string][]string{"objectClass": {"device", "extensibleObject"}, "cn": {"anything"}, "mail": {"a@b.c"}}),
+	}
+	for _, entry := range entries {
+		Ω(s.Validate(entry)).Should(gomega.Succeed(), entry.DN)
+	}
+}
+
+func TestValidateInvalidEntries(t *testing.T) {
+	gomega.RegisterTestingT(t)
+	// workaround as our naming clashes with gomega.
+	Ω := gomega.Ω
+
+	s := Default()
+	tests := map[*ldap.Entry][]string{
+		newEntry("cn=user,dc=home,dc=lab", map[string][]string{"objectClass": {"inetOrgPerson"}, "cn": {"user"}, "givename": {"Home"}}): {
+			"attribute 'givename' is not defined",
+			"attribute 'sn' is required by objectClass 'person'",
+		},
+		newEntry("cn=root,dc=home,dc=lab", map[string][]string{"objectClass": {"posixAccount"}, "cn": {"root"}, "uid": {"root"}}): {
+			"it has no structural objectClass",
+			"attribute 'uidNumber' is required by objectClass 'posixAccount'",
+			"attribute 'gidNumber' is required by objectClass 'posixAccount'",
+			"attribute 'homeDirectory' is required by objectClass 'posixAccount'",
+		},
+		newEntry("cn=user,dc=home,dc=lab", map[string][]string{"objectClass": {"person", "jellyfinUser"}, "cn": {"user"}, "sn": {"User"}, "mail": {"user@home.lab"}}): {
+			"objectClass 'jellyfinUser' is not defined",
+			"attribute 'mail' is not allowed by the entry's object classes",
+		},
+		newEntry("cn=group,dc=home,dc=lab", map[string][]string{"objectClass": {"posixGroup"}, "cn": {"other"}, "gidNumber": {"1", "2"}}): {
+			"attribute 'gidNumber' may only have one value",
+			"naming attribute 'cn=group' is not present in the entry",
+		},
+		newEntry("cn=nothing,dc=home,dc=lab", map[string][]string{"cn": {"nothing"}}): {
+			"it has no objectClass",
+		},
+	}
+
+	for entry, expected := range tests {
+		Ω(problems(s, entry)).Should(gomega.ConsistOf(expected), entry.DN)
+	}
+
+	err := s.Validate(newEntry("cn=nothing,dc=home,dc=lab", nil))
+	Ω(err).Should(gomega.MatchError("entry 'cn=nothing,dc=home,dc=lab' violates the schema: it has no objectClass; naming attribute 'cn=nothing' is not present in the entry"))
+}